@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/audit"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/detector"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/publish"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Check that published releases hold the files in the download manifest",
+	Long: `List the assets of every release and compare them with the download manifest.
+Sizes and, where the forge reports them, checksums are taken from the forge.
+Other assets are only reported as verified with --download, which downloads
+and hashes them; without it they are unverified, and a checksum in the
+release notes that differs from the manifest is still reported.
+Missing, extra, renamed and corrupted assets, and assets that could not be
+downloaded, are reported, and the command exits with status 1 if any are found.
+
+Examples:
+  # Audit every release against the manifest
+  qoder-downloader release audit
+
+  # Hash the contents of the assets of one version
+  qoder-downloader release audit --version 0.2.1 --download
+
+  # Also compare with upstream and keep a machine-readable report
+  qoder-downloader release audit --upstream --report audit.json`,
+	Run: runAudit,
+}
+
+var (
+	auditDir      string
+	auditVersions []string
+	auditDownload bool
+	auditUpstream bool
+	auditJSON     bool
+	auditReport   string
+)
+
+func init() {
+	releaseCmd.AddCommand(auditCmd)
+	auditCmd.Flags().StringVarP(&auditDir, "downloads", "d", "./downloads", "Downloads directory")
+	auditCmd.Flags().StringSliceVar(&auditVersions, "version", nil, "Only audit these versions (default: every archived or released version)")
+	auditCmd.Flags().BoolVar(&auditDownload, "download", false, "Download and hash every asset the forge does not report a checksum for")
+	auditCmd.Flags().BoolVar(&auditUpstream, "upstream", false, "Also compare the archived files against upstream MD5 checksums")
+	auditCmd.Flags().BoolVar(&auditJSON, "json", false, "Write the report as JSON")
+	auditCmd.Flags().StringVar(&auditReport, "report", "", "Also write the JSON report to this file")
+	addPublishFlags(auditCmd)
+}
+
+func runAudit(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	m, err := manifest.Load(auditDir)
+	if err != nil {
+		log.Fatalf("Failed to load manifest: %v", err)
+	}
+	assetNames, err := assetNameLayout()
+	if err != nil {
+		log.Fatalf("Invalid asset name template: %v", err)
+	}
+	publishers, err := newPublishers(ctx, cmd, false)
+	if err != nil {
+		log.Fatalf("Failed to set up publishing: %v", err)
+	}
+
+	result := &audit.Audit{GeneratedAt: time.Now().UTC()}
+	for _, publisher := range publishers {
+		if verbose && !auditJSON {
+			fmt.Printf("Auditing releases on %s\n", describePublisher(publisher))
+		}
+		report, err := auditReleases(ctx, publisher, m, assetNames, verbose && !auditJSON)
+		if err != nil {
+			log.Fatalf("Failed to audit releases on %s: %v", describePublisher(publisher), err)
+		}
+		result.Targets = append(result.Targets, report)
+	}
+
+	if auditReport != "" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode report: %v", err)
+		}
+		if err := os.WriteFile(auditReport, append(data, '\n'), 0644); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+	}
+	if auditJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			log.Fatalf("Failed to encode report: %v", err)
+		}
+	} else {
+		for i, report := range result.Targets {
+			if i > 0 {
+				fmt.Println()
+			}
+			printAuditReport(report, len(result.Targets) > 1, verbose)
+		}
+	}
+
+	if !result.OK() {
+		os.Exit(1)
+	}
+}
+
+// auditReleases audits the releases on one forge of every archived or
+// released version, or of the versions asked for
+func auditReleases(ctx context.Context, publisher publish.ReleasePublisher, m *manifest.Manifest, assetNames *platform.Layout, verbose bool) (*audit.Report, error) {
+	releases, err := publisher.Releases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tags := releaseTags()
+	byVersion := tags.ByVersion(releases)
+
+	versions := auditVersions
+	if len(versions) == 0 {
+		seen := make(map[string]bool)
+		for _, version := range m.Versions() {
+			seen[version] = true
+		}
+		for version := range byVersion {
+			seen[version] = true
+		}
+		for version := range seen {
+			versions = append(versions, version)
+		}
+		detector.SortVersionStrings(versions)
+	}
+
+	auditor := audit.New(publisher, audit.Options{Download: auditDownload, Upstream: auditUpstream, Verbose: verbose})
+	report := &audit.Report{Forge: publisher.Forge(), Repo: publisher.Repo(), Downloaded: auditDownload, Counts: make(map[audit.Status]int)}
+	for _, version := range versions {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		entries := m.ForVersion(version)
+		if len(entries) == 0 {
+			// Without archived files there is nothing to compare the assets with
+			if len(byVersion[version]) > 0 {
+				report.Skipped = append(report.Skipped, version)
+			}
+			continue
+		}
+
+		var expected []audit.Expected
+		for _, entry := range entries {
+			platformInfo, err := platform.GetArtifact(entry.Platform, entry.Artifact)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", entry.Path, err)
+				continue
+			}
+			expected = append(expected, audit.Expected{Name: assetNames.Base(version, platformInfo), Entry: entry})
+		}
+		release, err := auditor.Release(ctx, version, tags.Find(releases, version), expected)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", version, err)
+		}
+		report.Add(release)
+	}
+	return report, nil
+}
+
+// printAuditReport lists the problems found on one forge and counts the
+// results. Unverified assets are only listed when verbose.
+func printAuditReport(report *audit.Report, header, verbose bool) {
+	if header {
+		fmt.Printf("%s %s\n", report.Forge, report.Repo)
+	}
+	releases := 0
+	for _, release := range report.Releases {
+		if release.Tag != "" {
+			releases++
+		}
+		for _, result := range release.Results {
+			if result.Status == audit.StatusOK || (result.Status == audit.StatusUnverified && !verbose) {
+				continue
+			}
+			fmt.Printf("%-18s %s: %s", result.Status, release.Version, result.Name())
+			switch result.Status {
+			case audit.StatusRenamed:
+				fmt.Printf(" (expected %s)", result.Expected)
+			case audit.StatusCorrupted:
+				if result.Size != result.ExpectedSize && result.ExpectedSize > 0 {
+					fmt.Printf(" (size %d, expected %d)", result.Size, result.ExpectedSize)
+				} else if result.SHA256 != "" && result.ExpectedSHA256 != "" && result.SHA256 != result.ExpectedSHA256 {
+					fmt.Printf(" (sha256 %s, expected %s)", result.SHA256, result.ExpectedSHA256)
+				}
+			case audit.StatusUpstreamMismatch:
+				fmt.Printf(" (upstream md5 %s)", result.UpstreamMD5)
+			}
+			if result.Detail != "" {
+				fmt.Printf(": %s", result.Detail)
+			}
+			fmt.Println()
+		}
+	}
+	for _, version := range report.Skipped {
+		fmt.Printf("%-18s %s: no archived files to compare with\n", "skipped", version)
+	}
+
+	how := "reported sizes and checksums"
+	if report.Downloaded {
+		how = "downloaded contents where no checksum is reported"
+	}
+	fmt.Printf("\nAudited %d versions (%d released) on %s %s using %s\n", len(report.Releases), releases, report.Forge, report.Repo, how)
+	for _, status := range []audit.Status{
+		audit.StatusOK,
+		audit.StatusMissing,
+		audit.StatusExtra,
+		audit.StatusRenamed,
+		audit.StatusCorrupted,
+		audit.StatusUnverified,
+		audit.StatusFailed,
+		audit.StatusUpstreamMismatch,
+	} {
+		if count := report.Counts[status]; count > 0 {
+			fmt.Printf("  %-18s %d\n", status+":", count)
+		}
+	}
+	if count := report.Counts[audit.StatusUnverified]; count > 0 && !report.Downloaded {
+		fmt.Printf("%d assets were only checked by size; use --download to hash them\n", count)
+	}
+}
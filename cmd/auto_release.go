@@ -7,36 +7,41 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
-	"github.com/google/go-github/v50/github"
 	"github.com/spf13/cobra"
-	"golang.org/x/oauth2"
 
 	"github.com/vibe-coding-labs/qoder-downloader/internal/cache"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/detector"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/downloader"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/notes"
 	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/publish"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/signing"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/verify"
 )
 
 var autoReleaseCmd = &cobra.Command{
 	Use:   "auto-release",
-	Short: "Automatically create GitHub releases for newly detected versions",
-	Long:  `Automatically create GitHub releases for versions that don't have releases yet.`,
-	Run:   runAutoRelease,
+	Short: "Automatically create releases for newly detected versions",
+	Long: `Automatically create releases for versions that don't have releases yet,
+on GitHub or on every forge listed under "targets" in the config file.
+
+Assets are published from the downloads directory: files recorded in its
+manifest are checked against their recorded checksums and uploaded as they
+are, and missing or damaged files are downloaded into it first. The manifest
+records which release asset each file was published as.`,
+	Run: runAutoRelease,
 }
 
-var (
-	githubToken string
-	githubRepo  string
-)
-
 func init() {
 	rootCmd.AddCommand(autoReleaseCmd)
-	autoReleaseCmd.Flags().StringVar(&githubToken, "token", "", "GitHub personal access token")
-	autoReleaseCmd.Flags().StringVar(&githubRepo, "repo", "vibe-coding-labs/qoder-downloader", "GitHub repository (owner/repo)")
+	autoReleaseCmd.Flags().StringVarP(&downloadsDir, "downloads", "d", "./downloads", "Downloads directory")
+	addPublishFlags(autoReleaseCmd)
 }
 
 func runAutoRelease(cmd *cobra.Command, args []string) {
@@ -69,185 +74,317 @@ func runAutoRelease(cmd *cobra.Command, args []string) {
 		fmt.Printf("Found %d valid versions in cache\n", len(validVersions))
 	}
 
-	// Get existing GitHub releases
-	ctx := context.Background()
-	client := createGitHubClient(ctx)
+	ctx := cmd.Context()
+	publishers, err := newPublishers(ctx, cmd, true)
+	if err != nil {
+		log.Fatalf("Failed to set up publishing: %v", err)
+	}
+	if err := setupSigning(); err != nil {
+		log.Fatalf("Failed to load signing key: %v", err)
+	}
+
+	// Releases made under any earlier tag format count as existing
+	tags := releaseTags()
+
+	assetNames, err := assetNameLayout()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	dl, err := downloader.NewDownloader(verbose, downloadsDir)
+	if err != nil {
+		log.Fatalf("Failed to create downloader: %v", err)
+	}
+	layout, err := artifactLayout()
+	if err != nil {
+		log.Fatalf("Invalid layout: %v", err)
+	}
+	dl.SetLayout(layout)
+
+	// Each forge is brought up to date on its own; the downloads directory
+	// is shared, so files are only fetched from upstream once
+	for _, publisher := range publishers {
+		if ctx.Err() != nil {
+			fmt.Println("Interrupted, remaining versions will be released on the next run")
+			return
+		}
+		if len(publishers) > 1 {
+			fmt.Printf("Publishing to %s\n", describePublisher(publisher))
+		}
+		autoRelease(ctx, publisher, cacheManager, dl, tags, assetNames, validVersions, verbose)
+	}
+}
 
-	existingReleases, err := getExistingReleases(ctx, client)
+// autoRelease publishes every version that a forge is missing or holds
+// incomplete
+func autoRelease(ctx context.Context, publisher publish.ReleasePublisher, cacheManager *cache.Manager, dl *downloader.Downloader, tags publish.TagScheme, assetNames *platform.Layout, validVersions []detector.Version, verbose bool) {
+	existingReleases, err := publisher.Releases(ctx)
 	if err != nil {
 		log.Printf("Failed to get existing releases: %v", err)
 		log.Println("Continuing without existing releases check...")
 	}
 
-	// Filter versions that don't have releases yet
-	versionsToRelease := make([]string, 0)
+	// Releases are reconciled rather than skipped once their tag exists, so
+	// a run that failed halfway is completed by the next one
+	var plans []releasePlan
 	for _, version := range validVersions {
-		versionStr := version.Raw
-		tagName := "v" + versionStr
-
-		// Check if release already exists
-		exists := false
-		for _, release := range existingReleases {
-			if release.TagName != nil && *release.TagName == tagName {
-				exists = true
-				break
-			}
-		}
-
-		if !exists {
-			versionsToRelease = append(versionsToRelease, versionStr)
+		plan := planRelease(version.Raw, tags.Find(existingReleases, version.Raw), assetNames)
+		if plan.needed() {
+			plans = append(plans, plan)
 		}
 	}
 
-	if len(versionsToRelease) == 0 {
-		fmt.Println("No new versions to create releases for")
+	if len(plans) == 0 {
+		fmt.Println("All releases are up to date")
+		if err := syncLatest(ctx, publisher, tags, false); err != nil {
+			fmt.Printf("Failed to update the latest release: %v\n", err)
+		}
 		return
 	}
 
-	fmt.Printf("Creating releases for %d new versions: %v\n", len(versionsToRelease), versionsToRelease)
+	versions := make([]string, len(plans))
+	for i, plan := range plans {
+		versions[i] = plan.version
+	}
+	fmt.Printf("Publishing %d versions: %v\n", len(plans), versions)
+
+	render := func(version string, files []notes.File) (string, error) {
+		// Sizes of the previous version come from the manifest, or else
+		// from its release
+		previous, _ := adjacentVersions(cacheManager, version)
+		sizes := make(map[string]int64)
+		if release := tags.Find(existingReleases, previous); release != nil {
+			for _, asset := range release.Assets {
+				if asset.Size >= 0 {
+					sizes[asset.Name] = asset.Size
+				}
+			}
+		}
+		for i, file := range files {
+			if entry := dl.Manifest().Find(previous, file.Platform, file.Artifact); entry != nil {
+				files[i].PreviousSize, files[i].HasPrevious = entry.Size, true
+				continue
+			}
+			p, err := platform.GetArtifact(file.Platform, file.Artifact)
+			if err != nil {
+				continue
+			}
+			if size, ok := sizes[assetNames.Base(previous, p)]; ok {
+				files[i].PreviousSize, files[i].HasPrevious = size, true
+			}
+		}
+		return releaseNotes(publisher, cacheManager, dl.Manifest(), version, files, tags.Tag)
+	}
 
-	// Create releases for each new version
-	for _, version := range versionsToRelease {
-		err := createReleaseForNewVersion(ctx, client, version)
+	for _, plan := range plans {
+		if ctx.Err() != nil {
+			fmt.Println("Interrupted, remaining versions will be released on the next run")
+			return
+		}
+		plan.released = firstSeen(cacheManager, dl.Manifest(), plan.version)
+		err := publishReleasePlan(ctx, publisher, tags, dl, plan, render, verbose)
 		if err != nil {
-			fmt.Printf("Failed to create release for %s: %v\n", version, err)
+			fmt.Printf("Failed to publish release for %s: %v\n", plan.version, err)
 		} else {
-			fmt.Printf("Successfully created release for %s\n", version)
+			fmt.Printf("Published release for %s\n", plan.version)
 		}
 	}
-}
 
-func createGitHubClient(ctx context.Context) *github.Client {
-	if githubToken != "" {
-		ts := oauth2.StaticTokenSource(
-			&oauth2.Token{AccessToken: githubToken},
-		)
-		tc := oauth2.NewClient(ctx, ts)
-		return github.NewClient(tc)
+	if err := syncLatest(ctx, publisher, tags, false); err != nil {
+		fmt.Printf("Failed to update the latest release: %v\n", err)
 	}
-	return github.NewClient(nil) // Anonymous client
 }
 
-func getExistingReleases(ctx context.Context, client *github.Client) ([]*github.RepositoryRelease, error) {
-	owner, repo, err := parseRepo(githubRepo)
-	if err != nil {
-		return nil, err
-	}
+// releasePlan lists the files one version still needs from upstream
+type releasePlan struct {
+	version string
+	exists  bool
+	files   []planFile
+	sums    bool // The release lacks its SHA256SUMS or signature
+	draft   bool // The release was left a draft by an earlier run
+	// When the version was first seen, which dates a new release
+	released time.Time
+}
 
-	releases, _, err := client.Repositories.ListReleases(ctx, owner, repo, &github.ListOptions{
-		PerPage: 100,
-	})
-	return releases, err
+// planFile is one upstream file and the asset name it is published under
+type planFile struct {
+	name     string
+	urls     []string               // Checksum files only; tried in order
+	platform *platform.PlatformInfo // The artifact, or nil for checksum files
 }
 
-func parseRepo(repo string) (string, string, error) {
-	parts := strings.Split(repo, "/")
-	if len(parts) != 2 {
-		return "", "", fmt.Errorf("invalid repo format, expected 'owner/repo'")
-	}
-	return parts[0], parts[1], nil
+func (p releasePlan) needed() bool {
+	return !p.exists || len(p.files) > 0 || p.sums || p.draft
 }
 
-func createReleaseForNewVersion(ctx context.Context, client *github.Client, version string) error {
-	tagName := "v" + version
-	releaseName := fmt.Sprintf("Qoder %s", version)
-	releaseBody := fmt.Sprintf("Automated release for Qoder version %s", version)
+// planRelease works out which assets of a version are missing from its
+// release, judging from the release listing alone. MD5 files are fetched
+// along with their artifact, and again for every artifact when the release
+// may still hold placeholders. A release without its SHA256SUMS takes every
+// artifact, so that all of them are listed.
+func planRelease(version string, release *publish.RemoteRelease, assetNames *platform.Layout) releasePlan {
+	plan := releasePlan{version: version, exists: release != nil}
+
+	var pending map[string]bool
+	placeholders := false
+	if release != nil {
+		var names []string
+		for _, p := range platform.GetAllPlatforms() {
+			names = append(names, assetNames.Base(version, p))
+		}
+		pending = make(map[string]bool)
+		for _, name := range publish.Pending(release, names) {
+			pending[name] = true
+		}
+		placeholders = publish.MayHavePlaceholders(release)
+
+		sumsAssets := []string{signing.SumsName}
+		if releaseSigner != nil {
+			sumsAssets = append(sumsAssets, signing.SignatureName)
+		}
+		plan.sums = len(publish.Pending(release, sumsAssets)) > 0
+		plan.draft = release.Draft
+	}
 
-	// First create the release without assets
-	cmd := exec.Command("gh", "release", "create", tagName, 
-		"--title", releaseName,
-		"--notes", releaseBody,
-		"--repo", githubRepo)
+	for _, p := range platform.GetAllPlatforms() {
+		url := platform.ConstructDownloadURL(version, p)
+		filename := assetNames.Base(version, p)
+		// Listing a file in SHA256SUMS takes its checksum, which only the
+		// local copy can provide
+		if release == nil || pending[filename] || plan.sums {
+			plan.files = append(plan.files, planFile{name: filename, platform: &p})
+		} else if !placeholders {
+			continue
+		}
+		plan.files = append(plan.files, planFile{name: filename + ".md5", urls: md5URLs(url, filename, p)})
+	}
+	return plan
+}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to create GitHub release with CLI: %v\nOutput: %s", err, string(output))
+// md5URLs returns the places upstream may keep the MD5 checksum of a file
+func md5URLs(url, filename string, p platform.PlatformInfo) []string {
+	if md5URL := platform.ConstructMD5URL(url); md5URL != url {
+		return []string{md5URL}
 	}
+	return []string{
+		strings.TrimSuffix(url, fmt.Sprintf(".%s", p.Extension)) + ".md5",
+		fmt.Sprintf("https://download.qoder.com/release/md5/%s.md5", filename),
+	}
+}
 
-	// Create a temporary directory for downloading assets
-	tmpDir, err := os.MkdirTemp("", fmt.Sprintf("qoder-assets-%s-*", version))
+// publishReleasePlan gathers the files a release needs and reconciles it.
+// Artifacts come from the downloads directory; checksum files are fetched
+// from upstream. The notes of a new release are rendered from the artifacts.
+func publishReleasePlan(ctx context.Context, publisher publish.ReleasePublisher, tags publish.TagScheme, dl *downloader.Downloader, plan releasePlan, render func(string, []notes.File) (string, error), verbose bool) error {
+	// Create a temporary directory for downloading checksum files
+	tmpDir, err := os.MkdirTemp("", fmt.Sprintf("qoder-assets-%s-*", plan.version))
 	if err != nil {
 		return fmt.Errorf("failed to create temp directory: %v", err)
 	}
 	defer os.RemoveAll(tmpDir)
 
-	// Download all platform assets for this version
-	platforms := platform.GetAllPlatforms()
-	assetPaths := []string{}
-
-	for _, platformInfo := range platforms {
-		// Download the main file
-		url := platform.ConstructDownloadURL(version, platformInfo)
-		filename := fmt.Sprintf("qoder-%s-%s.%s", version, platformInfo.Name, platformInfo.Extension)
-		filePath := filepath.Join(tmpDir, filename)
-
-		if err := downloadFile(url, filePath); err != nil {
-			log.Printf("Failed to download %s for version %s: %v", platformInfo.Name, version, err)
-			continue // Continue with other platforms even if one fails
-		}
+	m := dl.Manifest()
+	verifier := verify.NewVerifier(verify.Options{Verbose: verbose})
 
-		assetPaths = append(assetPaths, filePath)
-
-		// Also download the MD5 file
-		md5Url := strings.Replace(url, "/release/", "/release/md5/", 1)
-		if md5Url == url { // If the replacement didn't work, construct manually
-			// Try to construct MD5 URL based on common patterns
-			md5Filename := filename + ".md5"
-			md5FilePath := filepath.Join(tmpDir, md5Filename)
-			md5Url = strings.TrimSuffix(url, fmt.Sprintf(".%s", platformInfo.Extension)) + ".md5"
-			
-			if err := downloadFile(md5Url, md5FilePath); err != nil {
-				log.Printf("Failed to download MD5 file for %s: %v", filename, err)
-				// Create a simple MD5 file with placeholder content if download fails
-				placeholderMd5 := "PLACEHOLDER_MD5_VALUE"
-				if err := os.WriteFile(md5FilePath, []byte(placeholderMd5), 0644); err != nil {
-					log.Printf("Failed to create placeholder MD5 file for %s: %v", filename, err)
-				} else {
-					assetPaths = append(assetPaths, md5FilePath)
-				}
-			} else {
-				assetPaths = append(assetPaths, md5FilePath)
-			}
-			
-			// Alternative MD5 URL pattern - construct based on filename
+	var assets []publish.Asset
+	var files []notes.File
+	sources := make(map[string]*manifest.Entry)
+	for _, file := range plan.files {
+		if file.platform != nil {
+			entry, err := localArtifact(ctx, dl, verifier, plan.version, *file.platform)
 			if err != nil {
-				altMd5Url := fmt.Sprintf("https://download.qoder.com/release/md5/%s.md5", filename)
-				if err := downloadFile(altMd5Url, md5FilePath); err != nil {
-					log.Printf("Also failed to download MD5 file from alternative URL for %s: %v", filename, err)
-				} else {
-					assetPaths = append(assetPaths, md5FilePath)
-				}
+				// Missing files are retried on the next run
+				log.Printf("Failed to download %s for version %s: %v", file.name, plan.version, err)
+				continue
 			}
-		} else {
-			md5FilePath := filepath.Join(tmpDir, filename+".md5")
-			if err := downloadFile(md5Url, md5FilePath); err != nil {
-				log.Printf("Failed to download MD5 file for %s: %v", filename, err)
-			} else {
-				assetPaths = append(assetPaths, md5FilePath)
+			sources[file.name] = entry
+			assets = append(assets, publish.Asset{
+				Name:   file.name,
+				Path:   m.AbsPath(entry),
+				Size:   entry.Size,
+				SHA256: entry.SHA256,
+			})
+			files = append(files, notes.File{
+				Platform: entry.Platform,
+				Artifact: entry.Artifact,
+				Asset:    file.name,
+				URL:      entry.URL,
+				Size:     entry.Size,
+				SHA256:   entry.SHA256,
+				MD5:      entry.MD5,
+			})
+			continue
+		}
+
+		path := filepath.Join(tmpDir, file.name)
+		var err error
+		for _, url := range file.urls {
+			if err = downloadFile(ctx, url, path); err == nil {
+				break
 			}
 		}
+		if err != nil {
+			log.Printf("Failed to download %s for version %s: %v", file.name, plan.version, err)
+			continue
+		}
+		assets = append(assets, publish.Asset{Name: file.name, Path: path})
 	}
 
-	// Upload all downloaded assets to the release
-	for _, assetPath := range assetPaths {
-		fileName := filepath.Base(assetPath)
-		cmd := exec.Command("gh", "release", "upload", tagName, assetPath, "--repo", githubRepo)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			log.Printf("Failed to upload asset %s: %v (Output: %s)", fileName, err, string(output))
-			// Don't fail the whole release if asset upload fails
-		} else {
-			log.Printf("Successfully uploaded asset: %s", fileName)
+	if !plan.exists && len(assets) == 0 {
+		return fmt.Errorf("no files could be downloaded")
+	}
+
+	// Notes only matter for a release that is about to be created
+	var body string
+	if !plan.exists {
+		if body, err = render(plan.version, files); err != nil {
+			return err
+		}
+	}
+
+	// Reconcile even when nothing was downloaded, so that placeholder
+	// assets are removed
+	return publishRelease(ctx, publisher, tags, plan.version, plan.released, body, assets, m, sources, verbose)
+}
+
+// localArtifact returns the manifest entry of an artifact whose file matches
+// its recorded checksums, downloading the artifact first if there is no such
+// file
+func localArtifact(ctx context.Context, dl *downloader.Downloader, verifier *verify.Verifier, version string, p platform.PlatformInfo) (*manifest.Entry, error) {
+	m := dl.Manifest()
+	job := downloader.Job{Version: version, Platform: p.Name, Artifact: p.Artifact}
+	download := dl.DownloadJob
+	if entry := m.Find(version, p.Name, p.Artifact); entry != nil {
+		result := verifier.CheckEntry(ctx, m, entry)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
+		if result.Status == verify.StatusOK {
+			return entry, nil
+		}
+		fmt.Printf("%s is %s, downloading again\n", entry.Path, result.Status)
+		// The damaged file stays in place until its replacement is complete
+		download = dl.Redownload
 	}
 
-	return nil
+	if err := download(ctx, job); err != nil {
+		return nil, err
+	}
+	entry := m.Find(version, p.Name, p.Artifact)
+	if entry == nil {
+		return nil, fmt.Errorf("%s was not recorded in the manifest", p.Name)
+	}
+	return entry, nil
 }
 
 // downloadFile downloads a file from the given URL to the specified filepath
-func downloadFile(url, filepath string) error {
+func downloadFile(ctx context.Context, url, filepath string) error {
 	client := &http.Client{Timeout: 30 * time.Minute}
-	resp, err := client.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -62,7 +62,11 @@ func runBruteforce(cmd *cobra.Command, args []string) {
 		}
 
 		// Check if version exists
-		exists, err := det.CheckVersion(versionStr)
+		exists, err := det.CheckVersion(cmd.Context(), versionStr)
+		if cmd.Context().Err() != nil {
+			log.Println("Interrupted")
+			break
+		}
 		if err != nil {
 			log.Printf("Error checking version %s: %v\n", versionStr, err)
 			continue
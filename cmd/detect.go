@@ -1,14 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/vibe-coding-labs/qoder-downloader/internal/cache"
 	"github.com/vibe-coding-labs/qoder-downloader/internal/detector"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
 )
 
 // detectCmd represents the detect command
@@ -17,7 +20,11 @@ var detectCmd = &cobra.Command{
 	Short: "Detect available Qoder versions",
 	Long: `Detect available Qoder versions by checking the download URLs.
 This command will probe different version numbers and cache the results
-locally to avoid repeated detection.`,
+locally to avoid repeated detection.
+
+For every version found, the artifacts each platform publishes (dmg, zip,
+deb, rpm, tar.gz, ...) are checked once and recorded in
+existing_artifacts.txt; --artifacts checks them again.`,
 	Run: runDetect,
 }
 
@@ -30,6 +37,7 @@ var (
 	showStats   bool
 	cacheTTL    int64
 	specificVer string
+	recheckArt  bool
 )
 
 func init() {
@@ -48,6 +56,9 @@ func init() {
 
 	// Specific version check
 	detectCmd.Flags().StringVar(&specificVer, "version", "", "Check a specific version (e.g., 0.1.0)")
+
+	// Artifact detection
+	detectCmd.Flags().BoolVar(&recheckArt, "artifacts", false, "Re-check which artifacts found versions publish, even if already recorded")
 }
 
 func runDetect(cmd *cobra.Command, args []string) {
@@ -98,7 +109,7 @@ func runDetect(cmd *cobra.Command, args []string) {
 
 	// Check specific version if provided
 	if specificVer != "" {
-		if err := checkSpecificVersion(det, cacheManager, specificVer); err != nil {
+		if err := checkSpecificVersion(cmd.Context(), det, cacheManager, specificVer); err != nil {
 			fmt.Fprintf(os.Stderr, "Error checking version %s: %v\n", specificVer, err)
 			os.Exit(1)
 		}
@@ -106,26 +117,26 @@ func runDetect(cmd *cobra.Command, args []string) {
 	}
 
 	// Run full detection
-	if err := runFullDetection(det, cacheManager); err != nil {
+	if err := runFullDetection(cmd.Context(), det, cacheManager); err != nil {
 		fmt.Fprintf(os.Stderr, "Error during detection: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func checkSpecificVersion(det *detector.Detector, cacheManager *cache.Manager, version string) error {
+func checkSpecificVersion(ctx context.Context, det *detector.Detector, cacheManager *cache.Manager, version string) error {
 	// Check cache first
 	if requested, exists := cacheManager.Get(version); requested {
 		if exists {
 			fmt.Printf("Version %s: EXISTS (cached)\n", version)
-		} else {
-			fmt.Printf("Version %s: NOT FOUND (cached)\n", version)
+			return showArtifacts(ctx, det, cacheManager, version)
 		}
+		fmt.Printf("Version %s: NOT FOUND (cached)\n", version)
 		return nil
 	}
 
 	// Check online
 	fmt.Printf("Checking version %s...\n", version)
-	exists, err := det.CheckVersion(version)
+	exists, err := det.CheckVersion(ctx, version)
 	if err != nil {
 		return err
 	}
@@ -138,14 +149,67 @@ func checkSpecificVersion(det *detector.Detector, cacheManager *cache.Manager, v
 
 	if exists {
 		fmt.Printf("Version %s: EXISTS\n", version)
-	} else {
-		fmt.Printf("Version %s: NOT FOUND\n", version)
+		return showArtifacts(ctx, det, cacheManager, version)
 	}
+	fmt.Printf("Version %s: NOT FOUND\n", version)
 
 	return nil
 }
 
-func runFullDetection(det *detector.Detector, cacheManager *cache.Manager) error {
+// recordArtifacts checks which artifacts a version publishes unless they are
+// already recorded
+func recordArtifacts(ctx context.Context, det *detector.Detector, cacheManager *cache.Manager, version string) error {
+	if cacheManager.HasArtifacts(version) && !recheckArt {
+		return nil
+	}
+
+	artifacts, err := det.CheckArtifacts(ctx, version)
+	if err != nil {
+		return err
+	}
+	return cacheManager.SetArtifacts(version, artifacts)
+}
+
+// showArtifacts records and prints the artifacts of a version
+func showArtifacts(ctx context.Context, det *detector.Detector, cacheManager *cache.Manager, version string) error {
+	if err := recordArtifacts(ctx, det, cacheManager, version); err != nil {
+		return err
+	}
+
+	artifacts := cacheManager.GetArtifacts(version)
+	for _, p := range platform.GetAllPlatforms() {
+		names, ok := artifacts[p.Name]
+		if !ok {
+			continue
+		}
+		labels := make([]string, len(names))
+		for i, name := range names {
+			labels[i] = name
+			if rule := cacheManager.GetArtifactRule(version, p.Name, name); rule != platform.DefaultRule {
+				labels[i] = fmt.Sprintf("%s (rule %s)", name, rule)
+			}
+		}
+		fmt.Printf("  %-15s %s\n", p.Name, strings.Join(labels, ", "))
+	}
+	return nil
+}
+
+// artifactURL returns the URL an artifact was found under by the given rule
+func artifactURL(version string, p platform.PlatformInfo, name, rule string) string {
+	variant, ok := p.WithArtifact(name)
+	if !ok {
+		return ""
+	}
+	candidates := platform.DownloadCandidates(version, variant)
+	for _, candidate := range candidates {
+		if candidate.Rule == rule {
+			return candidate.URL
+		}
+	}
+	return candidates[0].URL
+}
+
+func runFullDetection(ctx context.Context, det *detector.Detector, cacheManager *cache.Manager) error {
 	fmt.Printf("Starting version detection (max: %d.%d.%d)...\n", maxMajor, maxMinor, maxPatch)
 	start := time.Now()
 
@@ -175,7 +239,11 @@ func runFullDetection(det *detector.Detector, cacheManager *cache.Manager) error
 		}
 
 		// Check online
-		exists, err := det.CheckVersion(candidate)
+		exists, err := det.CheckVersion(ctx, candidate)
+		if ctx.Err() != nil {
+			fmt.Fprintf(os.Stderr, "\nInterrupted after checking %d versions\n", checked)
+			return ctx.Err()
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "\nError checking %s: %v\n", candidate, err)
 			continue
@@ -228,19 +296,27 @@ func runFullDetection(det *detector.Detector, cacheManager *cache.Manager) error
 
 	fmt.Printf("\nLatest version: %s\n", foundVersions[len(foundVersions)-1].String())
 
-	// Show download URL for latest version
-	latestVersion := foundVersions[len(foundVersions)-1].String()
-	fmt.Printf("\nDownload URLs for %s:\n", latestVersion)
-	patterns := []string{
-		"Qoder-darwin-arm64.dmg",
-		"Qoder-darwin-x64.dmg",
-		"Qoder-linux-x64.tar.gz",
-		"Qoder-win32-x64.zip",
+	// Record which artifacts each found version publishes
+	for _, version := range foundVersions {
+		if err := recordArtifacts(ctx, det, cacheManager, version.String()); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "Warning: failed to check artifacts of %s: %v\n", version, err)
+		}
 	}
 
-	for _, pattern := range patterns {
-		url := fmt.Sprintf("https://download.qoder.com/release/%s/%s", latestVersion, pattern)
-		fmt.Printf("  %s\n", url)
+	// Show download URLs for latest version
+	latestVersion := foundVersions[len(foundVersions)-1].String()
+	fmt.Printf("\nDownload URLs for %s:\n", latestVersion)
+	artifacts := cacheManager.GetArtifacts(latestVersion)
+	for _, p := range platform.GetAllPlatforms() {
+		for _, name := range artifacts[p.Name] {
+			rule := cacheManager.GetArtifactRule(latestVersion, p.Name, name)
+			if url := artifactURL(latestVersion, p, name, rule); url != "" {
+				fmt.Printf("  %s\n", url)
+			}
+		}
 	}
 
 	return nil
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/discovery"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/retention"
+)
+
+var discoverCmd = &cobra.Command{
+	Use:   "discover-artifacts <version>",
+	Short: "Probe filename variants to find artifacts the registry does not know",
+	Long: `Build candidate filenames from every combination of the configured products,
+OS names, architectures and extensions, and report which ones upstream serves
+for a version, with their sizes. Files the platform registry already produces
+are marked as known.
+
+The name parts come from the "discovery" section of the config file:
+
+  discovery:
+    products: [Qoder, QoderSetup, QoderUserSetup]
+    oses: [darwin, linux, win32]
+    archs: [x64, arm64, universal, ia32]
+    extensions: [dmg, zip, exe, msi, AppImage, deb, rpm, tar.gz]
+    patterns: ["{product}-{os}-{arch}.{ext}", "{product}-{arch}.{ext}"]
+
+With --promote, new files are added to the registry file ("registry_file" in
+the config file, or --registry-file) so that detection and downloads include
+them from then on. The file is rewritten, so comments in it are not kept.
+
+Examples:
+  # See what upstream publishes for a version
+  qoder-downloader discover-artifacts 0.2.1
+
+  # Add what was found to the local registry
+  qoder-downloader discover-artifacts 0.2.1 --promote --registry-file ~/.qoder-platforms.yaml`,
+	Args: cobra.ExactArgs(1),
+	Run:  runDiscover,
+}
+
+var (
+	discoverPromote      bool
+	discoverRegistryFile string
+	discoverJobs         int
+	discoverJSON         bool
+)
+
+func init() {
+	rootCmd.AddCommand(discoverCmd)
+	discoverCmd.Flags().BoolVar(&discoverPromote, "promote", false, "Add newly found artifacts to the registry file")
+	discoverCmd.Flags().StringVar(&discoverRegistryFile, "registry-file", "", "Registry file to promote into (default: registry_file from the config)")
+	discoverCmd.Flags().IntVarP(&discoverJobs, "jobs", "j", 8, "Number of concurrent requests")
+	discoverCmd.Flags().BoolVar(&discoverJSON, "json", false, "Write the artifacts found as JSON")
+}
+
+func runDiscover(cmd *cobra.Command, args []string) {
+	version := args[0]
+
+	var config discovery.Config
+	if err := viper.UnmarshalKey("discovery", &config); err != nil {
+		log.Fatalf("Invalid discovery section in config: %v", err)
+	}
+	config = config.Merge(discovery.DefaultConfig())
+
+	registry := platform.ActiveRegistry()
+	probes := config.Probes(version, registry)
+	if !discoverJSON {
+		fmt.Printf("Probing %d candidate files for version %s...\n", len(probes), version)
+	}
+
+	found, checkErr := discovery.Check(cmd.Context(), probes, discoverJobs)
+	if cmd.Context().Err() != nil {
+		fmt.Println("Interrupted")
+		os.Exit(130)
+	}
+
+	if discoverJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(found); err != nil {
+			log.Fatalf("Failed to encode results: %v", err)
+		}
+	} else {
+		printDiscovered(found)
+	}
+
+	// What was found is still listed, but an incomplete run is not promoted
+	if checkErr != nil {
+		log.Fatalf("Discovery failed: %v", checkErr)
+	}
+	if !discoverPromote {
+		return
+	}
+
+	path := discoverRegistryFile
+	if path == "" {
+		path = viper.GetString("registry_file")
+	}
+	if path == "" {
+		log.Fatalf("No registry file to promote into: set registry_file in your config file or pass --registry-file")
+	}
+	if err := promoteDiscovered(path, registry, version, found); err != nil {
+		log.Fatalf("Failed to promote artifacts: %v", err)
+	}
+}
+
+func printDiscovered(found []discovery.Found) {
+	if len(found) == 0 {
+		fmt.Println("No artifacts found")
+		return
+	}
+
+	newCount := 0
+	fmt.Printf("%-40s %-12s %s\n", "FILENAME", "SIZE", "STATUS")
+	for _, f := range found {
+		size := "unknown"
+		if f.Size >= 0 {
+			size = retention.FormatSize(f.Size)
+		}
+		status := "known"
+		if !f.Known {
+			status = "new"
+			newCount++
+		}
+		fmt.Printf("%-40s %-12s %s\n", f.Filename, size, status)
+	}
+	fmt.Printf("\n%d found, %d not in the platform registry\n", len(found), newCount)
+}
+
+// promoteDiscovered records new artifacts in the registry file at path
+func promoteDiscovered(path string, registry *platform.Registry, version string, found []discovery.Found) error {
+	changes, err := discovery.PromoteFile(path, registry, version, found)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("Nothing new to promote")
+		return nil
+	}
+
+	fmt.Printf("Promoted into %s:\n  %s\n", path, strings.Join(changes, "\n  "))
+	if viper.GetString("registry_file") != path {
+		fmt.Printf("Set \"registry_file: %s\" in your config file to use it\n", path)
+	}
+	return nil
+}
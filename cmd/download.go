@@ -3,11 +3,11 @@ package cmd
 import (
 	"fmt"
 	"log"
-	"runtime"
 
 	"github.com/spf13/cobra"
 	"github.com/vibe-coding-labs/qoder-downloader/internal/cache"
 	"github.com/vibe-coding-labs/qoder-downloader/internal/downloader"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
 )
 
 var downloadCmd = &cobra.Command{
@@ -22,14 +22,18 @@ var (
 	downloadPlatform string
 	downloadAll      bool
 	outputDir        string
+	downloadRefresh  bool
+	downloadArtifact string
 )
 
 func init() {
 	rootCmd.AddCommand(downloadCmd)
 	downloadCmd.Flags().StringVar(&downloadVersion, "version", "", "Specific version to download (e.g., 0.1.21)")
-	downloadCmd.Flags().StringVarP(&downloadPlatform, "platform", "p", "", "Platform to download (darwin-arm64, darwin-x64, linux-x64, windows-x64)")
+	downloadCmd.Flags().StringVarP(&downloadPlatform, "platform", "p", "", "Platform to download, e.g. darwin-arm64, win32-x64 or an alias like macos-arm (default: this host)")
 	downloadCmd.Flags().BoolVarP(&downloadAll, "all", "a", false, "Download all existing versions")
 	downloadCmd.Flags().StringVarP(&outputDir, "output", "o", "./downloads", "Output directory for downloads")
+	downloadCmd.Flags().BoolVar(&downloadRefresh, "refresh", false, "Re-check existing downloads and fetch them again if upstream changed")
+	downloadCmd.Flags().StringVar(&downloadArtifact, "artifact", "", "Comma-separated artifacts to download instead of the platform default (e.g. deb,tar.gz)")
 }
 
 func runDownload(cmd *cobra.Command, args []string) {
@@ -42,15 +46,30 @@ func runDownload(cmd *cobra.Command, args []string) {
 	}
 	
 	// Initialize downloader
-	dl := downloader.NewDownloader(verbose, outputDir)
+	dl, err := downloader.NewDownloader(verbose, outputDir)
+	if err != nil {
+		log.Fatalf("Failed to create downloader: %v", err)
+	}
+	dl.SetRefresh(downloadRefresh)
+	layout, err := artifactLayout()
+	if err != nil {
+		log.Fatalf("Invalid layout: %v", err)
+	}
+	dl.SetLayout(layout)
 	
 	// Determine platform
-	platform := downloadPlatform
-	if platform == "" {
-		platform = getCurrentPlatform()
-		if verbose {
-			fmt.Printf("Auto-detected platform: %s\n", platform)
-		}
+	platformInfo, err := platform.Resolve(downloadPlatform)
+	if err != nil {
+		log.Fatalf("Invalid platform: %v", err)
+	}
+	if downloadPlatform == "" && verbose {
+		fmt.Printf("Auto-detected platform: %s\n", platformInfo.Name)
+	}
+	platformName := platformInfo.Name
+	
+	artifacts, err := parseArtifacts(downloadArtifact, []string{platformName})
+	if err != nil {
+		log.Fatalf("Invalid --artifact: %v", err)
 	}
 	
 	// Load cache
@@ -68,43 +87,24 @@ func runDownload(cmd *cobra.Command, args []string) {
 			return
 		}
 		
-		fmt.Printf("Downloading %d versions for platform %s...\n", len(existingVersions), platform)
+		fmt.Printf("Downloading %d versions for platform %s...\n", len(existingVersions), platformName)
 		
-		err = dl.DownloadAllVersions(existingVersions, platform)
+		err = dl.DownloadJobs(cmd.Context(), downloader.Jobs(existingVersions, []string{platformName}, artifacts))
 		if err != nil {
 			log.Fatalf("Failed to download versions: %v", err)
 		}
 	} else if downloadVersion != "" {
 		// Download specific version
-		fmt.Printf("Downloading version %s for platform %s...\n", downloadVersion, platform)
+		fmt.Printf("Downloading version %s for platform %s...\n", downloadVersion, platformName)
 		
-		err := dl.DownloadVersion(downloadVersion, platform)
-		if err != nil {
-			log.Fatalf("Failed to download %s: %v", downloadVersion, err)
-		} else {
-			fmt.Printf("Successfully downloaded %s\n", downloadVersion)
+		for _, job := range downloader.Jobs([]string{downloadVersion}, []string{platformName}, artifacts) {
+			if err := dl.DownloadJob(cmd.Context(), job); err != nil {
+				log.Fatalf("Failed to download %s for %s: %v", downloadVersion, job.Label(), err)
+			}
 		}
+		fmt.Printf("Successfully downloaded %s\n", downloadVersion)
 	} else {
 		fmt.Println("Please specify either --version or --all flag")
 		cmd.Help()
 	}
 }
-
-func getCurrentPlatform() string {
-	goos := runtime.GOOS
-	goarch := runtime.GOARCH
-	
-	switch goos {
-	case "darwin":
-		if goarch == "arm64" {
-			return "darwin-arm64"
-		}
-		return "darwin-x64"
-	case "linux":
-		return "linux-x64"
-	case "windows":
-		return "windows-x64"
-	default:
-		return "darwin-arm64" // fallback
-	}
-}
\ No newline at end of file
@@ -3,11 +3,14 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/vibe-coding-labs/qoder-downloader/internal/cache"
 	"github.com/vibe-coding-labs/qoder-downloader/internal/downloader"
 	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/retention"
 )
 
 // downloadAllCmd represents the download-all command
@@ -17,10 +20,12 @@ var downloadAllCmd = &cobra.Command{
 	Long: `Download all available versions of Qoder for all supported platforms.
 
 This command will:
+- Check upstream sizes and free disk space before starting
 - Download all versions for all platforms (macOS, Windows, Linux)
 - Support different file formats (dmg, exe, AppImage)
 - Create organized directory structure by version
 - Show detailed progress information
+- Stop cleanly on Ctrl-C and resume the remaining jobs on the next run with the same flags
 
 Examples:
   # Download all versions for all platforms
@@ -32,23 +37,41 @@ Examples:
   # Download all versions for specific platform
   qoder-downloader download-all --platform darwin-arm64
   
+  # Download the Debian and tarball packages instead of the AppImages
+  qoder-downloader download-all --platform linux-x64 --artifact deb,tar.gz
+  
   # Download with verbose output
   qoder-downloader download-all --verbose
   
   # List available platforms
-  qoder-downloader download-all --list-platforms`,
+  qoder-downloader download-all --list-platforms
+  
+  # Show how much would be downloaded without starting
+  qoder-downloader download-all --plan
+  
+  # Pick up builds that upstream republished under the same version
+  qoder-downloader download-all --refresh
+  
+  # Download everything, then apply the retention policy from the config file
+  qoder-downloader download-all --prune`,
 	Run: func(cmd *cobra.Command, args []string) {
 		verbose, _ := cmd.Flags().GetBool("verbose")
 		version, _ := cmd.Flags().GetString("version")
 		platformName, _ := cmd.Flags().GetString("platform")
 		listPlatforms, _ := cmd.Flags().GetBool("list-platforms")
 		outputDir, _ := cmd.Flags().GetString("output")
+		prune, _ := cmd.Flags().GetBool("prune")
+		refresh, _ := cmd.Flags().GetBool("refresh")
+		planOnly, _ := cmd.Flags().GetBool("plan")
+		force, _ := cmd.Flags().GetBool("force")
+		restart, _ := cmd.Flags().GetBool("restart")
+		artifactList, _ := cmd.Flags().GetString("artifact")
 
 		// Handle list platforms flag
 		if listPlatforms {
 			fmt.Println("Available platforms:")
 			for _, p := range platform.GetAllPlatforms() {
-				fmt.Printf("  %-15s (%s, %s)\n", p.Name, p.OS, p.Extension)
+				fmt.Printf("  %-15s (%s, %s)\n", p.Name, p.OS, strings.Join(p.ArtifactNames(), ", "))
 			}
 			return
 		}
@@ -67,43 +90,116 @@ Examples:
 		}
 
 		// Initialize downloader
-		downloaderInstance := downloader.NewDownloader(verbose, outputDir)
+		downloaderInstance, err := downloader.NewDownloader(verbose, outputDir)
+		if err != nil {
+			fmt.Printf("Failed to create downloader: %v\n", err)
+			os.Exit(1)
+		}
+		downloaderInstance.SetRefresh(refresh)
+		layout, err := artifactLayout()
+		if err != nil {
+			fmt.Printf("Invalid layout: %v\n", err)
+			os.Exit(1)
+		}
+		downloaderInstance.SetLayout(layout)
 
-		// Determine what to download based on flags
-		if version != "" && platformName != "" {
-			// Download specific version for specific platform
-			if verbose {
-				fmt.Printf("Downloading version %s for platform %s\n", version, platformName)
-			}
-			err = downloaderInstance.DownloadVersion(version, platformName)
-		} else if version != "" {
-			// Download specific version for all platforms
-			if verbose {
-				fmt.Printf("Downloading version %s for all platforms\n", version)
-			}
-			err = downloaderInstance.DownloadAllPlatforms(version)
-		} else if platformName != "" {
-			// Download all versions for specific platform
-			versions := cacheManager.GetExistingVersions()
-			if len(versions) == 0 {
-				fmt.Println("No versions found. Please run 'qoder-downloader list' first to discover versions.")
+		// The flags select the jobs; an interrupted batch resumes only for the same selection
+		selection := downloader.Selection{Version: version}
+		platformNames := platform.GetPlatformNames()
+		if platformName != "" {
+			platformInfo, err := platform.Resolve(platformName)
+			if err != nil {
+				fmt.Printf("Invalid platform: %v\n", err)
 				os.Exit(1)
 			}
-			if verbose {
-				fmt.Printf("Downloading all %d versions for platform %s\n", len(versions), platformName)
+			platformNames = []string{platformInfo.Name}
+			selection.Platform = platformInfo.Name
+		}
+		artifacts, err := parseArtifacts(artifactList, platformNames)
+		if err != nil {
+			fmt.Printf("Invalid --artifact: %v\n", err)
+			os.Exit(1)
+		}
+		sorted := append([]string(nil), artifacts...)
+		sort.Strings(sorted)
+		selection.Artifacts = strings.Join(sorted, ",")
+		downloaderInstance.SetSelection(selection)
+
+		// Continue an interrupted batch unless asked to start over
+		state, err := downloaderInstance.LoadState()
+		if err != nil {
+			fmt.Printf("Failed to load batch state: %v\n", err)
+			os.Exit(1)
+		}
+		if state != nil && restart {
+			if err := downloaderInstance.ClearState(); err != nil {
+				fmt.Printf("Failed to remove batch state: %v\n", err)
+				os.Exit(1)
 			}
-			err = downloaderInstance.DownloadAllVersions(versions, platformName)
+			state = nil
+		}
+		if state != nil && state.Selection != selection {
+			fmt.Printf("An interrupted batch (%s) is saved in %s, but this run selects %s.\n",
+				state.Selection, downloaderInstance.StatePath(), selection)
+			fmt.Println("Run download-all with the flags of that batch to resume it, or use --restart to discard it.")
+			os.Exit(1)
+		}
+
+		var jobs []downloader.Job
+		if state != nil {
+			fmt.Printf("Resuming interrupted batch from %s: %d jobs left (use --restart to discard)\n",
+				state.SavedAt.Local().Format("2006-01-02 15:04:05"), len(state.Jobs))
+			jobs = state.Jobs
 		} else {
-			// Download all versions for all platforms
-			versions := cacheManager.GetExistingVersions()
-			if len(versions) == 0 {
-				fmt.Println("No versions found. Please run 'qoder-downloader list' first to discover versions.")
-				os.Exit(1)
+			// Work out the full job list so it can be planned before anything is fetched
+			var versions []string
+			if version != "" {
+				versions = []string{version}
+			} else {
+				versions = cacheManager.GetExistingVersions()
+				if len(versions) == 0 {
+					fmt.Println("No versions found. Please run 'qoder-downloader list' first to discover versions.")
+					os.Exit(1)
+				}
 			}
-			if verbose {
-				fmt.Printf("Downloading all %d versions for all platforms\n", len(versions))
+			jobs = downloader.Jobs(versions, platformNames, artifacts)
+		}
+
+		ctx := cmd.Context()
+		plan, err := downloaderInstance.Plan(ctx, jobs)
+		if ctx.Err() != nil {
+			// Keep the queue so that the next run resumes it like any other interrupted batch
+			if !planOnly {
+				if err := downloaderInstance.SaveQueue(jobs); err != nil {
+					fmt.Printf("Interrupted, and failed to save the queue: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("\nInterrupted while planning: %d jobs saved to %s\n", len(jobs), downloaderInstance.StatePath())
+				fmt.Println("Run download-all again to continue.")
 			}
-			err = downloaderInstance.DownloadAllVersionsAllPlatforms(versions)
+			os.Exit(130)
+		}
+		if err != nil {
+			fmt.Printf("Failed to plan downloads: %v\n", err)
+			os.Exit(1)
+		}
+		printDownloadPlan(plan, outputDir)
+		if planOnly {
+			return
+		}
+		if !plan.Fits() {
+			if !force {
+				fmt.Printf("Not enough free space in %s: need %s, %s available. Use --force to start anyway.\n",
+					outputDir, retention.FormatSize(plan.NeededBytes), retention.FormatSize(int64(plan.FreeBytes)))
+				os.Exit(1)
+			}
+			fmt.Println("Warning: not enough free space, continuing because of --force")
+		}
+
+		err = downloaderInstance.DownloadJobs(ctx, jobs)
+		if ctx.Err() != nil {
+			fmt.Println("Run download-all again to continue.")
+			os.Exit(130)
 		}
 
 		if err != nil {
@@ -114,6 +210,23 @@ Examples:
 		if verbose {
 			fmt.Println("\nAll downloads completed successfully!")
 		}
+
+		// Apply the configured retention policy once everything is in place
+		if prune {
+			policy, err := retentionPolicy(cmd)
+			if err != nil {
+				fmt.Printf("Invalid retention policy: %v\n", err)
+				os.Exit(1)
+			}
+			if policy.IsEmpty() {
+				fmt.Println("No retention policy configured, skipping prune")
+				return
+			}
+			if err := pruneDownloads(outputDir, policy, false, verbose); err != nil {
+				fmt.Printf("Prune failed: %v\n", err)
+				os.Exit(1)
+			}
+		}
 	},
 }
 
@@ -122,8 +235,47 @@ func init() {
 
 	// Add flags
 	downloadAllCmd.Flags().StringP("version", "v", "", "Download specific version (if not specified, downloads all versions)")
-	downloadAllCmd.Flags().StringP("platform", "p", "", "Download for specific platform (if not specified, downloads all platforms)")
+	downloadAllCmd.Flags().StringP("platform", "p", "", "Download for specific platform or alias such as linux-arm64 or aarch64 (if not specified, downloads all platforms)")
 	downloadAllCmd.Flags().BoolP("list-platforms", "l", false, "List all available platforms")
 	downloadAllCmd.Flags().StringP("output", "o", "downloads", "Output directory for downloads")
 	downloadAllCmd.Flags().BoolP("verbose", "", false, "Enable verbose output")
-}
\ No newline at end of file
+	downloadAllCmd.Flags().Bool("plan", false, "Show the download plan and exit")
+	downloadAllCmd.Flags().Bool("force", false, "Start even if the output volume does not have enough free space")
+	downloadAllCmd.Flags().Bool("restart", false, "Discard the state of an interrupted batch instead of resuming it")
+	downloadAllCmd.Flags().Bool("refresh", false, "Re-check existing downloads with conditional requests and fetch changed files")
+	downloadAllCmd.Flags().Bool("prune", false, "Apply the configured retention policy after downloading")
+	downloadAllCmd.Flags().String("artifact", "", "Comma-separated artifacts to download instead of each platform's default (e.g. deb,tar.gz,zip)")
+}
+// printDownloadPlan shows what a download-all run will fetch and whether it fits on disk
+func printDownloadPlan(plan *downloader.Plan, outputDir string) {
+	fmt.Printf("Download plan:\n")
+	fmt.Printf("  %-10s %-22s %-12s %s\n", "VERSION", "PLATFORM", "SIZE", "STATUS")
+	for _, item := range plan.Items {
+		size := "unknown"
+		if item.RemoteSize >= 0 {
+			size = retention.FormatSize(item.RemoteSize)
+		}
+		status := item.Status
+		if item.Err != nil {
+			status = fmt.Sprintf("%s (%v)", status, item.Err)
+		}
+		fmt.Printf("  %-10s %-22s %-12s %s\n", item.Version, item.Label(), size, status)
+	}
+
+	fmt.Printf("\n%d to download, %d already present, %d unavailable\n",
+		plan.Count(downloader.PlanDownload), plan.Count(downloader.PlanPresent), plan.Count(downloader.PlanUnavailable))
+	if failed := plan.Count(downloader.PlanFailed); failed > 0 {
+		fmt.Printf("%d could not be checked upstream and will be tried anyway\n", failed)
+	}
+	fmt.Printf("Total upstream size: %s\n", retention.FormatSize(plan.TotalBytes))
+	fmt.Printf("Still to download:   %s", retention.FormatSize(plan.NeededBytes))
+	if plan.UnknownSize > 0 {
+		fmt.Printf(" (+%d files of unknown size)", plan.UnknownSize)
+	}
+	fmt.Println()
+	if plan.FreeErr != nil {
+		fmt.Printf("Free space in %s: unknown (%v)\n\n", outputDir, plan.FreeErr)
+	} else {
+		fmt.Printf("Free space in %s: %s\n\n", outputDir, retention.FormatSize(int64(plan.FreeBytes)))
+	}
+}
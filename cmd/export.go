@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/cache"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/chunks"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/detector"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/publish"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/sitedata"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export version data for other tools",
+}
+
+var exportSiteDataCmd = &cobra.Command{
+	Use:   "site-data",
+	Short: "Write the version data file the website is built from",
+	Long: `Write every known version with its platforms, artifacts, upstream URLs and
+release date to a JSON file for the website. Sizes, checksums and GitHub
+release links are filled in for files recorded in the download manifest.
+The JSON Schema of the file is written next to it.
+
+Examples:
+  # Update the data file of the webapp
+  qoder-downloader export site-data
+
+  # Write it somewhere else, linking to another repository's releases
+  qoder-downloader export site-data -o site/versions.json --repo me/qoder-mirror`,
+	Run: runExportSiteData,
+}
+
+var (
+	exportDir    string
+	exportOutput string
+	exportSchema string
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.AddCommand(exportSiteDataCmd)
+	exportSiteDataCmd.Flags().StringVarP(&exportDir, "downloads", "d", "./downloads", "Downloads directory")
+	exportSiteDataCmd.Flags().StringVarP(&exportOutput, "output", "o", filepath.Join("webapp", "public", sitedata.FileName), "Data file to write")
+	exportSiteDataCmd.Flags().StringVar(&exportSchema, "schema", "", "JSON Schema file to write (default: "+sitedata.SchemaFileName+" next to the data file)")
+	exportSiteDataCmd.Flags().StringVar(&githubRepo, "repo", defaultGitHubRepo, "GitHub repository release links point to (owner/repo)")
+	exportSiteDataCmd.Flags().StringVar(&githubAPIURL, "api-url", "", "GitHub API base URL, for GitHub Enterprise (default: github.api_url from the config or api.github.com)")
+}
+
+func runExportSiteData(cmd *cobra.Command, args []string) {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	cacheManager, err := cache.NewManager(".", verbose, 24)
+	if err != nil {
+		log.Fatalf("Failed to create cache manager: %v", err)
+	}
+	if err := cacheManager.Load(); err != nil {
+		log.Fatalf("Failed to load cache: %v", err)
+	}
+	m, err := manifest.Load(exportDir)
+	if err != nil {
+		log.Fatalf("Failed to load manifest: %v", err)
+	}
+	assetNames, err := assetNameLayout()
+	if err != nil {
+		log.Fatalf("Invalid asset name template: %v", err)
+	}
+	// Only used to build links, so no request is made
+	github, err := publish.NewGitHub(cmd.Context(), githubOptions(cmd))
+	if err != nil {
+		log.Fatalf("Failed to set up GitHub links: %v", err)
+	}
+
+	schemaPath := exportSchema
+	if schemaPath == "" {
+		schemaPath = filepath.Join(filepath.Dir(exportOutput), sitedata.SchemaFileName)
+	}
+	data := siteData(cacheManager, m, assetNames, github)
+	if rel, err := filepath.Rel(filepath.Dir(exportOutput), schemaPath); err == nil {
+		data.Schema = filepath.ToSlash(rel)
+	}
+
+	encoded, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode site data: %v", err)
+	}
+	if err := writeFileAtomic(exportOutput, append(encoded, '\n')); err != nil {
+		log.Fatalf("Failed to write site data: %v", err)
+	}
+	if err := writeFileAtomic(schemaPath, sitedata.Schema); err != nil {
+		log.Fatalf("Failed to write schema: %v", err)
+	}
+
+	archived, released := 0, 0
+	for _, v := range data.Versions {
+		if len(m.ForVersion(v.Version)) > 0 {
+			archived++
+		}
+		if v.Release != nil {
+			released++
+		}
+	}
+	fmt.Printf("Wrote %d versions (%d archived, %d released) to %s\n", len(data.Versions), archived, released, exportOutput)
+	fmt.Printf("Wrote schema to %s\n", schemaPath)
+}
+
+// siteData collects every version known to the cache or the manifest, newest
+// first
+func siteData(cacheManager *cache.Manager, m *manifest.Manifest, assetNames *platform.Layout, github *publish.GitHub) *sitedata.Data {
+	seen := make(map[string]bool)
+	var versions []string
+	for _, v := range cacheManager.GetValidVersions() {
+		seen[v.Raw] = true
+		versions = append(versions, v.Raw)
+	}
+	for _, version := range m.Versions() {
+		if !seen[version] {
+			seen[version] = true
+			versions = append(versions, version)
+		}
+	}
+	detector.SortVersionStrings(versions)
+
+	data := &sitedata.Data{
+		SchemaVersion: sitedata.SchemaVersion,
+		GeneratedAt:   time.Now().UTC(),
+		Repo:          github.Repo(),
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := siteVersion(cacheManager, m, assetNames, github, versions[i])
+		if data.Latest == "" && !v.Prerelease {
+			data.Latest = v.Version
+		}
+		data.Versions = append(data.Versions, v)
+	}
+	return data
+}
+
+// siteVersion describes one version. Artifacts the cache recorded are listed
+// with their upstream URL, and archived ones also with their size, checksums
+// and release asset.
+func siteVersion(cacheManager *cache.Manager, m *manifest.Manifest, assetNames *platform.Layout, github *publish.GitHub, version string) *sitedata.Version {
+	v := &sitedata.Version{Version: version, Platforms: []*sitedata.Platform{}}
+	if parsed, err := detector.ParseVersion(version); err == nil {
+		v.Prerelease = parsed.Prerelease()
+	}
+	if t := firstSeen(cacheManager, m, version); !t.IsZero() {
+		v.ReleaseDate = &t
+	}
+
+	cached := cacheManager.GetArtifacts(version)
+	for _, p := range platform.GetAllPlatforms() {
+		found := make(map[string]bool)
+		for _, name := range cached[p.Name] {
+			found[name] = true
+		}
+		for _, entry := range m.ForVersion(version) {
+			if entry.Platform != p.Name {
+				continue
+			}
+			if entry.Artifact == "" {
+				found[p.Extension] = true
+			} else {
+				found[entry.Artifact] = true
+			}
+		}
+
+		sp := &sitedata.Platform{Name: p.Name, OS: p.OS, Arch: p.Arch}
+		for _, name := range p.ArtifactNames() {
+			if !found[name] {
+				continue
+			}
+			variant, ok := p.WithArtifact(name)
+			if !ok {
+				continue
+			}
+			a := &sitedata.Artifact{
+				Name:      name,
+				Primary:   name == p.Extension,
+				Extension: variant.Extension,
+				FileName:  assetNames.Base(version, variant),
+			}
+
+			artifact := name
+			if a.Primary {
+				artifact = ""
+			}
+			entry := m.Find(version, p.Name, artifact)
+			if entry == nil {
+				a.UpstreamURL = artifactURL(version, p, name, cacheManager.GetArtifactRule(version, p.Name, name))
+				sp.Artifacts = append(sp.Artifacts, a)
+				continue
+			}
+			a.UpstreamURL, a.Size, a.MD5, a.SHA256 = entry.URL, entry.Size, entry.MD5, entry.SHA256
+			for _, r := range entry.Releases {
+				if r.ForgeName() != github.Forge() || r.Repo != github.Repo() {
+					continue
+				}
+				a.DownloadURL = github.AssetURL(r.Tag, r.Asset)
+				a.Split = strings.HasSuffix(r.Asset, chunks.ManifestSuffix)
+				if v.Release == nil {
+					v.Release = &sitedata.Release{Tag: r.Tag, URL: github.ReleaseURL(r.Tag)}
+				}
+			}
+			sp.Artifacts = append(sp.Artifacts, a)
+		}
+		if len(sp.Artifacts) > 0 {
+			v.Platforms = append(v.Platforms, sp)
+		}
+	}
+	return v
+}
+
+// writeFileAtomic replaces a file, creating its directory, so that readers
+// never see it half written
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/publish"
+)
+
+// Default repository releases are published to
+const defaultGitHubRepo = "vibe-coding-labs/qoder-downloader"
+
+// Default directory for cached GitHub API responses
+const defaultGitHubCacheDir = ".github-cache"
+
+var (
+	githubToken  string
+	githubRepo   string
+	githubAPIURL string
+)
+
+// addGitHubFlags adds the flags selecting the repository to publish to
+func addGitHubFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&githubToken, "token", "", "GitHub token (default: github.token from the config, $GITHUB_TOKEN or $GH_TOKEN)")
+	cmd.Flags().StringVar(&githubRepo, "repo", defaultGitHubRepo, "GitHub repository (owner/repo)")
+	cmd.Flags().StringVar(&githubAPIURL, "api-url", "", "GitHub API base URL, for GitHub Enterprise (default: github.api_url from the config or api.github.com)")
+}
+
+// githubOptions combines the GitHub flags with the "github" config section
+// and the environment. Flags win over the config file, which wins over the
+// environment.
+func githubOptions(cmd *cobra.Command) publish.Options {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	opts := publish.Options{
+		Token:     githubToken,
+		Repo:      githubRepo,
+		APIURL:    githubAPIURL,
+		UploadURL: viper.GetString("github.upload_url"),
+		CacheDir:  defaultGitHubCacheDir,
+		Verbose:   verbose,
+	}
+	if viper.IsSet("github.cache_dir") {
+		// An empty value turns caching off
+		opts.CacheDir = viper.GetString("github.cache_dir")
+	}
+
+	if opts.Token == "" {
+		opts.Token = viper.GetString("github.token")
+	}
+	for _, env := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if opts.Token == "" {
+			opts.Token = os.Getenv(env)
+		}
+	}
+	if !cmd.Flags().Changed("repo") && viper.GetString("github.repo") != "" {
+		opts.Repo = viper.GetString("github.repo")
+	}
+	if opts.APIURL == "" {
+		opts.APIURL = viper.GetString("github.api_url")
+	}
+	return opts
+}
+
+// releaseTags returns the tag scheme configured as release.tag_format
+func releaseTags() publish.TagScheme {
+	scheme, err := publish.NewTagScheme(viper.GetString("release.tag_format"))
+	if err != nil {
+		log.Fatalf("Invalid release.tag_format in config: %v", err)
+	}
+	return scheme
+}
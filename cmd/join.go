@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/chunks"
+)
+
+var joinCmd = &cobra.Command{
+	Use:   "join <name>.parts.json",
+	Short: "Reassemble a release asset that was uploaded in parts",
+	Long: `Reassemble a file that was too large for a forge and was attached to the
+release as numbered parts (NAME.001, NAME.002, ...) with a NAME.parts.json
+manifest. Download the parts and the manifest into one directory, then pass
+the manifest. Every part and the joined file are checked against the sizes
+and SHA-256 checksums the manifest records; the joined file is only written
+when they all match.
+
+Examples:
+  # Join into the directory holding the parts
+  qoder-downloader join Qoder-darwin-arm64-0.2.1.dmg.parts.json
+
+  # Write the file elsewhere and delete the parts afterwards
+  qoder-downloader join ~/Downloads/Qoder-darwin-arm64-0.2.1.dmg.parts.json -o /tmp/Qoder.dmg --remove-parts`,
+	Args: cobra.ExactArgs(1),
+	Run:  runJoin,
+}
+
+var (
+	joinOutput      string
+	joinRemoveParts bool
+)
+
+func init() {
+	rootCmd.AddCommand(joinCmd)
+	joinCmd.Flags().StringVarP(&joinOutput, "output", "o", "", "Where to write the joined file (default: its original name next to the parts)")
+	joinCmd.Flags().BoolVar(&joinRemoveParts, "remove-parts", false, "Delete the parts and the manifest once the file is joined")
+}
+
+func runJoin(cmd *cobra.Command, args []string) {
+	manifestPath := args[0]
+	if !strings.HasSuffix(manifestPath, chunks.ManifestSuffix) {
+		log.Fatalf("%s is not a parts manifest (%s)", manifestPath, chunks.ManifestSuffix)
+	}
+	m, err := chunks.Load(manifestPath)
+	if err != nil {
+		log.Fatalf("Failed to read manifest: %v", err)
+	}
+
+	dir := filepath.Dir(manifestPath)
+	output := joinOutput
+	if output == "" {
+		output = filepath.Join(dir, m.Name)
+	}
+	if _, err := os.Stat(output); err == nil {
+		log.Fatalf("%s already exists", output)
+	}
+
+	fmt.Printf("Joining %d parts into %s...\n", len(m.Parts), output)
+	if err := chunks.Join(m, dir, output); err != nil {
+		log.Fatalf("Failed to join %s: %v", m.Name, err)
+	}
+	fmt.Printf("OK %s (%d bytes, SHA-256 %s)\n", output, m.Size, m.SHA256)
+
+	if !joinRemoveParts {
+		return
+	}
+	for _, part := range m.Parts {
+		if err := os.Remove(filepath.Join(dir, part.Name)); err != nil {
+			log.Printf("Failed to remove %s: %v", part.Name, err)
+		}
+	}
+	if err := os.Remove(manifestPath); err != nil {
+		log.Printf("Failed to remove %s: %v", manifestPath, err)
+	}
+}
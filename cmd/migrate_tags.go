@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/detector"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/publish"
+)
+
+var migrateTagsCmd = &cobra.Command{
+	Use:   "migrate-tags",
+	Short: "Move existing releases to the configured tag format",
+	Long: `Find releases tagged with an earlier tag format (such as "0.2.1" next to
+"v0.2.1") and move them to the format configured as release.tag_format in
+the config file (default "v{version}"). The new tag is created on the commit
+of the old one, and the old tag is deleted unless --keep-old-tags is given.
+Only GitHub targets support moving releases; other forges are skipped.
+
+A version released under several tags is reported rather than changed, so
+that the duplicate can be checked and removed by hand.
+
+Examples:
+  # See what would change
+  qoder-downloader release migrate-tags --dry-run
+
+  # Move every release to the canonical tag
+  qoder-downloader release migrate-tags`,
+	Run: runMigrateTags,
+}
+
+var (
+	migrateDryRun bool
+	keepOldTags   bool
+)
+
+func init() {
+	releaseCmd.AddCommand(migrateTagsCmd)
+	migrateTagsCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Show what would be done without actually doing it")
+	migrateTagsCmd.Flags().BoolVar(&keepOldTags, "keep-old-tags", false, "Keep the old tags after moving releases off them")
+	addPublishFlags(migrateTagsCmd)
+}
+
+func runMigrateTags(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+	tags := releaseTags()
+
+	publishers, err := newPublishers(ctx, cmd, !migrateDryRun)
+	if err != nil {
+		log.Fatalf("Failed to set up publishing: %v", err)
+	}
+
+	for _, publisher := range publishers {
+		retagger, ok := publisher.(publish.Retagger)
+		if !ok {
+			fmt.Printf("Skipping %s: moving releases to another tag is not supported there\n", describePublisher(publisher))
+			continue
+		}
+		if len(publishers) > 1 {
+			fmt.Printf("Migrating tags on %s\n", describePublisher(publisher))
+		}
+		migrateTags(ctx, publisher, retagger, tags)
+	}
+}
+
+// migrateTags moves the releases of one forge to the canonical tag format
+func migrateTags(ctx context.Context, publisher publish.ReleasePublisher, retagger publish.Retagger, tags publish.TagScheme) {
+	releases, err := publisher.Releases(ctx)
+	if err != nil {
+		log.Fatalf("Failed to get existing releases: %v", err)
+	}
+
+	byVersion := tags.ByVersion(releases)
+	versions := make([]string, 0, len(byVersion))
+	for version := range byVersion {
+		versions = append(versions, version)
+	}
+	detector.SortVersionStrings(versions)
+
+	moved, duplicates := 0, 0
+	for _, version := range versions {
+		if ctx.Err() != nil {
+			log.Fatal("Interrupted")
+		}
+
+		canonical := tags.Tag(version)
+		var current *publish.RemoteRelease
+		var others []*publish.RemoteRelease
+		for _, release := range byVersion[version] {
+			if release.Tag == canonical {
+				current = release
+			} else {
+				others = append(others, release)
+			}
+		}
+		if len(others) == 0 {
+			continue
+		}
+
+		if current != nil || len(others) > 1 {
+			duplicates++
+			fmt.Printf("%s: released as %s, skipping\n", version, describeTags(current, others))
+			continue
+		}
+
+		old := others[0].Tag
+		if migrateDryRun {
+			fmt.Printf("[DRY RUN] Would move release %s to %s\n", old, canonical)
+			moved++
+			continue
+		}
+		if err := retagger.Retag(ctx, others[0], canonical, keepOldTags); err != nil {
+			fmt.Printf("Failed to move release %s: %v\n", old, err)
+			continue
+		}
+		fmt.Printf("Moved release %s to %s\n", old, canonical)
+		moved++
+	}
+
+	fmt.Printf("\n%d releases moved to the %q tag format, %d versions with duplicate releases\n", moved, tags.Format, duplicates)
+}
+
+// describeTags lists the tags a version was released under
+func describeTags(current *publish.RemoteRelease, others []*publish.RemoteRelease) string {
+	var names []string
+	if current != nil {
+		names = append(names, current.Tag)
+	}
+	for _, release := range others {
+		names = append(names, release.Tag)
+	}
+	return strings.Join(names, ", ")
+}
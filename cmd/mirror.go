@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/retention"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/storage"
+)
+
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror",
+	Short: "Upload the downloads directory to S3-compatible object storage",
+	Long: `Upload the files recorded in the download manifest to an S3-compatible
+bucket (AWS S3, MinIO, ...) under the names the configured layout gives them.
+Objects that already hold a file with the same size and SHA-256 are skipped;
+every object carries its checksums as x-amz-meta-sha256 and x-amz-meta-md5.
+Large files are uploaded in parts.
+
+index.json (every file with its checksums) and versions.json (every version,
+newest first) are kept up to date next to the files.
+
+The bucket is configured in the config file:
+
+  s3:
+    endpoint: http://127.0.0.1:9000
+    region: us-east-1
+    bucket: qoder
+    prefix: releases
+    path_style: true          # needed for MinIO
+    public_url: https://mirror.example.com   # where the bucket is served, for index.json
+    part_size: 64MB
+    access_key: ...           # default $AWS_ACCESS_KEY_ID
+    secret_key: ...           # default $AWS_SECRET_ACCESS_KEY
+
+Examples:
+  # See what would be uploaded
+  qoder-downloader mirror --dry-run
+
+  # Upload one version
+  qoder-downloader mirror --version 0.2.1`,
+	Run: runMirror,
+}
+
+var (
+	mirrorDir     string
+	mirrorVersion string
+	mirrorDryRun  bool
+	mirrorBucket  string
+)
+
+func init() {
+	rootCmd.AddCommand(mirrorCmd)
+	mirrorCmd.Flags().StringVarP(&mirrorDir, "downloads", "d", "./downloads", "Downloads directory")
+	mirrorCmd.Flags().StringVar(&mirrorVersion, "version", "", "Only upload this version")
+	mirrorCmd.Flags().BoolVar(&mirrorDryRun, "dry-run", false, "Show what would be uploaded without uploading anything")
+	mirrorCmd.Flags().StringVar(&mirrorBucket, "bucket", "", "Bucket to upload to (default: s3.bucket from the config)")
+}
+
+func runMirror(cmd *cobra.Command, args []string) {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	opts, err := s3Options(verbose)
+	if err != nil {
+		log.Fatalf("Invalid s3 config: %v", err)
+	}
+	store, err := storage.NewS3(opts)
+	if err != nil {
+		log.Fatalf("Invalid s3 config: %v", err)
+	}
+
+	layout, err := artifactLayout()
+	if err != nil {
+		log.Fatalf("Invalid layout: %v", err)
+	}
+
+	m, err := manifest.Load(mirrorDir)
+	if err != nil {
+		log.Fatalf("Failed to load manifest: %v", err)
+	}
+	entries := m.Entries
+	if mirrorVersion != "" {
+		entries = m.ForVersion(mirrorVersion)
+	}
+	if len(entries) == 0 {
+		log.Fatalf("No files recorded in %s", manifest.Path(mirrorDir))
+	}
+
+	mirror := storage.NewMirror(store, viper.GetString("s3.prefix"), viper.GetString("s3.public_url"), layout, verbose)
+	actions, err := mirror.Sync(cmd.Context(), m, entries, mirrorDryRun)
+
+	uploaded := 0
+	for _, action := range actions {
+		switch action.Op {
+		case "keep":
+			if verbose {
+				fmt.Printf("  %s\n", action)
+			}
+			continue
+		case "upload", "replace":
+			uploaded++
+		}
+		if mirrorDryRun {
+			fmt.Printf("[DRY RUN] Would %s\n", action)
+		} else {
+			fmt.Printf("  %s\n", action)
+		}
+	}
+	if err != nil {
+		log.Fatalf("Mirroring to %s failed: %v", store.Bucket(), err)
+	}
+	if mirrorDryRun {
+		fmt.Printf("\n%d of %d files would be uploaded to %s\n", uploaded, len(entries), store.Bucket())
+		return
+	}
+	fmt.Printf("\n%d of %d files uploaded to %s\n", uploaded, len(entries), store.Bucket())
+}
+
+// s3Options reads the "s3" config section. Credentials fall back to the
+// usual AWS environment variables.
+func s3Options(verbose bool) (storage.Options, error) {
+	opts := storage.Options{
+		Endpoint:  viper.GetString("s3.endpoint"),
+		Region:    viper.GetString("s3.region"),
+		Bucket:    viper.GetString("s3.bucket"),
+		AccessKey: viper.GetString("s3.access_key"),
+		SecretKey: viper.GetString("s3.secret_key"),
+		PathStyle: viper.GetBool("s3.path_style"),
+		Verbose:   verbose,
+	}
+	if mirrorBucket != "" {
+		opts.Bucket = mirrorBucket
+	}
+	if opts.AccessKey == "" {
+		opts.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if opts.SecretKey == "" {
+		opts.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if opts.Region == "" {
+		opts.Region = os.Getenv("AWS_REGION")
+	}
+	if opts.Endpoint == "" {
+		region := opts.Region
+		if region == "" {
+			region = "us-east-1"
+		}
+		opts.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+
+	if size := viper.GetString("s3.part_size"); size != "" {
+		partSize, err := retention.ParseSize(size)
+		if err != nil {
+			return opts, fmt.Errorf("part_size: %v", err)
+		}
+		opts.PartSize = partSize
+	}
+
+	// Dry runs look at the bucket too, so they need credentials as well
+	if opts.AccessKey == "" || opts.SecretKey == "" {
+		return opts, fmt.Errorf("credentials are required: set s3.access_key and s3.secret_key or export AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return opts, nil
+}
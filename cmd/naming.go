@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+)
+
+// artifactLayout returns the configured name template for the downloads directory
+func artifactLayout() (*platform.Layout, error) {
+	template := viper.GetString("layout")
+	if template == "" {
+		template = platform.DefaultLayout
+	}
+	return platform.NewLayout(template)
+}
+
+// assetNameLayout returns the configured name template for release assets
+func assetNameLayout() (*platform.Layout, error) {
+	template := viper.GetString("asset_name")
+	if template == "" {
+		template = platform.DefaultAssetName
+	}
+	return platform.NewLayout(template)
+}
+
+// moveEntryToLayout moves a recorded file to the path the layout gives it and
+// updates the entry in place. The caller is responsible for saving the manifest.
+func moveEntryToLayout(m *manifest.Manifest, entry *manifest.Entry, layout *platform.Layout, verbose bool, dryRun bool) (string, error) {
+	platformInfo, err := platform.GetArtifact(entry.Platform, entry.Artifact)
+	if err != nil {
+		return "", err
+	}
+
+	originalPath := m.AbsPath(entry)
+	newRel := layout.Format(entry.Version, platformInfo)
+	newPath := filepath.Join(m.Dir(), filepath.FromSlash(newRel))
+
+	if newRel == entry.Path {
+		return originalPath, nil
+	}
+
+	if verbose {
+		fmt.Printf("Moving %s -> %s\n", entry.Path, newRel)
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would move: %s -> %s\n", originalPath, newPath)
+		return newPath, nil
+	}
+
+	if _, err := os.Stat(originalPath); err != nil {
+		return "", fmt.Errorf("cannot move %s: %v", entry.Path, err)
+	}
+	// Check if target file already exists
+	if _, err := os.Stat(newPath); err == nil {
+		return "", fmt.Errorf("target file %s already exists", newPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := os.Rename(originalPath, newPath); err != nil {
+		return "", fmt.Errorf("failed to move file: %v", err)
+	}
+	removeEmptyDirs(filepath.Dir(originalPath), m.Dir())
+
+	entry.Path = newRel
+	return newPath, nil
+}
+
+// removeEmptyDirs removes dir and its parents up to (not including) root while they are empty
+func removeEmptyDirs(dir, root string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && len(dir) > len(root); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"sort"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/cache"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/notes"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/publish"
+)
+
+// adjacentVersions returns the known versions right before and after a
+// version, or empty strings at either end
+func adjacentVersions(cacheManager *cache.Manager, version string) (string, string) {
+	versions := cacheManager.GetValidVersions()
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Compare(versions[j]) < 0
+	})
+
+	var previous, next string
+	for i, v := range versions {
+		if v.Raw != version {
+			continue
+		}
+		if i > 0 {
+			previous = versions[i-1].Raw
+		}
+		if i+1 < len(versions) {
+			next = versions[i+1].Raw
+		}
+	}
+	return previous, next
+}
+
+// firstSeen returns when a version was first found upstream, falling back
+// to its earliest download recorded in m (which may be nil). It is zero when
+// neither is known.
+func firstSeen(cacheManager *cache.Manager, m *manifest.Manifest, version string) time.Time {
+	var first time.Time
+	if t, ok := cacheManager.FirstSeen(version); ok {
+		first = t
+	} else if m != nil {
+		for _, entry := range m.ForVersion(version) {
+			if first.IsZero() || entry.DownloadedAt.Before(first) {
+				first = entry.DownloadedAt
+			}
+		}
+	}
+	return first.In(time.UTC)
+}
+
+// releaseNotes renders the notes of a version with the template configured
+// as release.notes_template. Links to neighbouring releases point to the
+// forge of publisher, and files too large for it are listed with the number
+// of parts they are split into. m may be nil (see firstSeen).
+func releaseNotes(publisher publish.ReleasePublisher, cacheManager *cache.Manager, m *manifest.Manifest, version string, files []notes.File, tagFor func(string) string) (string, error) {
+	text, err := notes.Load(viper.GetString("release.notes_template"))
+	if err != nil {
+		return "", err
+	}
+
+	data := notes.Data{
+		Version: version,
+		Tag:     tagFor(version),
+		Files:   make([]notes.File, len(files)),
+	}
+	for i, file := range files {
+		file.Parts = publish.PartCount(file.Size, publisher.MaxAssetSize())
+		data.Files[i] = file
+	}
+
+	data.FirstSeen = firstSeen(cacheManager, m, version)
+
+	previous, next := adjacentVersions(cacheManager, version)
+	link := func(v string) *notes.Link {
+		if v == "" {
+			return nil
+		}
+		return &notes.Link{Version: v, Tag: tagFor(v), URL: publisher.ReleaseURL(tagFor(v))}
+	}
+	data.Previous, data.Next = link(previous), link(next)
+
+	return notes.Render(text, data)
+}
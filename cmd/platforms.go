@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+)
+
+var platformsCmd = &cobra.Command{
+	Use:   "platforms",
+	Short: "Show the effective platform registry",
+	Long: `Show the platforms this tool downloads, after merging the built-in registry
+with "registry_file" and the "registry"/"platforms" sections of the config file.
+
+Example override in ~/.qoder-downloader.yaml:
+
+  registry:
+    base_url: https://mirror.example.com/qoder/release
+  platforms:
+    - name: linux-arm64
+      disabled: true
+    - name: linux-x64-deb
+      os: linux
+      arch: amd64
+      extension: deb
+      filename: "Qoder-linux-x64.{ext}"
+
+Examples:
+  # List platforms with example URLs
+  qoder-downloader platforms
+
+  # Dump the effective registry as a starting point for registry_file
+  qoder-downloader platforms --yaml`,
+	Run: runPlatforms,
+}
+
+var (
+	platformsVersion string
+	platformsJSON    bool
+	platformsYAML    bool
+)
+
+func init() {
+	rootCmd.AddCommand(platformsCmd)
+	platformsCmd.Flags().StringVar(&platformsVersion, "version", "latest", "Version used for the example URLs")
+	platformsCmd.Flags().BoolVar(&platformsJSON, "json", false, "Print the effective registry as JSON")
+	platformsCmd.Flags().BoolVar(&platformsYAML, "yaml", false, "Print the effective registry as YAML")
+}
+
+func runPlatforms(cmd *cobra.Command, args []string) {
+	registry := platform.ActiveRegistry()
+
+	switch {
+	case platformsJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(registry); err != nil {
+			log.Fatalf("Failed to encode registry: %v", err)
+		}
+		return
+	case platformsYAML:
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(registry); err != nil {
+			log.Fatalf("Failed to encode registry: %v", err)
+		}
+		return
+	}
+
+	fmt.Printf("Base URL: %s\n\n", registry.BaseURL)
+	fmt.Printf("%-15s %-8s %-6s %-9s %-8s %s\n", "NAME", "OS", "ARCH", "EXT", "SOURCE", "URL")
+	for _, p := range registry.Platforms {
+		fmt.Printf("%-15s %-8s %-6s %-9s %-8s %s\n", p.Name, p.OS, p.Arch, p.Extension, p.Source, registry.URL(platformsVersion, p))
+		for _, name := range p.ArtifactNames()[1:] {
+			artifact, _ := p.WithArtifact(name)
+			fmt.Printf("  + %-11s %-8s %-6s %-9s %-8s %s\n", name, "", "", artifact.Extension, "", registry.URL(platformsVersion, artifact))
+		}
+	}
+}
+
+// loadPlatformRegistry merges the built-in registry with the configured overrides
+func loadPlatformRegistry() (*platform.Registry, error) {
+	registry := platform.DefaultRegistry()
+
+	if file := viper.GetString("registry_file"); file != "" {
+		// A missing file is created by "discover-artifacts --promote"
+		data, err := os.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read registry file: %w", err)
+		}
+		if err == nil {
+			overlay, err := platform.ParseRegistry(data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			registry.Merge(overlay, "file")
+		}
+	}
+
+	var overlay platform.Registry
+	if err := viper.UnmarshalKey("registry", &overlay); err != nil {
+		return nil, fmt.Errorf("invalid registry section in config: %w", err)
+	}
+	if err := viper.UnmarshalKey("platforms", &overlay.Platforms); err != nil {
+		return nil, fmt.Errorf("invalid platforms section in config: %w", err)
+	}
+	registry.Merge(&overlay, "config")
+
+	if err := registry.Validate(); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// parseArtifacts splits an --artifact value and checks that every name is
+// published by at least one of the selected platforms
+func parseArtifacts(value string, platformNames []string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	known := make(map[string]bool)
+	var knownNames []string
+	for _, name := range platformNames {
+		p, err := platform.GetPlatformByName(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, artifact := range p.ArtifactNames() {
+			if !known[artifact] {
+				known[artifact] = true
+				knownNames = append(knownNames, artifact)
+			}
+		}
+	}
+
+	var artifacts []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("unknown artifact %q (available: %s)", name, strings.Join(knownNames, ", "))
+		}
+		artifacts = append(artifacts, name)
+	}
+	return artifacts, nil
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/cache"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/retention"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old downloads according to retention policies",
+	Long: `Remove downloads that are not retained by any configured policy.
+
+A download is kept when any keep rule retains it. The size cap is applied
+afterwards by evicting the oldest versions first. Versions listed under
+"pinned_versions" in the config file are never removed.
+
+Policies can be set in the config file:
+
+  retention:
+    keep_latest: 5
+    keep_since: 2025-01-01
+    keep_per_minor: true
+    max_size: 200GB
+  pinned_versions:
+    - 0.1.21
+
+Examples:
+  # Show what would be removed when keeping the 3 newest versions per platform
+  qoder-downloader prune --keep-latest 3 --dry-run
+
+  # Stay under 100GB, oldest versions first
+  qoder-downloader prune --max-size 100GB`,
+	Run: runPrune,
+}
+
+var (
+	pruneDir          string
+	pruneKeepLatest   int
+	pruneKeepSince    string
+	pruneKeepPerMinor bool
+	pruneMaxSize      string
+	pruneDryRun       bool
+)
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+	pruneCmd.Flags().StringVarP(&pruneDir, "downloads", "d", "./downloads", "Downloads directory")
+	pruneCmd.Flags().IntVar(&pruneKeepLatest, "keep-latest", 0, "Keep the newest N versions per platform")
+	pruneCmd.Flags().StringVar(&pruneKeepSince, "keep-since", "", "Keep every version first seen on or after this date (YYYY-MM-DD)")
+	pruneCmd.Flags().BoolVar(&pruneKeepPerMinor, "keep-per-minor", false, "Keep the newest build of every minor version line")
+	pruneCmd.Flags().StringVar(&pruneMaxSize, "max-size", "", "Total size cap, e.g. 200GB")
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "List what would be removed without deleting anything")
+}
+
+func runPrune(cmd *cobra.Command, args []string) {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	policy, err := retentionPolicy(cmd)
+	if err != nil {
+		log.Fatalf("Invalid retention policy: %v", err)
+	}
+	if policy.IsEmpty() {
+		log.Fatal("No retention policy configured. Use --keep-latest, --keep-since, --keep-per-minor or --max-size")
+	}
+
+	if err := pruneDownloads(pruneDir, policy, pruneDryRun, verbose); err != nil {
+		log.Fatalf("Prune failed: %v", err)
+	}
+}
+
+// pruneDownloads applies a retention policy to a downloads directory
+func pruneDownloads(dir string, policy retention.Policy, dryRun bool, verbose bool) error {
+	m, err := manifest.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	// keep_since goes by the date a version was first seen upstream
+	cacheManager, err := cache.NewManager(".", verbose, 24)
+	if err != nil {
+		return err
+	}
+	policy.FirstSeen = cacheManager.FirstSeen
+
+	plan, err := retention.Apply(m, policy)
+	if err != nil {
+		return err
+	}
+
+	if len(plan.Remove) == 0 {
+		fmt.Println("Nothing to prune")
+		return nil
+	}
+
+	for _, decision := range plan.Remove {
+		prefix := "Removing"
+		if dryRun {
+			prefix = "[DRY RUN] Would remove"
+		}
+		fmt.Printf("%s %s (%s): %s\n", prefix, decision.Entry.Path, retention.FormatSize(decision.Entry.Size), decision.Reason)
+	}
+	fmt.Printf("\n%d files, %s freed; %d files, %s kept\n",
+		len(plan.Remove), retention.FormatSize(plan.RemovedSize),
+		len(plan.Keep), retention.FormatSize(plan.KeptSize))
+
+	if dryRun {
+		return nil
+	}
+
+	return retention.Execute(m, plan, verbose)
+}
+
+// retentionPolicy builds a policy from the config file, overridden by any prune flags that were set
+func retentionPolicy(cmd *cobra.Command) (retention.Policy, error) {
+	policy := retention.Policy{
+		KeepLatest:   viper.GetInt("retention.keep_latest"),
+		KeepPerMinor: viper.GetBool("retention.keep_per_minor"),
+		Pinned:       viper.GetStringSlice("pinned_versions"),
+	}
+	keepSince := viper.GetString("retention.keep_since")
+	maxSize := viper.GetString("retention.max_size")
+
+	flags := cmd.Flags()
+	if flags.Changed("keep-latest") {
+		policy.KeepLatest = pruneKeepLatest
+	}
+	if flags.Changed("keep-per-minor") {
+		policy.KeepPerMinor = pruneKeepPerMinor
+	}
+	if flags.Changed("keep-since") {
+		keepSince = pruneKeepSince
+	}
+	if flags.Changed("max-size") {
+		maxSize = pruneMaxSize
+	}
+
+	if keepSince != "" {
+		since, err := time.Parse("2006-01-02", keepSince)
+		if err != nil {
+			return policy, fmt.Errorf("invalid keep-since date %q: %v", keepSince, err)
+		}
+		policy.KeepSince = since
+	}
+
+	size, err := retention.ParseSize(maxSize)
+	if err != nil {
+		return policy, err
+	}
+	policy.MaxSize = size
+
+	return policy, nil
+}
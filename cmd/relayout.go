@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/downloader"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+)
+
+var relayoutCmd = &cobra.Command{
+	Use:   "relayout",
+	Short: "Move the downloads directory to a new name template",
+	Long: `Move every file recorded in the download manifest to the path given by a new
+name template, updating the manifest as it goes.
+
+Templates may use {version}, {product}, {platform}, {os}, {arch}, {goos},
+{goarch} and {ext}. {os}-{arch} is the upstream platform name (win32-x64),
+{goos}-{goarch} the Go one (windows-amd64).
+Files that are not in the manifest yet can be picked up with --from, which
+gives the template their current names follow.
+
+Examples:
+  # Group downloads by platform instead of by version
+  qoder-downloader relayout --to "{platform}/{product}-{version}.{ext}"
+
+  # Migrate a tree created before the manifest, using the old Windows names
+  qoder-downloader relayout --from "{version}/{product}-{version}-{goos}-{arch}.{ext}" --to "` + platform.DefaultLayout + `"`,
+	Run: runRelayout,
+}
+
+var (
+	relayoutDir    string
+	relayoutTo     string
+	relayoutFrom   string
+	relayoutDryRun bool
+)
+
+func init() {
+	rootCmd.AddCommand(relayoutCmd)
+	relayoutCmd.Flags().StringVarP(&relayoutDir, "downloads", "d", "./downloads", "Downloads directory")
+	relayoutCmd.Flags().StringVar(&relayoutTo, "to", "", "New name template (required)")
+	relayoutCmd.Flags().StringVar(&relayoutFrom, "from", "", "Template matching files not yet recorded in the manifest")
+	relayoutCmd.Flags().BoolVar(&relayoutDryRun, "dry-run", false, "Show what would be moved without moving anything")
+	relayoutCmd.MarkFlagRequired("to")
+}
+
+func runRelayout(cmd *cobra.Command, args []string) {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	to, err := platform.NewLayout(relayoutTo)
+	if err != nil {
+		log.Fatalf("Invalid --to template: %v", err)
+	}
+
+	m, err := manifest.Load(relayoutDir)
+	if err != nil {
+		log.Fatalf("Failed to load manifest: %v", err)
+	}
+
+	if relayoutFrom != "" {
+		from, err := platform.NewLayout(relayoutFrom)
+		if err != nil {
+			log.Fatalf("Invalid --from template: %v", err)
+		}
+		adopted, err := adoptUntracked(m, from, verbose, relayoutDryRun)
+		if err != nil {
+			log.Fatalf("Failed to scan %s: %v", relayoutDir, err)
+		}
+		fmt.Printf("Recorded %d untracked files matching %s\n", adopted, from)
+	}
+
+	moved := 0
+	failed := 0
+	for _, entry := range m.Entries {
+		before := entry.Path
+		if _, err := moveEntryToLayout(m, entry, to, verbose, relayoutDryRun); err != nil {
+			fmt.Printf("Failed to move %s: %v\n", before, err)
+			failed++
+			continue
+		}
+		if entry.Path != before || relayoutDryRun {
+			moved++
+		}
+	}
+
+	if !relayoutDryRun {
+		if err := m.Save(); err != nil {
+			log.Fatalf("Failed to save manifest: %v", err)
+		}
+	}
+
+	fmt.Printf("Moved %d files, %d failed\n", moved, failed)
+	if failed == 0 && !relayoutDryRun {
+		fmt.Printf("Set \"layout: %s\" in your config file so new downloads use the same template\n", to)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// adoptUntracked records files that match a template but are missing from the manifest
+func adoptUntracked(m *manifest.Manifest, from *platform.Layout, verbose bool, dryRun bool) (int, error) {
+	adopted := 0
+	err := filepath.Walk(m.Dir(), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := m.RelPath(path)
+		if err != nil {
+			return err
+		}
+		if manifest.IsBookkeeping(rel) || m.FindPath(rel) != nil {
+			return nil
+		}
+
+		version, platformInfo, err := from.Parse(rel)
+		if err != nil {
+			if verbose {
+				fmt.Printf("Skipping %s: %v\n", rel, err)
+			}
+			return nil
+		}
+		if m.Find(version, platformInfo.Name, platformInfo.Artifact) != nil {
+			fmt.Printf("Skipping %s: %s %s is already recorded\n", rel, version, platformInfo.Name)
+			return nil
+		}
+
+		entry := &manifest.Entry{
+			Version:      version,
+			Platform:     platformInfo.Name,
+			Artifact:     platformInfo.Artifact,
+			Path:         rel,
+			URL:          platform.ConstructDownloadURL(version, platformInfo),
+			DownloadedAt: info.ModTime().UTC(),
+		}
+		if !dryRun {
+			entry.Size, entry.MD5, entry.SHA256, err = downloader.HashFile(path)
+			if err != nil {
+				return err
+			}
+		}
+		if verbose {
+			fmt.Printf("Recording %s as %s %s\n", rel, version, platformInfo.Name)
+		}
+		m.Put(entry)
+		adopted++
+		return nil
+	})
+	return adopted, err
+}
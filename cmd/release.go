@@ -1,23 +1,33 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/vibe-coding-labs/qoder-downloader/internal/cache"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/detector"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/notes"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/publish"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/retention"
 )
 
 var releaseCmd = &cobra.Command{
 	Use:   "release",
-	Short: "Create GitHub releases with renamed Qoder files",
-	Long:  `Create GitHub releases for each version and upload renamed Qoder files as assets.`,
-	Run:   runRelease,
+	Short: "Create releases with renamed Qoder files",
+	Long: `Create releases for each version and upload renamed Qoder files as assets.
+
+Releases go to GitHub unless "targets" in the config file lists other forges
+(GitHub, Gitea, Forgejo or GitLab) to publish to; --target narrows a run
+down to some of them.`,
+	Run: runRelease,
 }
 
 var (
@@ -25,6 +35,7 @@ var (
 	releaseAll     bool
 	downloadsDir   string
 	dryRun         bool
+	updateNotes    bool
 )
 
 func init() {
@@ -33,14 +44,22 @@ func init() {
 	releaseCmd.Flags().BoolVarP(&releaseAll, "all", "a", false, "Create releases for all downloaded versions")
 	releaseCmd.Flags().StringVarP(&downloadsDir, "downloads", "d", "./downloads", "Downloads directory")
 	releaseCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be done without actually doing it")
+	releaseCmd.Flags().BoolVar(&updateNotes, "update-notes", false, "Regenerate the notes of releases that already exist")
+	addPublishFlags(releaseCmd)
 }
 
 func runRelease(cmd *cobra.Command, args []string) {
 	verbose, _ := cmd.Flags().GetBool("verbose")
 	
-	// Check if GitHub CLI is installed (skip in dry-run mode)
-	if !dryRun && !isGitHubCLIInstalled() {
-		log.Fatal("GitHub CLI (gh) is not installed. Please install it first: https://cli.github.com/")
+	// Tokens are only needed when releases are really published
+	publishers, err := newPublishers(cmd.Context(), cmd, !dryRun)
+	if err != nil {
+		log.Fatalf("Failed to set up publishing: %v", err)
+	}
+	if !dryRun {
+		if err := setupSigning(); err != nil {
+			log.Fatalf("Failed to load signing key: %v", err)
+		}
 	}
 	
 	// Initialize cache manager
@@ -62,148 +81,338 @@ func runRelease(cmd *cobra.Command, args []string) {
 		}
 		
 		if verbose {
-			fmt.Printf("Creating releases for %d versions...\n", len(existingVersions))
+			fmt.Printf("Creating releases for %d versions on %s...\n", len(existingVersions), forgeNames(publishers))
 		}
 		
 		for _, version := range existingVersions {
-			err := createReleaseForVersion(version, verbose, dryRun)
+			if cmd.Context().Err() != nil {
+				log.Fatal("Interrupted")
+			}
+			err := createReleaseForVersion(cmd.Context(), publishers, cacheManager, version, verbose, dryRun)
 			if err != nil {
 				fmt.Printf("Failed to create release for %s: %v\n", version, err)
 			}
 		}
 	} else if releaseVersion != "" {
 		// Create release for specific version
-		err := createReleaseForVersion(releaseVersion, verbose, dryRun)
+		err := createReleaseForVersion(cmd.Context(), publishers, cacheManager, releaseVersion, verbose, dryRun)
 		if err != nil {
 			log.Fatalf("Failed to create release for %s: %v", releaseVersion, err)
 		}
 	} else {
 		log.Fatal("Either --all or --version must be specified")
 	}
-}
 
-func isGitHubCLIInstalled() bool {
-	_, err := exec.LookPath("gh")
-	return err == nil
+	// Backfilled versions are published without becoming latest, so the
+	// highest version is made latest once they are all done
+	for _, publisher := range publishers {
+		if err := syncLatest(cmd.Context(), publisher, releaseTags(), dryRun); err != nil {
+			fmt.Printf("Failed to update the latest release on %s: %v\n", describePublisher(publisher), err)
+		}
+	}
 }
 
-func createReleaseForVersion(version string, verbose bool, dryRun bool) error {
-	versionDir := filepath.Join(downloadsDir, version)
-	
-	// Check if version directory exists
-	if _, err := os.Stat(versionDir); os.IsNotExist(err) {
-		return fmt.Errorf("version directory %s does not exist. Run 'download --version %s' first", versionDir, version)
+// createReleaseForVersion publishes the downloaded files of a version to
+// every publisher. A failure on one forge does not stop the others.
+func createReleaseForVersion(ctx context.Context, publishers []publish.ReleasePublisher, cacheManager *cache.Manager, version string, verbose bool, dryRun bool) error {
+	m, err := manifest.Load(downloadsDir)
+	if err != nil {
+		return err
+	}
+
+	entries := m.ForVersion(version)
+	if len(entries) == 0 {
+		return fmt.Errorf("no files recorded for version %s in %s. Run 'download --version %s' first", version, manifest.Path(downloadsDir), version)
 	}
 	
 	if verbose {
 		fmt.Printf("Processing version %s...\n", version)
 	}
 	
-	// Find all DMG files in the version directory
-	files, err := filepath.Glob(filepath.Join(versionDir, "*.dmg"))
+	layout, err := artifactLayout()
 	if err != nil {
-		return fmt.Errorf("failed to find DMG files: %v", err)
+		return err
 	}
-	
-	if len(files) == 0 {
-		return fmt.Errorf("no DMG files found in %s", versionDir)
+	assetNames, err := assetNameLayout()
+	if err != nil {
+		return err
 	}
 	
-	// Rename files to the new format
-	renamedFiles := make([]string, 0, len(files))
-	for _, file := range files {
-		renamedFile, err := renameQoderFile(file, version, verbose, dryRun)
+	// Move files to the configured layout and pick their asset names
+	var assets []publish.Asset
+	sources := make(map[string]*manifest.Entry)
+	var files []notes.File
+	previous, _ := adjacentVersions(cacheManager, version)
+	for _, entry := range entries {
+		path, err := moveEntryToLayout(m, entry, layout, verbose, dryRun)
+		if err != nil {
+			fmt.Printf("Failed to rename %s: %v\n", entry.Path, err)
+			continue
+		}
+		platformInfo, err := platform.GetArtifact(entry.Platform, entry.Artifact)
 		if err != nil {
-			fmt.Printf("Failed to rename %s: %v\n", file, err)
+			fmt.Printf("Skipping %s: %v\n", entry.Path, err)
 			continue
 		}
-		renamedFiles = append(renamedFiles, renamedFile)
+		sources[assetNames.Base(version, platformInfo)] = entry
+		assets = append(assets, publish.Asset{
+			Name:   assetNames.Base(version, platformInfo),
+			Path:   path,
+			Size:   entry.Size,
+			SHA256: entry.SHA256,
+		})
+
+		file := notes.File{
+			Platform: entry.Platform,
+			Artifact: entry.Artifact,
+			Asset:    assetNames.Base(version, platformInfo),
+			URL:      entry.URL,
+			Size:     entry.Size,
+			SHA256:   entry.SHA256,
+			MD5:      entry.MD5,
+		}
+		if prev := m.Find(previous, entry.Platform, entry.Artifact); prev != nil {
+			file.PreviousSize, file.HasPrevious = prev.Size, true
+		}
+		files = append(files, file)
 	}
 	
-	if len(renamedFiles) == 0 {
+	if len(assets) == 0 {
 		return fmt.Errorf("no files were successfully renamed")
 	}
 	
-	// Create GitHub release
-	if dryRun {
-		fmt.Printf("[DRY RUN] Would create GitHub release for version %s\n", version)
-		fmt.Printf("[DRY RUN] Would upload files: %v\n", renamedFiles)
-		return nil
+	tags := releaseTags()
+	if !dryRun {
+		if err := m.Save(); err != nil {
+			return err
+		}
 	}
-	
-	return createGitHubRelease(version, renamedFiles, verbose)
-}
 
-func renameQoderFile(originalPath, version string, verbose bool, dryRun bool) (string, error) {
-	filename := filepath.Base(originalPath)
-	dir := filepath.Dir(originalPath)
-	
-	// Extract platform from original filename
-	// Expected format: Qoder-darwin-arm64.dmg -> qoder-0.1.21-darwin-arm64.dmg
-	if !strings.HasPrefix(filename, "Qoder-") || !strings.HasSuffix(filename, ".dmg") {
-		return "", fmt.Errorf("unexpected filename format: %s", filename)
+	var failed []string
+	for _, publisher := range publishers {
+		// Notes link to neighbouring releases on the same forge
+		body, err := releaseNotes(publisher, cacheManager, m, version, files, tags.Tag)
+		if err != nil {
+			return err
+		}
+
+		if dryRun {
+			kind := "release"
+			if isPrerelease(version) {
+				kind = "prerelease"
+			}
+			fmt.Printf("[DRY RUN] Would create %s %s for version %s on %s\n", kind, tags.Tag(version), version, describePublisher(publisher))
+			for _, asset := range assets {
+				if parts := publish.PartCount(asset.Size, publisher.MaxAssetSize()); parts > 0 {
+					fmt.Printf("[DRY RUN] Would upload %s as %s in %d parts\n", asset.Path, asset.Name, parts)
+					continue
+				}
+				fmt.Printf("[DRY RUN] Would upload %s as %s\n", asset.Path, asset.Name)
+			}
+			if verbose {
+				fmt.Printf("[DRY RUN] Release notes:\n%s\n", body)
+			}
+			continue
+		}
+
+		if err := publishRelease(ctx, publisher, tags, version, firstSeen(cacheManager, m, version), body, assets, m, sources, verbose); err != nil {
+			fmt.Printf("Failed to publish %s to %s: %v\n", version, describePublisher(publisher), err)
+			failed = append(failed, describePublisher(publisher))
+		}
 	}
-	
-	// Extract platform part (e.g., "darwin-arm64" from "Qoder-darwin-arm64.dmg")
-	platform := strings.TrimSuffix(strings.TrimPrefix(filename, "Qoder-"), ".dmg")
-	
-	// Create new filename
-	newFilename := fmt.Sprintf("qoder-%s-%s.dmg", version, platform)
-	newPath := filepath.Join(dir, newFilename)
-	
+	if len(failed) > 0 {
+		return fmt.Errorf("publishing failed on %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// publishRelease brings the release for a version in line with the local
+// assets, creating it if needed, dated when the version was first seen. The
+// manifest entries the assets came from, keyed by asset name, record where
+// they were published.
+func publishRelease(ctx context.Context, publisher publish.ReleasePublisher, tags publish.TagScheme, version string, released time.Time, body string, assets []publish.Asset, m *manifest.Manifest, sources map[string]*manifest.Entry, verbose bool) error {
 	if verbose {
-		fmt.Printf("Renaming %s -> %s\n", filename, newFilename)
+		fmt.Printf("Publishing release for version %s to %s...\n", version, describePublisher(publisher))
 	}
-	
-	if dryRun {
-		fmt.Printf("[DRY RUN] Would rename: %s -> %s\n", originalPath, newPath)
-		return newPath, nil
+
+	variants := tags.Variants(version)
+	// New releases stay drafts until every asset is in place
+	want := publish.Release{
+		Tag:        variants[0],
+		Aliases:    variants[1:],
+		Name:       fmt.Sprintf("Qoder %s", version),
+		Body:       body,
+		Draft:      true,
+		Prerelease: isPrerelease(version),
+		Date:       released,
 	}
-	
-	// Check if target file already exists
-	if _, err := os.Stat(newPath); err == nil {
+
+	// Files larger than the forge accepts go up as numbered parts
+	dir, err := os.MkdirTemp("", "qoder-parts-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	assets, split, err := publish.SplitOversized(assets, publisher.MaxAssetSize(), dir)
+	if err != nil {
+		return err
+	}
+	for manifestName, name := range split {
 		if verbose {
-			fmt.Printf("File %s already exists, skipping rename\n", newPath)
+			fmt.Printf("  %s is larger than %s allows, uploading it in parts\n", name, retention.FormatSize(publisher.MaxAssetSize()))
 		}
-		return newPath, nil
+		// The parts manifest records where the file was published
+		sources[manifestName] = sources[name]
+	}
+
+	release, actions, err := publish.Reconcile(ctx, publisher, want, assets, false)
+	printActions(actions, verbose)
+	// Uploads made before a failure are recorded too
+	if recordErr := recordReleases(m, publisher, release, actions, sources); recordErr != nil && err == nil {
+		err = recordErr
 	}
-	
-	err := os.Rename(originalPath, newPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to rename file: %v", err)
+		return err
+	}
+	if err := publishSums(ctx, publisher, want, release, verbose); err != nil {
+		return err
+	}
+	if release.Draft || release.Prerelease != want.Prerelease {
+		if _, err := publisher.Publish(ctx, release, want); err != nil {
+			return err
+		}
+		if release.Draft {
+			fmt.Printf("  publish %s\n", release.Tag)
+		}
+	}
+
+	created := len(actions) > 0 && actions[0].Op == "create"
+	if updateNotes && !created {
+		if err := refreshNotes(ctx, publisher, want); err != nil {
+			return err
+		}
 	}
-	
-	return newPath, nil
-}
 
-func createGitHubRelease(version string, files []string, verbose bool) error {
 	if verbose {
-		fmt.Printf("Creating GitHub release for version %s...\n", version)
+		fmt.Printf("Release for version %s is up to date\n", version)
 	}
-	
-	// Create the release
-	cmd := exec.Command("gh", "release", "create", version, 
-		"--title", fmt.Sprintf("Qoder %s", version),
-		"--notes", fmt.Sprintf("Release of Qoder version %s", version))
-	
-	// Add all files as assets
-	for _, file := range files {
-		cmd.Args = append(cmd.Args, file)
+
+	return nil
+}
+
+// recordReleases notes on manifest entries which release assets they are
+// published as
+func recordReleases(m *manifest.Manifest, publisher publish.ReleasePublisher, release *publish.RemoteRelease, actions []publish.Action, sources map[string]*manifest.Entry) error {
+	if release == nil {
+		return nil
 	}
-	
-	if verbose {
-		fmt.Printf("Running: %s\n", strings.Join(cmd.Args, " "))
+
+	changed := false
+	for _, action := range actions {
+		entry := sources[action.Name]
+		if entry == nil || action.ID == 0 {
+			continue
+		}
+		if entry.RecordRelease(&manifest.Release{
+			Forge:       publisher.Forge(),
+			Repo:        publisher.Repo(),
+			Tag:         release.Tag,
+			Asset:       action.Name,
+			AssetID:     action.ID,
+			PublishedAt: time.Now().UTC(),
+		}) {
+			changed = true
+		}
 	}
-	
-	output, err := cmd.CombinedOutput()
+	if !changed {
+		return nil
+	}
+	return m.Save()
+}
+
+// refreshNotes replaces the notes of an existing release, keeping the
+// recorded asset checksums and any retirement notice
+func refreshNotes(ctx context.Context, publisher publish.ReleasePublisher, want publish.Release) error {
+	var release *publish.RemoteRelease
+	for _, tag := range append([]string{want.Tag}, want.Aliases...) {
+		var err error
+		if release, err = publisher.ReleaseByTag(ctx, tag); err != nil {
+			return err
+		}
+		if release != nil {
+			break
+		}
+	}
+	if release == nil {
+		return nil
+	}
+	body := publish.WithChecksums(want.Body, publish.Checksums(release.Body))
+	if notice := publish.RetiredNotice(release.Body); notice != "" {
+		body = publish.WithRetiredNotice(body, notice)
+	}
+	if body == release.Body && want.Name == release.Name {
+		return nil
+	}
+	fmt.Printf("  update notes of %s\n", release.Tag)
+	_, err := publisher.UpdateNotes(ctx, release, want.Name, body)
+	return err
+}
+
+// isPrerelease reports whether a version is a pre-release build
+func isPrerelease(version string) bool {
+	v, err := detector.ParseVersion(version)
+	return err == nil && v.Prerelease()
+}
+
+// syncLatest shows the release of the highest published version as latest,
+// on forges that let it be chosen. Others pick it themselves, so a warning
+// is printed when they show another one.
+func syncLatest(ctx context.Context, publisher publish.ReleasePublisher, tags publish.TagScheme, dryRun bool) error {
+	reporter, ok := publisher.(publish.LatestReporter)
+	if !ok {
+		return nil
+	}
+	releases, err := publisher.Releases(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create GitHub release: %v\nOutput: %s", err, string(output))
+		return err
 	}
-	
-	if verbose {
-		fmt.Printf("Successfully created release for version %s\n", version)
-		fmt.Printf("GitHub CLI output: %s\n", string(output))
+	want := publish.LatestCandidate(releases, tags)
+	if want == nil {
+		return nil
 	}
-	
-	return nil
-}
\ No newline at end of file
+	current, err := reporter.Latest(ctx)
+	if err != nil {
+		return err
+	}
+	if current != nil && current.Tag == want.Tag {
+		return nil
+	}
+
+	marker, ok := publisher.(publish.LatestMarker)
+	if !ok {
+		shown := "no release"
+		if current != nil {
+			shown = current.Tag
+		}
+		fmt.Printf("Warning: %s shows %s as latest instead of %s, and the latest release cannot be chosen there\n", describePublisher(publisher), shown, want.Tag)
+		return nil
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would mark %s as latest on %s\n", want.Tag, describePublisher(publisher))
+		return nil
+	}
+	fmt.Printf("  mark %s as latest on %s\n", want.Tag, describePublisher(publisher))
+	return marker.MarkLatest(ctx, want)
+}
+
+// printActions lists the changes made to a release; unchanged assets are
+// only listed when verbose
+func printActions(actions []publish.Action, verbose bool) {
+	for _, action := range actions {
+		if action.Op == "keep" && !verbose {
+			continue
+		}
+		fmt.Printf("  %s\n", action)
+	}
+}
@@ -3,19 +3,20 @@ package cmd
 import (
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/vibe-coding-labs/qoder-downloader/internal/cache"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
 )
 
 var renameCmd = &cobra.Command{
 	Use:   "rename",
-	Short: "Rename downloaded Qoder files to include version and platform",
-	Long:  `Rename downloaded Qoder files to the format qoder-{version}-{platform}.dmg`,
+	Short: "Rename downloaded Qoder files to the configured layout",
+	Long: `Rename downloaded Qoder files recorded in the download manifest to the
+configured layout (default ` + platform.DefaultLayout + `).
+Use 'relayout' to move the whole tree to a different template.`,
 	Run:   runRename,
 }
 
@@ -81,33 +82,31 @@ func runRename(cmd *cobra.Command, args []string) {
 }
 
 func renameFilesForVersion(version string, verbose bool) error {
-	versionDir := filepath.Join(renameDir, version)
-	
-	// Check if version directory exists
-	if _, err := os.Stat(versionDir); os.IsNotExist(err) {
-		return fmt.Errorf("version directory %s does not exist. Run 'download --version %s' first", versionDir, version)
-	}
-	
-	if verbose {
-		fmt.Printf("Processing version %s...\n", version)
+	m, err := manifest.Load(renameDir)
+	if err != nil {
+		return err
 	}
-	
-	// Find all DMG files in the version directory
-	files, err := filepath.Glob(filepath.Join(versionDir, "*.dmg"))
+
+	layout, err := artifactLayout()
 	if err != nil {
-		return fmt.Errorf("failed to find DMG files: %v", err)
+		return err
+	}
+
+	entries := m.ForVersion(version)
+	if len(entries) == 0 {
+		return fmt.Errorf("no files recorded for version %s in %s. Run 'download --version %s' first", version, manifest.Path(renameDir), version)
 	}
 	
-	if len(files) == 0 {
-		return fmt.Errorf("no DMG files found in %s", versionDir)
+	if verbose {
+		fmt.Printf("Processing version %s...\n", version)
 	}
 	
 	// Rename files to the new format
 	successCount := 0
-	for _, file := range files {
-		_, err := renameQoderFileActual(file, version, verbose)
+	for _, entry := range entries {
+		_, err := moveEntryToLayout(m, entry, layout, verbose, false)
 		if err != nil {
-			fmt.Printf("Failed to rename %s: %v\n", file, err)
+			fmt.Printf("Failed to rename %s: %v\n", entry.Path, err)
 			continue
 		}
 		successCount++
@@ -116,47 +115,14 @@ func renameFilesForVersion(version string, verbose bool) error {
 	if successCount == 0 {
 		return fmt.Errorf("no files were successfully renamed")
 	}
-	
-	if verbose {
-		fmt.Printf("Successfully renamed %d/%d files for version %s\n", successCount, len(files), version)
-	}
-	
-	return nil
-}
 
-func renameQoderFileActual(originalPath, version string, verbose bool) (string, error) {
-	filename := filepath.Base(originalPath)
-	dir := filepath.Dir(originalPath)
-	
-	// Extract platform from original filename
-	// Expected format: Qoder-darwin-arm64.dmg -> qoder-0.1.21-darwin-arm64.dmg
-	if !strings.HasPrefix(filename, "Qoder-") || !strings.HasSuffix(filename, ".dmg") {
-		return "", fmt.Errorf("unexpected filename format: %s", filename)
+	if err := m.Save(); err != nil {
+		return err
 	}
 	
-	// Extract platform part (e.g., "darwin-arm64" from "Qoder-darwin-arm64.dmg")
-	platform := strings.TrimSuffix(strings.TrimPrefix(filename, "Qoder-"), ".dmg")
-	
-	// Create new filename
-	newFilename := fmt.Sprintf("qoder-%s-%s.dmg", version, platform)
-	newPath := filepath.Join(dir, newFilename)
-	
 	if verbose {
-		fmt.Printf("Renaming %s -> %s\n", filename, newFilename)
+		fmt.Printf("Successfully renamed %d/%d files for version %s\n", successCount, len(entries), version)
 	}
 	
-	// Check if target file already exists
-	if _, err := os.Stat(newPath); err == nil {
-		if verbose {
-			fmt.Printf("File %s already exists, skipping rename\n", newPath)
-		}
-		return newPath, nil
-	}
-	
-	err := os.Rename(originalPath, newPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to rename file: %v", err)
-	}
-	
-	return newPath, nil
-}
\ No newline at end of file
+	return nil
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/detector"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/publish"
+)
+
+var retireCmd = &cobra.Command{
+	Use:   "retire",
+	Short: "Mark releases of versions that disappeared upstream as deprecated",
+	Long: `Check every released version against the upstream download server and put a
+deprecation notice at the top of the notes of versions upstream no longer
+serves any file of. Retired versions are never shown as latest. When a
+retired version becomes available again, the notice is taken out.
+
+Examples:
+  # See which releases would be retired
+  qoder-downloader release retire --dry-run
+
+  # Only check some versions
+  qoder-downloader release retire --version 0.1.4 --version 0.1.5`,
+	Run: runRetire,
+}
+
+var (
+	retireDryRun   bool
+	retireVersions []string
+)
+
+func init() {
+	releaseCmd.AddCommand(retireCmd)
+	retireCmd.Flags().BoolVar(&retireDryRun, "dry-run", false, "Show what would be done without actually doing it")
+	retireCmd.Flags().StringSliceVar(&retireVersions, "version", nil, "Only check these versions (default: every released version)")
+	addPublishFlags(retireCmd)
+}
+
+func runRetire(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	tags := releaseTags()
+
+	publishers, err := newPublishers(ctx, cmd, !retireDryRun)
+	if err != nil {
+		log.Fatalf("Failed to set up publishing: %v", err)
+	}
+
+	// Upstream is checked once per version, whatever the number of forges
+	checker := &upstreamChecker{detector: detector.NewDetector(verbose), gone: make(map[string]bool)}
+	for _, publisher := range publishers {
+		if len(publishers) > 1 {
+			fmt.Printf("Checking releases on %s\n", describePublisher(publisher))
+		}
+		if err := retireReleases(ctx, publisher, tags, checker); err != nil {
+			log.Fatalf("Failed to retire releases on %s: %v", describePublisher(publisher), err)
+		}
+		if err := syncLatest(ctx, publisher, tags, retireDryRun); err != nil {
+			fmt.Printf("Failed to update the latest release on %s: %v\n", describePublisher(publisher), err)
+		}
+	}
+}
+
+// upstreamChecker remembers which versions upstream no longer serves
+type upstreamChecker struct {
+	detector *detector.Detector
+	gone     map[string]bool
+}
+
+// Gone reports whether upstream serves no file of a version any more
+func (c *upstreamChecker) Gone(ctx context.Context, version string) (bool, error) {
+	if gone, ok := c.gone[version]; ok {
+		return gone, nil
+	}
+	artifacts, err := c.detector.CheckArtifacts(ctx, version)
+	if err != nil {
+		return false, err
+	}
+	c.gone[version] = len(artifacts) == 0
+	return c.gone[version], nil
+}
+
+// retireReleases adds or removes the retirement notice of every published
+// release on one forge
+func retireReleases(ctx context.Context, publisher publish.ReleasePublisher, tags publish.TagScheme, checker *upstreamChecker) error {
+	releases, err := publisher.Releases(ctx)
+	if err != nil {
+		return err
+	}
+	byVersion := tags.ByVersion(releases)
+
+	versions := retireVersions
+	if len(versions) == 0 {
+		for version := range byVersion {
+			versions = append(versions, version)
+		}
+		detector.SortVersionStrings(versions)
+	}
+
+	retired, restored := 0, 0
+	for _, version := range versions {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if len(byVersion[version]) == 0 {
+			fmt.Printf("%s: not released, skipping\n", version)
+			continue
+		}
+		gone, err := checker.Gone(ctx, version)
+		if err != nil {
+			// Retiring on a network error would be wrong, so the version is left alone
+			fmt.Printf("%s: failed to check upstream, skipping: %v\n", version, err)
+			continue
+		}
+
+		for _, release := range byVersion[version] {
+			if release.Draft || gone == publish.Retired(release.Body) {
+				continue
+			}
+			body, op := publish.WithoutRetiredNotice(release.Body), "restore"
+			if gone {
+				notice := fmt.Sprintf("> **Deprecated:** Qoder %s is no longer available from the upstream download server (checked on %s). The files attached here are kept for reference; prefer a newer version.",
+					version, time.Now().UTC().Format("2006-01-02"))
+				body, op = publish.WithRetiredNotice(release.Body, notice), "retire"
+			}
+
+			if retireDryRun {
+				fmt.Printf("[DRY RUN] Would %s %s\n", op, release.Tag)
+			} else {
+				fmt.Printf("  %s %s\n", op, release.Tag)
+				if _, err := publisher.UpdateNotes(ctx, release, release.Name, body); err != nil {
+					return err
+				}
+			}
+			if gone {
+				retired++
+			} else {
+				restored++
+			}
+		}
+	}
+
+	if retireDryRun {
+		fmt.Printf("\n%d releases would be retired, %d restored\n", retired, restored)
+		return nil
+	}
+	fmt.Printf("\n%d releases retired, %d restored\n", retired, restored)
+	return nil
+}
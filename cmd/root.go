@@ -1,11 +1,16 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
 )
 
 var cfgFile string
@@ -25,8 +30,17 @@ the results locally to avoid repeated detection.`,
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// Commands receive a context that is cancelled on SIGINT or SIGTERM. Only the
+// first signal is caught, so a second one ends the process straight away.
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return rootCmd.ExecuteContext(ctx)
 }
 
 func init() {
@@ -38,6 +52,8 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.qoder-downloader.yaml)")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().StringP("cache-dir", "c", "", "cache directory (default is $HOME/.qoder-downloader)")
+	rootCmd.PersistentFlags().String("layout", "", "name template for the downloads directory (default \""+platform.DefaultLayout+"\")")
+	viper.BindPFlag("layout", rootCmd.PersistentFlags().Lookup("layout"))
 	
 	// Add all child commands to the root command
 	rootCmd.AddCommand(downloadCmd)
@@ -73,4 +89,12 @@ func initConfig() {
 			fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 		}
 	}
+
+	// Apply platform registry overrides before any command looks platforms up
+	registry, err := loadPlatformRegistry()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	platform.SetRegistry(registry)
 }
\ No newline at end of file
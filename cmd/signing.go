@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/publish"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/signing"
+)
+
+// Environment variable holding the passphrase of the signing key, unless
+// signing.passphrase_env names another
+const defaultPassphraseEnv = "QODER_SIGNING_PASSPHRASE"
+
+// releaseSigner signs the SHA256SUMS of releases; nil publishes them unsigned
+var releaseSigner *signing.Signer
+
+// setupSigning loads the key configured as signing.key, if any
+func setupSigning() error {
+	path := viper.GetString("signing.key")
+	if path == "" {
+		return nil
+	}
+	env := viper.GetString("signing.passphrase_env")
+	if env == "" {
+		env = defaultPassphraseEnv
+	}
+
+	signer, err := signing.LoadSigner(path, []byte(os.Getenv(env)))
+	if err != nil {
+		return err
+	}
+	releaseSigner = signer
+	return nil
+}
+
+// publishSums attaches a SHA256SUMS file listing every asset whose checksum
+// the release records, signed when a key is configured. Nothing is uploaded
+// when the recorded file is already current.
+func publishSums(ctx context.Context, publisher publish.ReleasePublisher, want publish.Release, release *publish.RemoteRelease, verbose bool) error {
+	recorded := publish.Checksums(release.Body)
+	sums := make(map[string]string)
+	for name, sum := range recorded {
+		if !signing.IsSumsAsset(name) {
+			sums[name] = sum
+		}
+	}
+	if len(sums) == 0 {
+		return nil
+	}
+
+	data := signing.FormatSums(sums)
+	hash := sha256.Sum256(data)
+	current := recorded[signing.SumsName] == hex.EncodeToString(hash[:])
+	if current && (releaseSigner == nil || recorded[signing.SignatureName] != "") {
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "qoder-sums-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	assets := []publish.Asset{{Name: signing.SumsName, Path: filepath.Join(dir, signing.SumsName)}}
+	if err := os.WriteFile(assets[0].Path, data, 0644); err != nil {
+		return err
+	}
+	if releaseSigner != nil {
+		signature, err := releaseSigner.Sign(data)
+		if err != nil {
+			return fmt.Errorf("failed to sign %s: %w", signing.SumsName, err)
+		}
+		path := filepath.Join(dir, signing.SignatureName)
+		if err := os.WriteFile(path, signature, 0644); err != nil {
+			return err
+		}
+		assets = append(assets, publish.Asset{Name: signing.SignatureName, Path: path})
+	}
+
+	_, actions, err := publish.Reconcile(ctx, publisher, want, assets, false)
+	printActions(actions, verbose)
+	return err
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/publish"
+)
+
+var publishTargets []string
+
+// addPublishFlags adds the flags selecting where releases are published
+func addPublishFlags(cmd *cobra.Command) {
+	addGitHubFlags(cmd)
+	cmd.Flags().StringSliceVar(&publishTargets, "target", nil, "Publish only to the named targets from the config file (default: all targets)")
+}
+
+// releaseTargets returns the forges listed under "targets" in the config
+// file, narrowed down to the ones named with --target. Without a "targets"
+// list releases go to GitHub alone. GitHub targets take what they leave out
+// from the GitHub flags and the "github" config section.
+func releaseTargets(cmd *cobra.Command) ([]publish.Target, error) {
+	var targets []publish.Target
+	if viper.IsSet("targets") {
+		if err := viper.UnmarshalKey("targets", &targets); err != nil {
+			return nil, fmt.Errorf("invalid targets in config: %w", err)
+		}
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("no targets configured")
+		}
+	} else {
+		targets = []publish.Target{{Type: "github"}}
+	}
+
+	opts := githubOptions(cmd)
+	seen := make(map[string]bool)
+	for i := range targets {
+		t := &targets[i]
+		if t.Type == "" {
+			t.Type = "github"
+		}
+		if seen[t.Label()] {
+			return nil, fmt.Errorf("target %s is configured twice; give the targets distinct names", t.Label())
+		}
+		seen[t.Label()] = true
+
+		t.ResolveToken()
+		if t.Type == "github" {
+			if t.Repo == "" {
+				t.Repo = opts.Repo
+			}
+			if t.APIURL == "" {
+				t.APIURL = opts.APIURL
+			}
+			if t.UploadURL == "" {
+				t.UploadURL = opts.UploadURL
+			}
+			if t.Token == "" {
+				t.Token = opts.Token
+			}
+			if t.MaxAssetSize == "" {
+				t.MaxAssetSize = viper.GetString("github.max_asset_size")
+			}
+		}
+	}
+
+	if len(publishTargets) == 0 {
+		return targets, nil
+	}
+	var selected []publish.Target
+	for _, name := range publishTargets {
+		if !seen[name] {
+			return nil, fmt.Errorf("unknown target %q", name)
+		}
+		for _, t := range targets {
+			if t.Label() == name {
+				selected = append(selected, t)
+			}
+		}
+	}
+	return selected, nil
+}
+
+// newPublishers creates a publisher for every selected target. Tokens are
+// only demanded when requireToken is set, so that dry runs work without.
+func newPublishers(ctx context.Context, cmd *cobra.Command, requireToken bool) ([]publish.ReleasePublisher, error) {
+	targets, err := releaseTargets(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := githubOptions(cmd)
+	var publishers []publish.ReleasePublisher
+	for _, t := range targets {
+		if requireToken && t.Token == "" {
+			if t.Type == "github" {
+				return nil, fmt.Errorf("a GitHub token is required: pass --token, set github.token in the config file or export GITHUB_TOKEN")
+			}
+			return nil, fmt.Errorf("target %s needs a token: set token or token_env in its config", t.Label())
+		}
+		publisher, err := publish.New(ctx, t, opts.CacheDir, opts.Verbose)
+		if err != nil {
+			return nil, err
+		}
+		publishers = append(publishers, publisher)
+	}
+	return publishers, nil
+}
+
+// describePublisher names a publisher in messages, such as "gitlab group/project"
+func describePublisher(p publish.ReleasePublisher) string {
+	return p.Forge() + " " + p.Repo()
+}
+
+// forgeNames lists the forges of publishers for messages
+func forgeNames(publishers []publish.ReleasePublisher) string {
+	names := make([]string, len(publishers))
+	for i, p := range publishers {
+		names[i] = describePublisher(p)
+	}
+	return strings.Join(names, ", ")
+}
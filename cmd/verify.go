@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/verify"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the downloads directory against the download manifest",
+	Long: `Re-hash every file in the downloads directory and compare it with the
+download manifest. Missing, extra, truncated and modified files are reported.
+
+Examples:
+  # Full verification using all CPUs
+  qoder-downloader verify
+
+  # Only compare file sizes
+  qoder-downloader verify --quick
+
+  # Also compare against the upstream MD5 checksums and emit JSON for CI
+  qoder-downloader verify --upstream --json`,
+	Run: runVerify,
+}
+
+var (
+	verifyDir      string
+	verifyQuick    bool
+	verifyUpstream bool
+	verifyJobs     int
+	verifyJSON     bool
+)
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().StringVarP(&verifyDir, "downloads", "d", "./downloads", "Downloads directory")
+	verifyCmd.Flags().BoolVar(&verifyQuick, "quick", false, "Only compare file sizes, skip hashing")
+	verifyCmd.Flags().BoolVar(&verifyUpstream, "upstream", false, "Also compare against upstream MD5 checksums")
+	verifyCmd.Flags().IntVarP(&verifyJobs, "jobs", "j", runtime.NumCPU(), "Number of files to hash in parallel")
+	verifyCmd.Flags().BoolVar(&verifyJSON, "json", false, "Write the report as JSON")
+}
+
+func runVerify(cmd *cobra.Command, args []string) {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	m, err := manifest.Load(verifyDir)
+	if err != nil {
+		log.Fatalf("Failed to load manifest: %v", err)
+	}
+
+	verifier := verify.NewVerifier(verify.Options{
+		Quick:    verifyQuick,
+		Upstream: verifyUpstream && !verifyQuick,
+		Jobs:     verifyJobs,
+		Verbose:  verbose && !verifyJSON,
+	})
+
+	report, err := verifier.Verify(cmd.Context(), m)
+	if cmd.Context().Err() != nil {
+		fmt.Println("Interrupted")
+		os.Exit(130)
+	}
+	if err != nil {
+		log.Fatalf("Verification failed: %v", err)
+	}
+
+	if verifyJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("Failed to encode report: %v", err)
+		}
+	} else {
+		printVerifyReport(report)
+	}
+
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
+
+func printVerifyReport(report *verify.Report) {
+	for _, result := range report.Problems() {
+		fmt.Printf("%-18s %s", result.Status, result.Path)
+		switch result.Status {
+		case verify.StatusTruncated, verify.StatusModified:
+			if result.ActualSize != result.ExpectedSize {
+				fmt.Printf(" (size %d, expected %d)", result.ActualSize, result.ExpectedSize)
+			}
+		case verify.StatusUpstreamMismatch:
+			fmt.Printf(" (upstream md5 %s)", result.UpstreamMD5)
+		}
+		if result.Detail != "" {
+			fmt.Printf(": %s", result.Detail)
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("\nVerified %d files in %s\n", len(report.Results), report.Dir)
+	for _, status := range []verify.Status{
+		verify.StatusOK,
+		verify.StatusMissing,
+		verify.StatusExtra,
+		verify.StatusTruncated,
+		verify.StatusModified,
+		verify.StatusUpstreamMismatch,
+	} {
+		if count := report.Counts[status]; count > 0 {
+			fmt.Printf("  %-18s %d\n", status+":", count)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/downloader"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/signing"
+)
+
+var verifyReleaseCmd = &cobra.Command{
+	Use:   "verify-release",
+	Short: "Check downloaded release assets against the signed SHA256SUMS",
+	Long: `Check the signature of a release's SHA256SUMS file with a public key, then
+hash the assets in a directory and compare them with the sums. Download the
+assets together with SHA256SUMS and SHA256SUMS.asc from the release first.
+
+The public key defaults to signing.public_key from the config file.
+
+Examples:
+  # Check everything downloaded into the current directory
+  qoder-downloader verify-release --key qoder-release.asc
+
+  # Check only the files that were downloaded
+  qoder-downloader verify-release --dir ~/Downloads/qoder --ignore-missing`,
+	Run: runVerifyRelease,
+}
+
+var (
+	verifyReleaseDir           string
+	verifyReleaseSums          string
+	verifyReleaseSignature     string
+	verifyReleaseKey           string
+	verifyReleaseIgnoreMissing bool
+)
+
+func init() {
+	rootCmd.AddCommand(verifyReleaseCmd)
+	verifyReleaseCmd.Flags().StringVar(&verifyReleaseDir, "dir", ".", "Directory holding the downloaded assets")
+	verifyReleaseCmd.Flags().StringVar(&verifyReleaseSums, "sums", "", "Checksum file (default: SHA256SUMS in --dir)")
+	verifyReleaseCmd.Flags().StringVar(&verifyReleaseSignature, "signature", "", "Detached signature of the checksum file (default: the checksum file with .asc appended)")
+	verifyReleaseCmd.Flags().StringVar(&verifyReleaseKey, "key", "", "Armored public key to check the signature with")
+	verifyReleaseCmd.Flags().BoolVar(&verifyReleaseIgnoreMissing, "ignore-missing", false, "Do not fail for assets that were not downloaded")
+}
+
+func runVerifyRelease(cmd *cobra.Command, args []string) {
+	sumsPath := verifyReleaseSums
+	if sumsPath == "" {
+		sumsPath = filepath.Join(verifyReleaseDir, signing.SumsName)
+	}
+	signaturePath := verifyReleaseSignature
+	if signaturePath == "" {
+		signaturePath = sumsPath + ".asc"
+	}
+	key := verifyReleaseKey
+	if key == "" {
+		key = viper.GetString("signing.public_key")
+	}
+	if key == "" {
+		log.Fatal("A public key is required: pass --key or set signing.public_key in the config file")
+	}
+
+	data, err := os.ReadFile(sumsPath)
+	if err != nil {
+		log.Fatalf("Failed to read checksums: %v", err)
+	}
+	signature, err := os.ReadFile(signaturePath)
+	if err != nil {
+		log.Fatalf("Failed to read signature: %v", err)
+	}
+	keyID, err := signing.Verify(data, signature, key)
+	if err != nil {
+		log.Fatalf("%s: %v", sumsPath, err)
+	}
+	fmt.Printf("Good signature on %s from key %s\n", filepath.Base(sumsPath), keyID)
+
+	sums, err := signing.ParseSums(data)
+	if err != nil {
+		log.Fatalf("Failed to parse %s: %v", sumsPath, err)
+	}
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ok, missing, failed int
+	for _, name := range names {
+		path := filepath.Join(verifyReleaseDir, filepath.FromSlash(name))
+		_, _, sha, err := downloader.HashFile(path)
+		switch {
+		case os.IsNotExist(err):
+			missing++
+			if !verifyReleaseIgnoreMissing {
+				fmt.Printf("%-8s %s\n", "MISSING", name)
+			}
+		case err != nil:
+			failed++
+			fmt.Printf("%-8s %s: %v\n", "FAILED", name, err)
+		case sha != sums[name]:
+			failed++
+			fmt.Printf("%-8s %s\n", "FAILED", name)
+		default:
+			ok++
+			fmt.Printf("%-8s %s\n", "OK", name)
+		}
+	}
+
+	fmt.Printf("\n%d OK, %d failed, %d missing\n", ok, failed, missing)
+	if failed > 0 || (missing > 0 && !verifyReleaseIgnoreMissing) || ok == 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,585 @@
+// Package audit checks that the assets of published releases match the files
+// recorded in the download manifest.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/chunks"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/publish"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/signing"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/verify"
+)
+
+// Status describes the outcome of checking one asset
+type Status string
+
+const (
+	StatusOK               Status = "ok"
+	StatusMissing          Status = "missing"           // An archived file has no asset
+	StatusExtra            Status = "extra"             // An asset no archived file accounts for
+	StatusRenamed          Status = "renamed"           // An archived file is attached under another name
+	StatusCorrupted        Status = "corrupted"         // The asset differs from the archived file
+	StatusUnverified       Status = "unverified"        // The size matches, but the contents were not hashed
+	StatusFailed           Status = "failed"            // The asset could not be downloaded to hash it
+	StatusUpstreamMismatch Status = "upstream-mismatch" // The archived file differs from upstream's MD5
+)
+
+// Result is the outcome for one asset, or for an archived file without one
+type Result struct {
+	Asset          string `json:"asset,omitempty"`    // Name on the release; empty when missing
+	Expected       string `json:"expected,omitempty"` // Name the archived file should have; empty for extra assets
+	Platform       string `json:"platform,omitempty"`
+	Artifact       string `json:"artifact,omitempty"`
+	Status         Status `json:"status"`
+	Size           int64  `json:"size,omitempty"` // As the forge reports it, or as downloaded
+	ExpectedSize   int64  `json:"expected_size,omitempty"`
+	SHA256         string `json:"sha256,omitempty"` // As downloaded or reported by the forge, else as recorded in the release notes
+	ExpectedSHA256 string `json:"expected_sha256,omitempty"`
+	UpstreamMD5    string `json:"upstream_md5,omitempty"`
+	Parts          int    `json:"parts,omitempty"` // Number of parts a split file is attached in
+	Detail         string `json:"detail,omitempty"`
+}
+
+// Name returns the name a result is listed under
+func (r Result) Name() string {
+	if r.Asset != "" {
+		return r.Asset
+	}
+	return r.Expected
+}
+
+// Release holds the results for the release of one version
+type Release struct {
+	Version string   `json:"version"`
+	Tag     string   `json:"tag,omitempty"` // Empty when the version has no release
+	URL     string   `json:"url,omitempty"`
+	Draft   bool     `json:"draft,omitempty"`
+	Results []Result `json:"results"`
+}
+
+// Report collects the audit of one forge
+type Report struct {
+	Forge      string         `json:"forge"`
+	Repo       string         `json:"repo"`
+	Downloaded bool           `json:"downloaded"`        // Asset contents were hashed
+	Releases   []*Release     `json:"releases"`          // Ordered by version
+	Skipped    []string       `json:"skipped,omitempty"` // Released versions the manifest has no files of
+	Counts     map[Status]int `json:"counts"`
+}
+
+// Audit is the machine-readable result of auditing any number of forges
+type Audit struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Targets     []*Report `json:"targets"`
+}
+
+// OK reports whether every forge passed
+func (a *Audit) OK() bool {
+	for _, report := range a.Targets {
+		if !report.OK() {
+			return false
+		}
+	}
+	return true
+}
+
+// Add appends the audit of a release and counts its results
+func (r *Report) Add(release *Release) {
+	if r.Counts == nil {
+		r.Counts = make(map[Status]int)
+	}
+	r.Releases = append(r.Releases, release)
+	for _, result := range release.Results {
+		r.Counts[result.Status]++
+	}
+}
+
+// OK reports whether no asset is missing, extra, renamed, damaged or failed
+// to download. Unverified assets, whose contents were not hashed, do not
+// count against it.
+func (r *Report) OK() bool {
+	for status, count := range r.Counts {
+		if status != StatusOK && status != StatusUnverified && count > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Expected is an archived file and the asset name it is published under
+type Expected struct {
+	Name  string
+	Entry *manifest.Entry
+}
+
+// Options controls an audit
+type Options struct {
+	Download bool // Hash the contents of every asset the forge does not report a checksum for
+	Upstream bool // Also compare the archived files with the upstream MD5 checksums
+	Verbose  bool
+}
+
+// Auditor checks the releases of one forge
+type Auditor struct {
+	publisher publish.ReleasePublisher
+	opts      Options
+	verifier  *verify.Verifier
+	upstream  map[*manifest.Entry]upstreamMD5
+}
+
+type upstreamMD5 struct {
+	sum string
+	err error
+}
+
+// digest is what downloading an asset showed
+type digest struct {
+	size   int64
+	md5    string
+	sha256 string
+}
+
+// New creates an auditor for the releases of a publisher
+func New(publisher publish.ReleasePublisher, opts Options) *Auditor {
+	return &Auditor{
+		publisher: publisher,
+		opts:      opts,
+		verifier:  verify.NewVerifier(verify.Options{Verbose: opts.Verbose}),
+		upstream:  make(map[*manifest.Entry]upstreamMD5),
+	}
+}
+
+// Release audits the release of a version against the files expected on it.
+// release may be nil when the version was never released.
+func (a *Auditor) Release(ctx context.Context, version string, release *publish.RemoteRelease, expected []Expected) (*Release, error) {
+	out := &Release{Version: version}
+	if release == nil {
+		for _, e := range expected {
+			result := expectedResult(e)
+			result.Status, result.Detail = StatusMissing, "the version has no release"
+			out.Results = append(out.Results, result)
+		}
+		return out, nil
+	}
+	out.Tag, out.URL, out.Draft = release.Tag, release.URL, release.Draft
+
+	assets, err := a.publisher.Assets(ctx, release)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*publish.RemoteAsset, len(assets))
+	for _, asset := range assets {
+		byName[asset.Name] = asset
+	}
+	c := &releaseCheck{
+		Auditor: a,
+		ctx:     ctx,
+		release: release,
+		byName:  byName,
+		sums:    publish.Checksums(release.Body),
+		used:    make(map[string]bool),
+		hashes:  make(map[int64]*digest),
+	}
+
+	var missing []Expected
+	for _, e := range expected {
+		switch {
+		case byName[e.Name] != nil:
+			out.Results = append(out.Results, c.checkFile(e, byName[e.Name]))
+		case byName[chunks.ManifestName(e.Name)] != nil:
+			out.Results = append(out.Results, c.checkParts(e, byName[chunks.ManifestName(e.Name)]))
+		default:
+			missing = append(missing, e)
+		}
+		if md5Asset := byName[e.Name+".md5"]; md5Asset != nil {
+			out.Results = append(out.Results, c.checkMD5(e, md5Asset))
+		}
+	}
+	out.Results = append(out.Results, c.checkSums(expected)...)
+
+	// A missing file attached under another name shows up as an extra asset
+	// with its checksum, or with the asset ID the manifest recorded
+	for _, e := range missing {
+		result := expectedResult(e)
+		if renamed := c.findRenamed(e, assets); renamed != nil {
+			c.used[renamed.Name] = true
+			result.Status, result.Asset, result.Size = StatusRenamed, renamed.Name, renamed.Size
+		} else {
+			result.Status = StatusMissing
+		}
+		out.Results = append(out.Results, result)
+	}
+
+	for _, asset := range assets {
+		if !c.used[asset.Name] {
+			sum := asset.SHA256
+			if sum == "" {
+				sum = c.sums[asset.Name]
+			}
+			out.Results = append(out.Results, Result{Asset: asset.Name, Status: StatusExtra, Size: asset.Size, SHA256: sum})
+		}
+	}
+
+	sort.SliceStable(out.Results, func(i, j int) bool {
+		return out.Results[i].Name() < out.Results[j].Name()
+	})
+	return out, nil
+}
+
+func expectedResult(e Expected) Result {
+	return Result{
+		Expected:       e.Name,
+		Platform:       e.Entry.Platform,
+		Artifact:       e.Entry.Artifact,
+		ExpectedSize:   e.Entry.Size,
+		ExpectedSHA256: e.Entry.SHA256,
+	}
+}
+
+// releaseCheck holds what is known about one release while it is audited
+type releaseCheck struct {
+	*Auditor
+	ctx     context.Context
+	release *publish.RemoteRelease
+	byName  map[string]*publish.RemoteAsset
+	sums    map[string]string // Checksums recorded in the release notes
+	used    map[string]bool   // Assets accounted for
+	hashes  map[int64]*digest // Downloaded assets, by ID
+}
+
+// checkFile compares an asset with the archived file it should hold. Only a
+// checksum of the contents, downloaded or reported by the forge, verifies
+// it; one recorded in the release notes only shows what was meant to be
+// uploaded.
+func (c *releaseCheck) checkFile(e Expected, asset *publish.RemoteAsset) Result {
+	c.used[asset.Name] = true
+	result := expectedResult(e)
+	result.Asset, result.Size, result.SHA256 = asset.Name, asset.Size, asset.SHA256
+
+	switch {
+	case !asset.Uploaded:
+		result.Status, result.Detail = StatusCorrupted, "upload did not finish"
+		return result
+	case asset.Size >= 0 && asset.Size != e.Entry.Size:
+		result.Status = StatusCorrupted
+		return result
+	}
+
+	md5Sum := ""
+	if c.opts.Download && result.SHA256 == "" {
+		d, err := c.hash(asset)
+		if err != nil {
+			result.Status, result.Detail = StatusFailed, err.Error()
+			return result
+		}
+		result.Size, result.SHA256, md5Sum = d.size, d.sha256, d.md5
+		if d.size != e.Entry.Size {
+			result.Status = StatusCorrupted
+			return result
+		}
+	}
+
+	recorded := c.sums[asset.Name]
+	switch {
+	case result.SHA256 != "" && result.SHA256 != e.Entry.SHA256, md5Sum != "" && e.Entry.MD5 != "" && md5Sum != e.Entry.MD5:
+		result.Status = StatusCorrupted
+	case result.SHA256 != "":
+		result.Status = StatusOK
+	case recorded != "" && recorded != e.Entry.SHA256:
+		result.Status, result.SHA256, result.Detail = StatusCorrupted, recorded, "the release notes record another checksum"
+	case recorded != "":
+		result.Status, result.SHA256, result.Detail = StatusUnverified, recorded, "the size and the checksum in the release notes match, but the contents were not hashed"
+	default:
+		result.Status, result.Detail = StatusUnverified, "the size matches, but the contents were not hashed"
+	}
+	if result.Status != StatusCorrupted {
+		c.compareUpstream(e, &result)
+	}
+	return result
+}
+
+// checkParts compares a file attached in parts with the archived file. The
+// parts manifest is always downloaded; the parts only with Download, unless
+// the forge reports their checksums.
+func (c *releaseCheck) checkParts(e Expected, manifestAsset *publish.RemoteAsset) Result {
+	c.used[manifestAsset.Name] = true
+	result := expectedResult(e)
+	result.Asset = manifestAsset.Name
+
+	data, err := c.read(manifestAsset, 1<<20)
+	if err != nil {
+		result.Status, result.Detail = StatusFailed, err.Error()
+		return result
+	}
+	m, err := chunks.Parse(data)
+	if err != nil {
+		result.Status, result.Detail = StatusCorrupted, fmt.Sprintf("invalid parts manifest: %v", err)
+		return result
+	}
+	result.Parts, result.Size, result.SHA256 = len(m.Parts), m.Size, m.SHA256
+	if m.Size != e.Entry.Size || m.SHA256 != e.Entry.SHA256 {
+		result.Status, result.Detail = StatusCorrupted, "the parts manifest describes a different file"
+		return result
+	}
+
+	whole := sha256.New()
+	hashed := true // Whether the contents of every part were checked
+	for _, part := range m.Parts {
+		asset := c.byName[part.Name]
+		if asset == nil {
+			result.Status, result.Detail = StatusCorrupted, fmt.Sprintf("part %s is missing", part.Name)
+			return result
+		}
+		c.used[part.Name] = true
+		if !asset.Uploaded || (asset.Size >= 0 && asset.Size != part.Size) {
+			result.Status, result.Detail = StatusCorrupted, fmt.Sprintf("part %s is damaged", part.Name)
+			return result
+		}
+		if recorded := c.sums[part.Name]; recorded != "" && recorded != part.SHA256 {
+			result.Status, result.Detail = StatusCorrupted, fmt.Sprintf("part %s has a different checksum", part.Name)
+			return result
+		}
+		if asset.SHA256 != "" {
+			if asset.SHA256 != part.SHA256 {
+				result.Status, result.Detail = StatusCorrupted, fmt.Sprintf("part %s has a different checksum", part.Name)
+				return result
+			}
+			if !c.opts.Download {
+				continue
+			}
+		}
+		if !c.opts.Download {
+			hashed = false
+			continue
+		}
+		h := sha256.New()
+		if err := c.copy(io.MultiWriter(whole, h), asset); err != nil {
+			result.Status, result.Detail = StatusFailed, err.Error()
+			return result
+		}
+		if hex.EncodeToString(h.Sum(nil)) != part.SHA256 {
+			result.Status, result.Detail = StatusCorrupted, fmt.Sprintf("part %s has a different checksum", part.Name)
+			return result
+		}
+	}
+	if c.opts.Download && hex.EncodeToString(whole.Sum(nil)) != e.Entry.SHA256 {
+		result.Status, result.Detail = StatusCorrupted, "the joined parts differ from the archived file"
+		return result
+	}
+
+	if hashed {
+		result.Status = StatusOK
+	} else {
+		result.Status, result.Detail = StatusUnverified, "the parts manifest matches, but the parts were not hashed"
+	}
+	c.compareUpstream(e, &result)
+	return result
+}
+
+// checkMD5 checks the MD5 asset next to a file. Without Download only
+// placeholders, which are recognised by their size, are found.
+func (c *releaseCheck) checkMD5(e Expected, asset *publish.RemoteAsset) Result {
+	c.used[asset.Name] = true
+	result := Result{Asset: asset.Name, Expected: asset.Name, Platform: e.Entry.Platform, Artifact: e.Entry.Artifact, Size: asset.Size, Status: StatusOK}
+
+	if !c.opts.Download {
+		if asset.Size >= 0 && asset.Size <= int64(len(publish.Placeholder))+2 {
+			result.Status, result.Detail = StatusCorrupted, "placeholder instead of a checksum"
+		} else {
+			result.Status, result.Detail = StatusUnverified, "not a placeholder, but the contents were not read"
+		}
+		return result
+	}
+	data, err := c.read(asset, 4096)
+	if err != nil {
+		result.Status, result.Detail = StatusFailed, err.Error()
+		return result
+	}
+	fields := strings.Fields(string(data))
+	switch {
+	case len(fields) > 0 && fields[0] == publish.Placeholder:
+		result.Status, result.Detail = StatusCorrupted, "placeholder instead of a checksum"
+	case len(fields) == 0 || !strings.EqualFold(fields[0], e.Entry.MD5):
+		result.Status, result.Detail = StatusCorrupted, fmt.Sprintf("does not hold the archived MD5 %s", e.Entry.MD5)
+	}
+	return result
+}
+
+// checkSums checks SHA256SUMS and its signature. Downloaded, SHA256SUMS must
+// list the archived checksum of every file it names.
+func (c *releaseCheck) checkSums(expected []Expected) []Result {
+	var results []Result
+	if asset := c.byName[signing.SignatureName]; asset != nil {
+		c.used[asset.Name] = true
+		results = append(results, Result{Asset: asset.Name, Expected: asset.Name, Status: StatusOK, Size: asset.Size})
+	}
+
+	asset := c.byName[signing.SumsName]
+	if asset == nil {
+		if len(expected) == 0 {
+			return results
+		}
+		return append(results, Result{Expected: signing.SumsName, Status: StatusMissing})
+	}
+	c.used[asset.Name] = true
+	result := Result{Asset: asset.Name, Expected: asset.Name, Status: StatusOK, Size: asset.Size, SHA256: asset.SHA256}
+	if !c.opts.Download {
+		result.Status, result.Detail = StatusUnverified, "the contents were not read"
+		return append(results, result)
+	}
+
+	data, err := c.read(asset, 1<<20)
+	if err != nil {
+		result.Status, result.Detail = StatusFailed, err.Error()
+		return append(results, result)
+	}
+	listed, err := signing.ParseSums(data)
+	if err != nil {
+		result.Status, result.Detail = StatusCorrupted, err.Error()
+		return append(results, result)
+	}
+	want := make(map[string]string, len(c.sums))
+	for name, sum := range c.sums {
+		want[name] = sum
+	}
+	for _, e := range expected {
+		want[e.Name] = e.Entry.SHA256
+	}
+	var wrong []string
+	for name, sum := range listed {
+		if want[name] != "" && want[name] != sum {
+			wrong = append(wrong, name)
+		}
+	}
+	if len(wrong) > 0 {
+		sort.Strings(wrong)
+		result.Status, result.Detail = StatusCorrupted, "wrong checksum listed for "+strings.Join(wrong, ", ")
+	}
+	return append(results, result)
+}
+
+// findRenamed returns an unaccounted asset that holds an archived file
+func (c *releaseCheck) findRenamed(e Expected, assets []*publish.RemoteAsset) *publish.RemoteAsset {
+	for _, asset := range assets {
+		if c.used[asset.Name] {
+			continue
+		}
+		for _, r := range e.Entry.Releases {
+			if r.ForgeName() == c.publisher.Forge() && r.Repo == c.publisher.Repo() && r.AssetID != 0 && r.AssetID == asset.ID {
+				return asset
+			}
+		}
+	}
+	for _, asset := range assets {
+		if c.used[asset.Name] || (asset.Size >= 0 && asset.Size != e.Entry.Size) {
+			continue
+		}
+		sum := asset.SHA256
+		if sum == "" {
+			sum = c.sums[asset.Name]
+		}
+		if sum == "" && c.opts.Download {
+			if d, err := c.hash(asset); err == nil {
+				sum = d.sha256
+			}
+		}
+		if sum == e.Entry.SHA256 {
+			return asset
+		}
+	}
+	return nil
+}
+
+// compareUpstream flags a file whose archived MD5 differs from upstream's
+func (c *releaseCheck) compareUpstream(e Expected, result *Result) {
+	if !c.opts.Upstream {
+		return
+	}
+	upstream, ok := c.upstream[e.Entry]
+	if !ok {
+		upstream.sum, upstream.err = c.verifier.UpstreamMD5(c.ctx, e.Entry)
+		c.upstream[e.Entry] = upstream
+	}
+	if upstream.err != nil {
+		result.Detail = fmt.Sprintf("upstream checksum unavailable: %v", upstream.err)
+		return
+	}
+	result.UpstreamMD5 = upstream.sum
+	if !strings.EqualFold(upstream.sum, e.Entry.MD5) {
+		result.Status = StatusUpstreamMismatch
+	}
+}
+
+// hash downloads an asset and returns its size and checksums
+func (c *releaseCheck) hash(asset *publish.RemoteAsset) (*digest, error) {
+	if d := c.hashes[asset.ID]; d != nil {
+		return d, nil
+	}
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	counter := &countingWriter{}
+	if err := c.copy(io.MultiWriter(md5Hash, sha256Hash, counter), asset); err != nil {
+		return nil, err
+	}
+	d := &digest{
+		size:   counter.n,
+		md5:    hex.EncodeToString(md5Hash.Sum(nil)),
+		sha256: hex.EncodeToString(sha256Hash.Sum(nil)),
+	}
+	c.hashes[asset.ID] = d
+	return d, nil
+}
+
+// read downloads a small asset, refusing ones larger than limit
+func (c *releaseCheck) read(asset *publish.RemoteAsset, limit int64) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.copy(&limitedWriter{w: &buf, n: limit}, asset); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *releaseCheck) copy(w io.Writer, asset *publish.RemoteAsset) error {
+	if c.opts.Verbose {
+		fmt.Printf("Downloading %s\n", asset.Name)
+	}
+	rc, err := c.publisher.DownloadAsset(c.ctx, c.release, asset)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", asset.Name, err)
+	}
+	defer rc.Close()
+	if _, err := io.Copy(w, rc); err != nil {
+		return fmt.Errorf("failed to download %s: %w", asset.Name, err)
+	}
+	return nil
+}
+
+type countingWriter struct{ n int64 }
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+type limitedWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > w.n {
+		return 0, fmt.Errorf("larger than expected")
+	}
+	w.n -= int64(len(p))
+	return w.w.Write(p)
+}
@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/publish"
+)
+
+// stubPublisher serves a fixed list of assets; contents missing from files
+// fail to download
+type stubPublisher struct {
+	publish.ReleasePublisher
+	assets []*publish.RemoteAsset
+	files  map[string]string
+}
+
+func (p *stubPublisher) Forge() string { return "gitea" }
+func (p *stubPublisher) Repo() string  { return "o/r" }
+
+func (p *stubPublisher) Assets(ctx context.Context, release *publish.RemoteRelease) ([]*publish.RemoteAsset, error) {
+	return p.assets, nil
+}
+
+func (p *stubPublisher) DownloadAsset(ctx context.Context, release *publish.RemoteRelease, asset *publish.RemoteAsset) (io.ReadCloser, error) {
+	data, ok := p.files[asset.Name]
+	if !ok {
+		return nil, fmt.Errorf("404 Not Found")
+	}
+	return io.NopCloser(strings.NewReader(data)), nil
+}
+
+func sum(data string) string {
+	h := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(h[:])
+}
+
+func TestCheckFileOnlyPassesHashedContents(t *testing.T) {
+	const contents = "disk image"
+	entry := &manifest.Entry{Version: "0.2.1", Platform: "darwin-arm64", Size: int64(len(contents)), SHA256: sum(contents)}
+	expected := []Expected{{Name: "qoder.dmg", Entry: entry}}
+
+	for _, tc := range []struct {
+		name     string
+		download bool
+		digest   string // Reported by the forge
+		notes    string // Checksum recorded in the release notes
+		files    map[string]string
+		want     Status
+	}{
+		{name: "size only", want: StatusUnverified},
+		{name: "notes checksum only", notes: sum(contents), want: StatusUnverified},
+		{name: "notes checksum differs", notes: sum("other"), want: StatusCorrupted},
+		{name: "forge digest", digest: sum(contents), want: StatusOK},
+		{name: "forge digest differs", digest: sum("other"), want: StatusCorrupted},
+		{name: "downloaded", download: true, files: map[string]string{"qoder.dmg": contents}, want: StatusOK},
+		{name: "downloaded differs", download: true, files: map[string]string{"qoder.dmg": "disk imag3"}, want: StatusCorrupted},
+		{name: "download fails", download: true, want: StatusFailed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			publisher := &stubPublisher{
+				assets: []*publish.RemoteAsset{{ID: 1, Name: "qoder.dmg", Size: entry.Size, Uploaded: true, SHA256: tc.digest}},
+				files:  tc.files,
+			}
+			body := "notes"
+			if tc.notes != "" {
+				body = publish.WithChecksums(body, map[string]string{"qoder.dmg": tc.notes})
+			}
+			release, err := New(publisher, Options{Download: tc.download}).Release(context.Background(), "0.2.1", &publish.RemoteRelease{Tag: "v0.2.1", Body: body}, expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var result *Result
+			for i := range release.Results {
+				if release.Results[i].Asset == "qoder.dmg" {
+					result = &release.Results[i]
+				}
+			}
+			if result == nil || result.Status != tc.want {
+				t.Fatalf("result = %+v, want status %s", result, tc.want)
+			}
+
+			report := &Report{}
+			report.Add(&Release{Results: []Result{*result}})
+			if ok := tc.want == StatusOK || tc.want == StatusUnverified; report.OK() != ok {
+				t.Errorf("OK() = %v with status %s", report.OK(), tc.want)
+			}
+		})
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/vibe-coding-labs/qoder-downloader/internal/detector"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
 )
 
 // Manager handles cache operations
@@ -15,6 +17,8 @@ type Manager struct {
 	cacheDir      string
 	requestedFile string
 	existingFile  string
+	artifactsFile string
+	firstSeenFile string
 	verbose       bool
 	ttl           int64 // Default TTL in hours (currently unused for text format)
 }
@@ -32,11 +36,15 @@ func NewManager(cacheDir string, verbose bool, ttl int64) (*Manager, error) {
 
 	requestedFile := filepath.Join(cacheDir, "requested_versions.txt")
 	existingFile := filepath.Join(cacheDir, "existing_versions.txt")
+	artifactsFile := filepath.Join(cacheDir, "existing_artifacts.txt")
+	firstSeenFile := filepath.Join(cacheDir, "first_seen.txt")
 
 	m := &Manager{
 		cacheDir:      cacheDir,
 		requestedFile: requestedFile,
 		existingFile:  existingFile,
+		artifactsFile: artifactsFile,
+		firstSeenFile: firstSeenFile,
 		verbose:       verbose,
 		ttl:           ttl,
 	}
@@ -126,6 +134,31 @@ func (m *Manager) AddExisting(version string) {
 	if !m.IsExisting(version) {
 		m.appendVersionToFile(m.existingFile, version)
 	}
+	if _, ok := m.FirstSeen(version); !ok {
+		m.appendVersionToFile(m.firstSeenFile, version+" "+time.Now().UTC().Format(time.RFC3339))
+	}
+}
+
+// FirstSeen returns when a version was first found to exist. Versions found
+// before dates were recorded have none.
+func (m *Manager) FirstSeen(version string) (time.Time, bool) {
+	file, err := os.Open(m.firstSeenFile)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != version {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, fields[1]); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
 }
 
 // Get returns whether a version has been requested and whether it exists
@@ -157,6 +190,98 @@ func (m *Manager) GetValidVersions() []detector.Version {
 	return result
 }
 
+// HasArtifacts reports whether the artifacts of a version have been recorded
+func (m *Manager) HasArtifacts(version string) bool {
+	return m.GetArtifacts(version) != nil
+}
+
+// GetArtifactRule returns the URL rule an artifact of a version was found
+// with, or "" if it is not recorded
+func (m *Manager) GetArtifactRule(version, platformName, artifact string) string {
+	lines, err := m.readArtifactLines()
+	if err != nil {
+		return ""
+	}
+	for _, fields := range lines {
+		if fields[0] == version && fields[1] == platformName && fields[2] == artifact {
+			return fields[3]
+		}
+	}
+	return ""
+}
+
+// GetArtifacts returns the recorded artifact names of a version by platform,
+// or nil if the version's artifacts have not been checked
+func (m *Manager) GetArtifacts(version string) map[string][]string {
+	lines, err := m.readArtifactLines()
+	if err != nil {
+		return nil
+	}
+
+	var result map[string][]string
+	for _, fields := range lines {
+		if fields[0] != version {
+			continue
+		}
+		if result == nil {
+			result = make(map[string][]string)
+		}
+		result[fields[1]] = append(result[fields[1]], fields[2])
+	}
+	return result
+}
+
+// SetArtifacts replaces the recorded artifacts of a version. Lines in the
+// artifacts file have the form "<version> <platform> <artifact> <rule>".
+func (m *Manager) SetArtifacts(version string, artifacts []detector.Artifact) error {
+	lines, err := m.readArtifactLines()
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, fields := range lines {
+		if fields[0] != version {
+			b.WriteString(strings.Join(fields, " ") + "\n")
+		}
+	}
+	for _, a := range artifacts {
+		fmt.Fprintf(&b, "%s %s %s %s\n", version, a.Platform, a.Name, a.Rule)
+	}
+
+	tmp := m.artifactsFile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.artifactsFile)
+}
+
+// readArtifactLines reads the artifacts file as version, platform, artifact
+// and rule fields. Lines written before rules were recorded get "default".
+func (m *Manager) readArtifactLines() ([][]string, error) {
+	file, err := os.Open(m.artifactsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines [][]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 {
+			fields = append(fields, platform.DefaultRule)
+		}
+		if len(fields) == 4 {
+			lines = append(lines, fields)
+		}
+	}
+	return lines, scanner.Err()
+}
+
 // GetRequestedVersions returns all requested versions
 func (m *Manager) GetRequestedVersions() []string {
 	versions, err := m.readVersionsFromFile(m.requestedFile)
@@ -185,7 +310,8 @@ func (m *Manager) GetExistingVersions() []string {
 	return result
 }
 
-// Clear removes all cache files
+// Clear removes all cache files. First-seen dates are kept, since they
+// cannot be recovered by detecting again.
 func (m *Manager) Clear() error {
 	var errors []string
 
@@ -197,6 +323,10 @@ func (m *Manager) Clear() error {
 		errors = append(errors, fmt.Sprintf("failed to remove %s: %v", m.existingFile, err))
 	}
 
+	if err := os.Remove(m.artifactsFile); err != nil && !os.IsNotExist(err) {
+		errors = append(errors, fmt.Sprintf("failed to remove %s: %v", m.artifactsFile, err))
+	}
+
 	if len(errors) > 0 {
 		return fmt.Errorf("cache clear errors: %s", strings.Join(errors, "; "))
 	}
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/detector"
+)
+
+func newManager(t *testing.T) (*Manager, string) {
+	t.Helper()
+	dir := t.TempDir()
+	m, err := NewManager(dir, false, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, dir
+}
+
+func TestFirstSeen(t *testing.T) {
+	m, dir := newManager(t)
+
+	if _, ok := m.FirstSeen("0.2.1"); ok {
+		t.Error("first-seen date of an unknown version")
+	}
+
+	before := time.Now().UTC().Truncate(time.Second)
+	m.Set("0.2.1", true)
+	seen, ok := m.FirstSeen("0.2.1")
+	if !ok || seen.Before(before) || seen.After(time.Now()) {
+		t.Fatalf("first seen %v, %v; want a date from now", seen, ok)
+	}
+
+	// Finding the version again keeps the first date
+	m.AddExisting("0.2.1")
+	if again, _ := m.FirstSeen("0.2.1"); !again.Equal(seen) {
+		t.Errorf("first seen moved from %v to %v", seen, again)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "first_seen.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "0.2.1 " + seen.Format(time.RFC3339) + "\n"; string(data) != want {
+		t.Errorf("first_seen.txt = %q, want %q", data, want)
+	}
+
+	// Requested versions that do not exist are not dated
+	m.Set("0.2.2", false)
+	if _, ok := m.FirstSeen("0.2.2"); ok {
+		t.Error("a missing version has a first-seen date")
+	}
+
+	// Versions recorded before dates were kept have none, and bad lines are skipped
+	if err := os.WriteFile(filepath.Join(dir, "first_seen.txt"), []byte("0.1.0 not-a-date\n0.1.1\n0.1.2 2025-01-02T03:04:05Z\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.FirstSeen("0.1.0"); ok {
+		t.Error("first-seen date from an unparsable line")
+	}
+	if seen, ok := m.FirstSeen("0.1.2"); !ok || !seen.Equal(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("first seen %v, %v", seen, ok)
+	}
+}
+
+func TestSetArtifacts(t *testing.T) {
+	m, dir := newManager(t)
+
+	if m.HasArtifacts("0.2.1") || m.GetArtifacts("0.2.1") != nil {
+		t.Error("artifacts of an unchecked version")
+	}
+
+	err := m.SetArtifacts("0.2.1", []detector.Artifact{
+		{Platform: "darwin-arm64", Name: "dmg", Rule: "default"},
+		{Platform: "darwin-arm64", Name: "zip", Rule: "default"},
+		{Platform: "linux-x64", Name: "deb", Rule: "old-names"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetArtifacts("0.2.0", []detector.Artifact{{Platform: "linux-x64", Name: "AppImage", Rule: "default"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{"darwin-arm64": {"dmg", "zip"}, "linux-x64": {"deb"}}
+	if got := m.GetArtifacts("0.2.1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetArtifacts = %v, want %v", got, want)
+	}
+	if rule := m.GetArtifactRule("0.2.1", "linux-x64", "deb"); rule != "old-names" {
+		t.Errorf("rule %q, want old-names", rule)
+	}
+	if rule := m.GetArtifactRule("0.2.1", "linux-x64", "rpm"); rule != "" {
+		t.Errorf("rule of an unrecorded artifact %q", rule)
+	}
+
+	// Setting a version again replaces its artifacts and keeps the others
+	if err := m.SetArtifacts("0.2.1", []detector.Artifact{{Platform: "win32-x64", Name: "exe", Rule: "default"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetArtifacts("0.2.1"); !reflect.DeepEqual(got, map[string][]string{"win32-x64": {"exe"}}) {
+		t.Errorf("after replacing: %v", got)
+	}
+	if !m.HasArtifacts("0.2.0") {
+		t.Error("replacing one version dropped another")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "existing_artifacts.txt.tmp")); !os.IsNotExist(err) {
+		t.Error("temporary file left behind")
+	}
+}
+
+func TestArtifactLinesWithoutRule(t *testing.T) {
+	m, dir := newManager(t)
+	// Lines written before rules were recorded
+	if err := os.WriteFile(filepath.Join(dir, "existing_artifacts.txt"), []byte("0.1.0 linux-x64 deb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if rule := m.GetArtifactRule("0.1.0", "linux-x64", "deb"); rule != "default" {
+		t.Errorf("rule %q, want default", rule)
+	}
+
+	// They are rewritten with the rule when another version is set
+	if err := m.SetArtifacts("0.1.1", nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "existing_artifacts.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "0.1.0 linux-x64 deb default\n" {
+		t.Errorf("existing_artifacts.txt = %q", data)
+	}
+}
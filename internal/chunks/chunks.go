@@ -0,0 +1,210 @@
+// Package chunks splits files that are too large for a forge into numbered
+// parts, described by a manifest that lets them be joined and verified again.
+package chunks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestSuffix is appended to a file name to name its parts manifest
+const ManifestSuffix = ".parts.json"
+
+// Manifest describes a file split into parts
+type Manifest struct {
+	Name   string  `json:"name"`
+	Size   int64   `json:"size"`
+	SHA256 string  `json:"sha256"`
+	Parts  []*Part `json:"parts"`
+}
+
+// Part is one piece of a split file, in order
+type Part struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ManifestName returns the name of the parts manifest of a file
+func ManifestName(name string) string {
+	return name + ManifestSuffix
+}
+
+// PartName returns the name of the n-th part of a file, counting from 1
+func PartName(name string, n int) string {
+	return fmt.Sprintf("%s.%03d", name, n)
+}
+
+// Split cuts the file at path into parts of at most partSize bytes, written
+// to dir with the parts manifest. name is what the joined file is called;
+// sha256 is its checksum, which the file must still match. The manifest is
+// returned along with its path.
+func Split(path, name, sha256sum string, partSize int64, dir string) (*Manifest, string, error) {
+	if partSize <= 0 {
+		return nil, "", fmt.Errorf("invalid part size %d", partSize)
+	}
+	if !isSHA256(sha256sum) {
+		return nil, "", fmt.Errorf("invalid SHA-256 %q for %s", sha256sum, name)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, "", err
+	}
+
+	m := &Manifest{Name: name, Size: info.Size(), SHA256: sha256sum}
+	whole := sha256.New()
+	for n, offset := 1, int64(0); offset < info.Size(); n, offset = n+1, offset+partSize {
+		part := &Part{Name: PartName(name, n)}
+		r := io.TeeReader(io.NewSectionReader(f, offset, partSize), whole)
+		if part.Size, part.SHA256, err = copyPart(filepath.Join(dir, part.Name), r); err != nil {
+			return nil, "", err
+		}
+		m.Parts = append(m.Parts, part)
+	}
+	if sum := hex.EncodeToString(whole.Sum(nil)); sum != sha256sum {
+		return nil, "", fmt.Errorf("%s has SHA-256 %s, want %s", path, sum, sha256sum)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+	manifestPath := filepath.Join(dir, ManifestName(name))
+	if err := os.WriteFile(manifestPath, append(data, '\n'), 0644); err != nil {
+		return nil, "", err
+	}
+	return m, manifestPath, nil
+}
+
+func copyPart(path string, r io.Reader) (int64, string, error) {
+	out, err := os.Create(path)
+	if err != nil {
+		return 0, "", err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Load reads a parts manifest
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Parse decodes a parts manifest, rejecting names that are not plain file
+// names and missing checksums, without which a joined file cannot be verified
+func Parse(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if m.Name == "" || len(m.Parts) == 0 {
+		return nil, fmt.Errorf("not a parts manifest")
+	}
+	if !isSHA256(m.SHA256) {
+		return nil, fmt.Errorf("invalid SHA-256 %q for %s", m.SHA256, m.Name)
+	}
+	for _, part := range m.Parts {
+		if part.Name == "" || strings.ContainsAny(part.Name, `/\`) {
+			return nil, fmt.Errorf("invalid part name %q", part.Name)
+		}
+		if !isSHA256(part.SHA256) {
+			return nil, fmt.Errorf("invalid SHA-256 %q for %s", part.SHA256, part.Name)
+		}
+	}
+	if strings.ContainsAny(m.Name, `/\`) {
+		return nil, fmt.Errorf("invalid file name %q", m.Name)
+	}
+	return &m, nil
+}
+
+// Join checks every part found in dir against the manifest and writes the
+// joined file to output, which is only created once the whole file has
+// matched the recorded size and checksum
+func Join(m *Manifest, dir, output string) error {
+	tmp := output + ".joining"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	h := sha256.New()
+	var size int64
+	for _, part := range m.Parts {
+		n, err := appendPart(io.MultiWriter(out, h), filepath.Join(dir, part.Name), part)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		size += n
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if size != m.Size {
+		return fmt.Errorf("joined size %d, want %d", size, m.Size)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != m.SHA256 {
+		return fmt.Errorf("joined file has SHA-256 %s, want %s", sum, m.SHA256)
+	}
+	return os.Rename(tmp, output)
+}
+
+// appendPart copies a part to w after checking it on its own, so that a bad
+// part is named rather than only failing the final checksum
+func appendPart(w io.Writer, path string, part *Part) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), f)
+	if err != nil {
+		return n, err
+	}
+	if n != part.Size {
+		return n, fmt.Errorf("%s has size %d, want %d", part.Name, n, part.Size)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != part.SHA256 {
+		return n, fmt.Errorf("%s has SHA-256 %s, want %s", part.Name, sum, part.SHA256)
+	}
+	return n, nil
+}
+
+// isSHA256 reports whether s is a hex-encoded SHA-256 digest
+func isSHA256(s string) bool {
+	if len(s) != hex.EncodedLen(sha256.Size) {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,140 @@
+package chunks
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// splitFile writes contents to a file and splits it into parts of partSize bytes
+func splitFile(t *testing.T, contents []byte, partSize int64) (*Manifest, string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Qoder-linux-x64.AppImage")
+	if err := os.WriteFile(path, contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(contents)
+	parts := t.TempDir()
+	m, manifestPath, err := Split(path, "Qoder-linux-x64.AppImage", hex.EncodeToString(sum[:]), partSize, parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifestPath != filepath.Join(parts, "Qoder-linux-x64.AppImage.parts.json") {
+		t.Errorf("manifest written to %s", manifestPath)
+	}
+	return m, parts
+}
+
+func TestSplitJoinRoundTrip(t *testing.T) {
+	contents := bytes.Repeat([]byte("0123456789"), 25)
+	m, dir := splitFile(t, contents, 100)
+
+	if len(m.Parts) != 3 || m.Parts[0].Name != "Qoder-linux-x64.AppImage.001" || m.Parts[2].Size != 50 {
+		t.Fatalf("parts %+v", m.Parts)
+	}
+
+	loaded, err := Load(filepath.Join(dir, ManifestName(m.Name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(t.TempDir(), "joined")
+	if err := Join(loaded, dir, output); err != nil {
+		t.Fatal(err)
+	}
+	joined, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(joined, contents) {
+		t.Error("joined file differs from the original")
+	}
+}
+
+func TestJoinRejectsBadParts(t *testing.T) {
+	contents := bytes.Repeat([]byte("0123456789"), 25)
+
+	for _, tc := range []struct {
+		name   string
+		damage func(path string) error
+		want   string
+	}{
+		{"corrupted", func(path string) error {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			data[10] ^= 0xff
+			return os.WriteFile(path, data, 0644)
+		}, "Qoder-linux-x64.AppImage.002 has SHA-256"},
+		{"truncated", func(path string) error {
+			return os.Truncate(path, 60)
+		}, "Qoder-linux-x64.AppImage.002 has size 60, want 100"},
+		{"missing", os.Remove, "Qoder-linux-x64.AppImage.002"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, dir := splitFile(t, contents, 100)
+			if err := tc.damage(filepath.Join(dir, m.Parts[1].Name)); err != nil {
+				t.Fatal(err)
+			}
+
+			output := filepath.Join(t.TempDir(), "joined")
+			err := Join(m, dir, output)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Join error %v, want it to mention %q", err, tc.want)
+			}
+			if _, err := os.Stat(output); !os.IsNotExist(err) {
+				t.Error("output created from bad parts")
+			}
+			if _, err := os.Stat(output + ".joining"); !os.IsNotExist(err) {
+				t.Error("temporary file left behind")
+			}
+		})
+	}
+}
+
+func TestJoinChecksWholeFile(t *testing.T) {
+	m, dir := splitFile(t, []byte("some contents"), 5)
+	m.SHA256 = strings.Repeat("0", 64)
+	if err := Join(m, dir, filepath.Join(t.TempDir(), "joined")); err == nil {
+		t.Error("joined a file that does not match its checksum")
+	}
+}
+
+func TestSplitChecksFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte("changed since it was hashed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, sum := range []string{"", strings.Repeat("0", 64)} {
+		if _, _, err := Split(path, "file", sum, 10, t.TempDir()); err == nil {
+			t.Errorf("split with SHA-256 %q", sum)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	for _, tc := range []struct {
+		name  string
+		json  string
+		valid bool
+	}{
+		{"valid", `{"name": "f", "size": 1, "sha256": "` + sum + `", "parts": [{"name": "f.001", "size": 1, "sha256": "` + sum + `"}]}`, true},
+		{"no checksum", `{"name": "f", "size": 1, "parts": [{"name": "f.001", "size": 1, "sha256": "` + sum + `"}]}`, false},
+		{"bad checksum", `{"name": "f", "size": 1, "sha256": "abc", "parts": [{"name": "f.001", "size": 1, "sha256": "` + sum + `"}]}`, false},
+		{"no part checksum", `{"name": "f", "size": 1, "sha256": "` + sum + `", "parts": [{"name": "f.001", "size": 1}]}`, false},
+		{"no parts", `{"name": "f", "size": 1, "sha256": "` + sum + `", "parts": []}`, false},
+		{"part outside the directory", `{"name": "f", "size": 1, "sha256": "` + sum + `", "parts": [{"name": "../f.001", "size": 1, "sha256": "` + sum + `"}]}`, false},
+		{"file outside the directory", `{"name": "../f", "size": 1, "sha256": "` + sum + `", "parts": [{"name": "f.001", "size": 1, "sha256": "` + sum + `"}]}`, false},
+	} {
+		_, err := Parse([]byte(tc.json))
+		if (err == nil) != tc.valid {
+			t.Errorf("%s: err = %v", tc.name, err)
+		}
+	}
+}
@@ -1,13 +1,17 @@
 package detector
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"path"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
 )
 
 // Version represents a semantic version
@@ -23,6 +27,12 @@ func (v Version) String() string {
 	return v.Raw
 }
 
+// Prerelease reports whether the version is a pre-release build, marked by a
+// suffix such as "-beta.1"
+func (v Version) Prerelease() bool {
+	return strings.Contains(v.Raw, "-")
+}
+
 // Compare compares two versions. Returns -1 if v < other, 0 if v == other, 1 if v > other
 func (v Version) Compare(other Version) int {
 	if v.Major != other.Major {
@@ -48,7 +58,6 @@ func (v Version) Compare(other Version) int {
 
 // Detector handles version detection for Qoder releases
 type Detector struct {
-	baseURL    string
 	httpClient *http.Client
 	verbose    bool
 }
@@ -56,7 +65,6 @@ type Detector struct {
 // NewDetector creates a new version detector
 func NewDetector(verbose bool) *Detector {
 	return &Detector{
-		baseURL: "https://download.qoder.com/release",
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
@@ -100,19 +108,83 @@ func ParseVersion(versionStr string) (Version, error) {
 }
 
 // CheckVersion checks if a specific version exists
-func (d *Detector) CheckVersion(version string) (bool, error) {
+func (d *Detector) CheckVersion(ctx context.Context, version string) (bool, error) {
 	if d.verbose {
 		fmt.Printf("Checking version: %s\n", version)
 	}
 
-	// For bruteforce, only check one platform to avoid unnecessary requests
-	pattern := "Qoder-darwin-arm64.dmg"
-	url := fmt.Sprintf("%s/%s/%s", d.baseURL, version, pattern)
+	// For bruteforce, only check one platform to avoid unnecessary requests.
+	// The first platform in the registry is the probe.
+	probe := platform.ActiveRegistry().Platforms[0]
+	candidate, err := d.findCandidate(ctx, version, probe)
+	return candidate != nil, err
+}
+
+// findCandidate tries every URL rule that applies to a version and returns
+// the first candidate upstream serves, or nil if there is none
+func (d *Detector) findCandidate(ctx context.Context, version string, p platform.PlatformInfo) (*platform.Candidate, error) {
+	for _, candidate := range platform.DownloadCandidates(version, p) {
+		ok, err := d.exists(ctx, candidate.URL)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &candidate, nil
+		}
+	}
+	return nil, nil
+}
+
+// Artifact is a file upstream publishes for a version
+type Artifact struct {
+	Platform string
+	Name     string // Artifact name as accepted by --artifact
+	URL      string
+	Rule     string // URL rule the artifact was found with
+}
+
+// CheckArtifacts reports which artifacts of every platform exist for a version
+func (d *Detector) CheckArtifacts(ctx context.Context, version string) ([]Artifact, error) {
+	if d.verbose {
+		fmt.Printf("Checking artifacts of version: %s\n", version)
+	}
+
+	var found []Artifact
+	for _, p := range platform.GetAllPlatforms() {
+		for _, name := range p.ArtifactNames() {
+			variant, _ := p.WithArtifact(name)
+			candidate, err := d.findCandidate(ctx, version, variant)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				return nil, fmt.Errorf("failed to check %s %s: %w", p.Name, name, err)
+			}
+			if candidate != nil {
+				found = append(found, Artifact{Platform: p.Name, Name: name, URL: candidate.URL, Rule: candidate.Rule})
+			}
+		}
+	}
+
+	return found, nil
+}
+
+// exists reports whether upstream serves the given URL. Only 404, and the
+// 403 and 410 the CDN answers for missing objects, mean it does not; other
+// statuses, such as server errors or rate limiting, are errors so that a
+// failing upstream is not taken for a missing file.
+func (d *Detector) exists(ctx context.Context, url string) (bool, error) {
+	pattern := path.Base(url)
 	if d.verbose {
 		fmt.Printf("  Checking URL: %s\n", url)
 	}
 
-	resp, err := d.httpClient.Head(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := d.httpClient.Do(req)
 	if err != nil {
 		if d.verbose {
 			fmt.Printf("  Error: %v\n", err)
@@ -128,11 +200,14 @@ func (d *Detector) CheckVersion(version string) (bool, error) {
 		return true, nil
 	}
 
-	if d.verbose {
-		fmt.Printf("  Not found: %s (Status: %d)\n", pattern, resp.StatusCode)
+	switch resp.StatusCode {
+	case http.StatusNotFound, http.StatusForbidden, http.StatusGone:
+		if d.verbose {
+			fmt.Printf("  Not found: %s (Status: %d)\n", pattern, resp.StatusCode)
+		}
+		return false, nil
 	}
-
-	return false, nil
+	return false, fmt.Errorf("unexpected status %s for %s", resp.Status, url)
 }
 
 // GenerateVersionCandidates generates a list of version candidates to check
@@ -156,4 +231,28 @@ func SortVersions(versions []Version) {
 	sort.Slice(versions, func(i, j int) bool {
 		return versions[i].Compare(versions[j]) < 0
 	})
-}
\ No newline at end of file
+}
+
+// CompareVersionStrings compares two version strings numerically like
+// Version.Compare. Unparsable versions sort before all others, and among
+// themselves as plain strings.
+func CompareVersionStrings(a, b string) int {
+	va, errA := ParseVersion(a)
+	vb, errB := ParseVersion(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	return va.Compare(vb)
+}
+
+// SortVersionStrings sorts version strings in ascending order, see CompareVersionStrings
+func SortVersionStrings(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return CompareVersionStrings(versions[i], versions[j]) < 0
+	})
+}
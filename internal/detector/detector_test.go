@@ -0,0 +1,43 @@
+package detector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestExistsOnlyTreatsMissingStatusesAsMissing(t *testing.T) {
+	for _, tc := range []struct {
+		status  int
+		exists  bool
+		wantErr bool
+	}{
+		{http.StatusOK, true, false},
+		{http.StatusNotFound, false, false},
+		{http.StatusForbidden, false, false},
+		{http.StatusGone, false, false},
+		{http.StatusTooManyRequests, false, true},
+		{http.StatusInternalServerError, false, true},
+		{http.StatusBadGateway, false, true},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+		}))
+		exists, err := NewDetector(false).exists(context.Background(), srv.URL+"/Qoder.dmg")
+		srv.Close()
+		if exists != tc.exists || (err != nil) != tc.wantErr {
+			t.Errorf("status %d: exists = %v, err = %v", tc.status, exists, err)
+		}
+	}
+}
+
+func TestSortVersionStrings(t *testing.T) {
+	versions := []string{"0.10.0", "v0.2.10", "nightly", "0.2.9", "1.0.0", "0.2.10-beta.1"}
+	SortVersionStrings(versions)
+	want := []string{"nightly", "0.2.9", "v0.2.10", "0.2.10-beta.1", "0.10.0", "1.0.0"}
+	if !reflect.DeepEqual(versions, want) {
+		t.Errorf("sorted %v, want %v", versions, want)
+	}
+}
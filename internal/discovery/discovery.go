@@ -0,0 +1,390 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+)
+
+// Config lists the name parts combined into candidate filenames
+type Config struct {
+	Products    []string `mapstructure:"products" json:"products"`
+	OSes        []string `mapstructure:"oses" json:"oses"`
+	Archs       []string `mapstructure:"archs" json:"archs"`
+	Extensions  []string `mapstructure:"extensions" json:"extensions"`
+	Patterns    []string `mapstructure:"patterns" json:"patterns"`         // Filename templates using {product}, {os}, {arch}, {ext} and {version}
+	URLTemplate string   `mapstructure:"url_template" json:"url_template"` // Uses {base_url}, {version} and {filename}
+}
+
+// DefaultConfig returns the name parts probed when none are configured
+func DefaultConfig() Config {
+	return Config{
+		Products:    []string{"Qoder", "QoderSetup", "QoderUserSetup"},
+		OSes:        []string{"darwin", "linux", "win32"},
+		Archs:       []string{"x64", "arm64", "universal", "ia32"},
+		Extensions:  []string{"dmg", "zip", "exe", "msi", "AppImage", "deb", "rpm", "tar.gz"},
+		Patterns:    []string{"{product}-{os}-{arch}.{ext}", "{product}-{arch}.{ext}"},
+		URLTemplate: "{base_url}/{version}/{filename}",
+	}
+}
+
+// Merge fills the fields left empty in c from defaults
+func (c Config) Merge(defaults Config) Config {
+	if len(c.Products) == 0 {
+		c.Products = defaults.Products
+	}
+	if len(c.OSes) == 0 {
+		c.OSes = defaults.OSes
+	}
+	if len(c.Archs) == 0 {
+		c.Archs = defaults.Archs
+	}
+	if len(c.Extensions) == 0 {
+		c.Extensions = defaults.Extensions
+	}
+	if len(c.Patterns) == 0 {
+		c.Patterns = defaults.Patterns
+	}
+	if c.URLTemplate == "" {
+		c.URLTemplate = defaults.URLTemplate
+	}
+	return c
+}
+
+// Probe is one candidate file
+type Probe struct {
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+	Product  string `json:"product"`
+	OS       string `json:"os,omitempty"` // Empty when the pattern does not name the OS
+	Arch     string `json:"arch"`
+	Ext      string `json:"ext"`
+	Known    bool   `json:"known"` // Already produced by the platform registry
+}
+
+// Found is a candidate upstream serves
+type Found struct {
+	Probe
+	Size int64 `json:"size"` // -1 when upstream does not report a Content-Length
+}
+
+var tokenPattern = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// Probes expands the configuration into the distinct candidate files of a version
+func (c Config) Probes(version string, registry *platform.Registry) []Probe {
+	known := knownURLs(version, registry)
+
+	seen := make(map[string]bool)
+	var probes []Probe
+	for _, pattern := range c.Patterns {
+		oses := c.OSes
+		if !strings.Contains(pattern, "{os}") {
+			oses = []string{""}
+		}
+		for _, product := range c.Products {
+			for _, osName := range oses {
+				for _, arch := range c.Archs {
+					for _, ext := range c.Extensions {
+						values := map[string]string{"product": product, "os": osName, "arch": arch, "ext": ext, "version": version}
+						filename := expand(pattern, values)
+						values["base_url"] = strings.TrimSuffix(registry.BaseURL, "/")
+						values["filename"] = filename
+						url := expand(c.URLTemplate, values)
+						if seen[url] {
+							continue
+						}
+						seen[url] = true
+						probes = append(probes, Probe{
+							Filename: filename,
+							URL:      url,
+							Product:  product,
+							OS:       osName,
+							Arch:     arch,
+							Ext:      ext,
+							Known:    known[url],
+						})
+					}
+				}
+			}
+		}
+	}
+	return probes
+}
+
+// Check HEADs every probe with the given number of workers and returns those
+// upstream serves, sorted by filename. Only a 404 means a file is absent; the
+// probes that failed otherwise are returned as errors next to what was found.
+func Check(ctx context.Context, probes []Probe, workers int) ([]Found, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var (
+		mu     sync.Mutex
+		found  []Found
+		failed []error
+		wg     sync.WaitGroup
+	)
+	work := make(chan Probe)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for probe := range work {
+				size, err := head(ctx, client, probe.URL)
+				if errors.Is(err, errAbsent) || ctx.Err() != nil {
+					continue
+				}
+				mu.Lock()
+				if err != nil {
+					failed = append(failed, fmt.Errorf("%s: %w", probe.Filename, err))
+				} else {
+					found = append(found, Found{Probe: probe, Size: size})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+send:
+	for _, probe := range probes {
+		select {
+		case work <- probe:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Filename < found[j].Filename
+	})
+	if len(failed) > 0 {
+		sort.Slice(failed, func(i, j int) bool {
+			return failed[i].Error() < failed[j].Error()
+		})
+		return found, fmt.Errorf("%d of %d candidates could not be checked:\n%w", len(failed), len(probes), errors.Join(failed...))
+	}
+	return found, nil
+}
+
+var errAbsent = errors.New("not found")
+
+// head returns the size upstream reports for url, -1 when it reports none,
+// or errAbsent when upstream answers 404
+func head(ctx context.Context, client *http.Client, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.ContentLength, nil
+	case http.StatusNotFound:
+		return 0, errAbsent
+	}
+	return 0, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// knownURLs returns every URL the registry produces for a version
+func knownURLs(version string, registry *platform.Registry) map[string]bool {
+	known := make(map[string]bool)
+	for _, p := range registry.Platforms {
+		for _, variant := range p.Variants() {
+			for _, candidate := range registry.Candidates(version, variant) {
+				known[candidate.URL] = true
+			}
+		}
+	}
+	return known
+}
+
+func expand(template string, values map[string]string) string {
+	return tokenPattern.ReplaceAllStringFunc(template, func(token string) string {
+		return values[strings.Trim(token, "{}")]
+	})
+}
+
+// Promote adds newly found files to a registry overlay so that later
+// detection and downloads include them. Files of a known platform become
+// artifacts of it; others become new platforms named "<os>-<arch>". It
+// returns a description of every change.
+func Promote(overlay *platform.Registry, active *platform.Registry, version string, found []Found) ([]string, error) {
+	var changes []string
+	for _, f := range found {
+		if f.Known {
+			continue
+		}
+		osName := f.OS
+		if osName == "" {
+			var err error
+			if osName, err = osForExtension(f.Ext); err != nil {
+				changes = append(changes, fmt.Sprintf("skipped %s: %v", f.Filename, err))
+				continue
+			}
+		}
+		name := osName + "-" + f.Arch
+		filename := strings.ReplaceAll(f.Filename, version, "{version}")
+
+		entry := overlayPlatform(overlay, name)
+		if promoted(*entry, filename) {
+			changes = append(changes, fmt.Sprintf("skipped %s: already promoted", f.Filename))
+			continue
+		}
+		existing, exists := active.Find(name)
+		if !exists && entry.Extension == "" {
+			entry.OS, entry.Arch, entry.Extension, entry.Filename = goOS(osName), goArch(f.Arch), f.Ext, filename
+			changes = append(changes, fmt.Sprintf("added platform %s (%s)", name, filename))
+			continue
+		}
+
+		// Extensions name local files, so a second file of the same kind
+		// is told apart by its product
+		used := make(map[string]bool)
+		for _, n := range append(existing.ArtifactNames(), entry.ArtifactNames()...) {
+			used[n] = true
+		}
+		for _, variant := range append(existing.Variants(), entry.Variants()...) {
+			used[variant.Extension] = true
+		}
+		artifact := platform.Artifact{Extension: f.Ext, Filename: filename}
+		if used[f.Ext] {
+			artifact.Name = strings.ToLower(f.Product)
+			artifact.Extension = strings.ToLower(f.Product) + "." + f.Ext
+			if used[artifact.Name] || used[artifact.Extension] {
+				changes = append(changes, fmt.Sprintf("skipped %s: %s already has a %s artifact", f.Filename, name, artifact.Name))
+				continue
+			}
+		}
+		entry.Artifacts = append(entry.Artifacts, artifact)
+		changes = append(changes, fmt.Sprintf("added %s artifact %s (%s)", name, artifact.ArtifactName(), filename))
+	}
+
+	// Make sure the result still loads before anything is written
+	merged := active.Clone()
+	merged.Merge(overlay, "file")
+	if err := merged.Validate(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// PromoteFile promotes newly found files (see Promote) into the registry file
+// at path, creating it if needed. The file is only written when something
+// was added.
+func PromoteFile(path string, active *platform.Registry, version string, found []Found) ([]string, error) {
+	overlay := &platform.Registry{}
+	if data, err := os.ReadFile(path); err == nil {
+		if overlay, err = platform.ParseRegistry(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	before, err := yaml.Marshal(overlay)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := Promote(overlay, active, version, found)
+	if err != nil {
+		return nil, err
+	}
+	data, err := yaml.Marshal(overlay)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(data, before) {
+		return changes, nil
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// promoted reports whether an overlay platform already names filename, for
+// overlays that are not part of the active registry
+func promoted(p platform.PlatformInfo, filename string) bool {
+	if p.Filename == filename {
+		return true
+	}
+	for _, a := range p.Artifacts {
+		if a.Filename == filename {
+			return true
+		}
+	}
+	return false
+}
+
+// overlayPlatform returns the overlay entry for a platform, adding one if needed
+func overlayPlatform(overlay *platform.Registry, name string) *platform.PlatformInfo {
+	for i := range overlay.Platforms {
+		if overlay.Platforms[i].Name == name {
+			return &overlay.Platforms[i]
+		}
+	}
+	overlay.Platforms = append(overlay.Platforms, platform.PlatformInfo{Name: name})
+	return &overlay.Platforms[len(overlay.Platforms)-1]
+}
+
+// osForExtension guesses the upstream OS name of a file whose name does not include it
+func osForExtension(ext string) (string, error) {
+	switch ext {
+	case "exe", "msi":
+		return "win32", nil
+	case "dmg":
+		return "darwin", nil
+	case "AppImage", "deb", "rpm":
+		return "linux", nil
+	}
+	return "", fmt.Errorf("cannot tell the OS of a .%s file", ext)
+}
+
+// goOS maps an upstream OS name to Go's
+func goOS(osName string) string {
+	if osName == "win32" {
+		return "windows"
+	}
+	return osName
+}
+
+// goArch maps an upstream architecture name to Go's where there is one
+func goArch(arch string) string {
+	switch arch {
+	case "x64":
+		return "amd64"
+	case "ia32":
+		return "386"
+	}
+	return arch
+}
@@ -0,0 +1,176 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+)
+
+func TestCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/Qoder-linux-x64.deb":
+			w.Header().Set("Content-Length", "1234")
+		case "/Qoder-linux-x64.rpm":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/Qoder-linux-x64.snap":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	var probes []Probe
+	for _, name := range []string{"Qoder-linux-x64.deb", "Qoder-linux-x64.rpm", "Qoder-linux-x64.snap", "Qoder-linux-x64.zip"} {
+		probes = append(probes, Probe{Filename: name, URL: srv.URL + "/" + name})
+	}
+
+	found, err := Check(context.Background(), probes, 2)
+	if len(found) != 1 || found[0].Filename != "Qoder-linux-x64.deb" || found[0].Size != 1234 {
+		t.Errorf("found %+v, want only the .deb", found)
+	}
+	if err == nil {
+		t.Fatal("failed probes were not reported")
+	}
+	for _, name := range []string{"Qoder-linux-x64.rpm", "Qoder-linux-x64.snap"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error does not mention %s: %v", name, err)
+		}
+	}
+	if strings.Contains(err.Error(), "Qoder-linux-x64.zip") {
+		t.Errorf("a 404 was reported as an error: %v", err)
+	}
+}
+
+func TestCheckReportsUnreachableUpstream(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	_, err := Check(context.Background(), []Probe{{Filename: "Qoder-linux-x64.deb", URL: url + "/Qoder-linux-x64.deb"}}, 1)
+	if err == nil {
+		t.Error("an unreachable upstream was taken for missing files")
+	}
+}
+
+func found(filename, product, osName, arch, ext string) Found {
+	return Found{Probe: Probe{Filename: filename, Product: product, OS: osName, Arch: arch, Ext: ext}}
+}
+
+func TestPromote(t *testing.T) {
+	active := platform.DefaultRegistry()
+	overlay := &platform.Registry{}
+
+	changes, err := Promote(overlay, active, "0.2.1", []Found{
+		// Already in the registry
+		{Probe: Probe{Filename: "Qoder-linux-x64.deb", Known: true}},
+		// A new kind of file for a known platform
+		found("Qoder-linux-x64-0.2.1.snap", "Qoder", "linux", "x64", "snap"),
+		// A second .zip for a platform that already has one
+		found("QoderSetup-darwin-arm64.zip", "QoderSetup", "darwin", "arm64", "zip"),
+		// A platform the registry does not know
+		found("Qoder-linux-riscv64.AppImage", "Qoder", "linux", "riscv64", "AppImage"),
+		// No OS in the name and nothing to tell it by
+		found("Qoder-x64.tar.gz", "Qoder", "", "x64", "tar.gz"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"added linux-x64 artifact snap (Qoder-linux-x64-{version}.snap)",
+		"added darwin-arm64 artifact qodersetup (QoderSetup-darwin-arm64.zip)",
+		"added platform linux-riscv64 (Qoder-linux-riscv64.AppImage)",
+		"skipped Qoder-x64.tar.gz: cannot tell the OS of a .tar.gz file",
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes:\n  %s\nwant:\n  %s", strings.Join(changes, "\n  "), strings.Join(want, "\n  "))
+	}
+
+	merged := active.Clone()
+	merged.Merge(overlay, "file")
+	riscv, ok := merged.Find("linux-riscv64")
+	if !ok || riscv.OS != "linux" || riscv.Extension != "AppImage" {
+		t.Errorf("new platform merged as %+v", riscv)
+	}
+	darwin, _ := merged.Find("darwin-arm64")
+	if _, ok := darwin.WithArtifact("qodersetup"); !ok {
+		t.Errorf("darwin-arm64 artifacts after merging: %v", darwin.ArtifactNames())
+	}
+}
+
+func TestPromoteFile(t *testing.T) {
+	active := platform.DefaultRegistry()
+	path := filepath.Join(t.TempDir(), "registry.yaml")
+	existing := "platforms:\n  - name: linux-x64\n    aliases: [linux]\n"
+	if err := os.WriteFile(path, []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	snap := found("Qoder-linux-x64.snap", "Qoder", "linux", "x64", "snap")
+	changes, err := PromoteFile(path, active, "0.2.1", []Found{snap})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("changes = %v", changes)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay, err := platform.ParseRegistry(data)
+	if err != nil {
+		t.Fatalf("rewritten registry file does not parse: %v\n%s", err, data)
+	}
+	if len(overlay.Platforms) != 1 {
+		t.Fatalf("rewritten registry file lists %d platforms:\n%s", len(overlay.Platforms), data)
+	}
+	p := overlay.Platforms[0]
+	if p.Name != "linux-x64" || !reflect.DeepEqual(p.Aliases, []string{"linux"}) {
+		t.Errorf("existing settings lost:\n%s", data)
+	}
+	if len(p.Artifacts) != 1 || p.Artifacts[0].Extension != "snap" {
+		t.Errorf("artifact not recorded:\n%s", data)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("temporary file left behind")
+	}
+
+	// Promoting the same file again changes nothing, and leaves the file alone
+	before, _ := os.Stat(path)
+	changes, err = PromoteFile(path, active, "0.2.1", []Found{snap})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(path)
+	if len(changes) != 1 || !strings.HasPrefix(changes[0], "skipped") || !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("second promotion: changes %v", changes)
+	}
+}
+
+func TestPromoteFileCreatesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.yaml")
+	changes, err := PromoteFile(path, platform.DefaultRegistry(), "0.2.1", []Found{
+		found("Qoder-linux-riscv64.AppImage", "Qoder", "linux", "riscv64", "AppImage"),
+	})
+	if err != nil || len(changes) != 1 {
+		t.Fatalf("changes %v, err %v", changes, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "linux-riscv64") {
+		t.Errorf("registry file:\n%s", data)
+	}
+}
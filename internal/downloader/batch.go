@@ -0,0 +1,161 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+)
+
+// BatchState is the queue of jobs left when a batch was interrupted
+type BatchState struct {
+	Jobs      []Job     `json:"jobs"`
+	Selection Selection `json:"selection"`
+	SavedAt   time.Time `json:"saved_at"`
+	Completed int       `json:"completed"`
+	Failed    int       `json:"failed"`
+}
+
+// Selection is what a batch was asked to download. The queue of an
+// interrupted batch only stands in for the jobs of the same selection.
+type Selection struct {
+	Version   string `json:"version,omitempty"`   // Empty for every known version
+	Platform  string `json:"platform,omitempty"`  // Canonical name; empty for every platform
+	Artifacts string `json:"artifacts,omitempty"` // Sorted and comma-separated; empty for the defaults
+}
+
+// String describes the selection for messages
+func (s Selection) String() string {
+	parts := []string{"all versions", "all platforms", "default artifacts"}
+	if s.Version != "" {
+		parts[0] = "version " + s.Version
+	}
+	if s.Platform != "" {
+		parts[1] = s.Platform
+	}
+	if s.Artifacts != "" {
+		parts[2] = s.Artifacts
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SetSelection records what the batch was asked to download, which is
+// saved with its queue
+func (d *Downloader) SetSelection(selection Selection) {
+	d.selection = selection
+}
+
+// StatePath returns the location of the batch state file in the output directory
+func (d *Downloader) StatePath() string {
+	return filepath.Join(d.outputDir, manifest.StateFileName)
+}
+
+// LoadState returns the saved batch state, or nil when there is none
+func (d *Downloader) LoadState() (*BatchState, error) {
+	data, err := os.ReadFile(d.StatePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read batch state: %w", err)
+	}
+
+	var state BatchState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse batch state %s: %w", d.StatePath(), err)
+	}
+	return &state, nil
+}
+
+// ClearState removes the saved batch state
+func (d *Downloader) ClearState() error {
+	if err := os.Remove(d.StatePath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// SaveQueue saves jobs as the queue of an interrupted batch, for when it is
+// interrupted before DownloadJobs starts
+func (d *Downloader) SaveQueue(jobs []Job) error {
+	return d.saveState(&BatchState{Jobs: jobs})
+}
+
+func (d *Downloader) saveState(state *BatchState) error {
+	if err := os.MkdirAll(d.outputDir, 0755); err != nil {
+		return err
+	}
+
+	state.Selection = d.selection
+	state.SavedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := d.StatePath() + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write batch state: %w", err)
+	}
+	return os.Rename(tmp, d.StatePath())
+}
+
+// DownloadJobs downloads a batch of jobs in order. When ctx is cancelled the
+// in-flight download keeps its partial file and the remaining queue, including
+// the interrupted job, is saved so a later run can continue with LoadState.
+func (d *Downloader) DownloadJobs(ctx context.Context, jobs []Job) error {
+	if len(jobs) == 0 {
+		return fmt.Errorf("no versions to download")
+	}
+
+	if d.verbose {
+		fmt.Printf("Starting batch download of %d jobs\n", len(jobs))
+	}
+
+	successCount := 0
+	failCount := 0
+
+	for i, job := range jobs {
+		if d.verbose {
+			fmt.Printf("\n[%d/%d] ", i+1, len(jobs))
+		}
+
+		err := d.DownloadJob(ctx, job)
+		if ctx.Err() != nil {
+			state := &BatchState{Jobs: jobs[i:], Completed: successCount, Failed: failCount}
+			if saveErr := d.saveState(state); saveErr != nil {
+				return fmt.Errorf("interrupted, and failed to save remaining jobs: %v", saveErr)
+			}
+			fmt.Printf("\nInterrupted: %d jobs left, saved to %s\n", len(state.Jobs), d.StatePath())
+			return ctx.Err()
+		}
+		if err != nil {
+			fmt.Printf("Failed to download %s for %s: %v\n", job.Version, job.Label(), err)
+			failCount++
+		} else {
+			successCount++
+		}
+	}
+
+	if err := d.ClearState(); err != nil {
+		fmt.Printf("Warning: failed to remove batch state: %v\n", err)
+	}
+
+	if d.verbose {
+		fmt.Printf("\n\nBatch download completed:\n")
+		fmt.Printf("  Success: %d\n", successCount)
+		fmt.Printf("  Failed: %d\n", failCount)
+		fmt.Printf("  Total: %d\n", len(jobs))
+	}
+
+	if failCount > 0 {
+		return fmt.Errorf("%d downloads failed out of %d total", failCount, len(jobs))
+	}
+
+	return nil
+}
@@ -1,14 +1,23 @@
 package downloader
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 	
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
 	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
 )
 
@@ -16,6 +25,10 @@ type Downloader struct {
 	verbose   bool
 	outputDir string
 	client    *http.Client
+	manifest  *manifest.Manifest
+	layout    *platform.Layout
+	refresh   bool
+	selection Selection // Saved with the queue of an interrupted batch
 }
 
 type ProgressReader struct {
@@ -44,74 +57,216 @@ func (pr *ProgressReader) Read(p []byte) (int, error) {
 	return n, err
 }
 
-func NewDownloader(verbose bool, outputDir string) *Downloader {
+func NewDownloader(verbose bool, outputDir string) (*Downloader, error) {
+	m, err := manifest.Load(outputDir)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Downloader{
 		verbose:   verbose,
 		outputDir: outputDir,
 		client: &http.Client{
 			Timeout: 30 * time.Minute, // Long timeout for large files
 		},
-	}
+		manifest: m,
+		layout:   platform.MustLayout(platform.DefaultLayout),
+	}, nil
 }
 
-func (d *Downloader) DownloadVersion(version, platformName string) error {
+// SetLayout sets the name template used for new downloads
+func (d *Downloader) SetLayout(layout *platform.Layout) {
+	d.layout = layout
+}
+
+// SetRefresh makes existing downloads be re-checked with conditional requests
+func (d *Downloader) SetRefresh(refresh bool) {
+	d.refresh = refresh
+}
+
+// Manifest returns the manifest of the output directory
+func (d *Downloader) Manifest() *manifest.Manifest {
+	return d.manifest
+}
+
+// DownloadVersion downloads the primary artifact of one version for one platform
+func (d *Downloader) DownloadVersion(ctx context.Context, version, platformName string) error {
+	return d.DownloadJob(ctx, Job{Version: version, Platform: platformName})
+}
+
+// DownloadJob downloads one artifact of one version for one platform. A
+// partial file left behind by an interrupted download is resumed.
+func (d *Downloader) DownloadJob(ctx context.Context, job Job) error {
+	return d.downloadJob(ctx, job, false)
+}
+
+// Redownload downloads an artifact even when its file looks present, for
+// files known to be damaged. The existing file is only replaced once the new
+// one is complete.
+func (d *Downloader) Redownload(ctx context.Context, job Job) error {
+	return d.downloadJob(ctx, job, true)
+}
+
+func (d *Downloader) downloadJob(ctx context.Context, job Job, replace bool) error {
+	version := job.Version
+
 	// Get platform info
-	platformInfo, err := platform.GetPlatformByName(platformName)
+	platformInfo, err := platform.GetArtifact(job.Platform, job.Artifact)
 	if err != nil {
-		return fmt.Errorf("invalid platform %s: %v", platformName, err)
+		return fmt.Errorf("invalid platform %s: %v", job.Label(), err)
 	}
 
+	// Construct download URLs and output path. Versions covered by URL rules
+	// may be published under several names; the most specific comes first.
+	candidates := platform.DownloadCandidates(version, platformInfo)
+	url := candidates[0].URL
+	outputPath := d.OutputPath(version, platformInfo)
+	filename := filepath.Base(outputPath)
+
 	// Create output directory if it doesn't exist
-	versionDir := filepath.Join(d.outputDir, version)
-	err = os.MkdirAll(versionDir, 0755)
+	err = os.MkdirAll(filepath.Dir(outputPath), 0755)
 	if err != nil {
-		return fmt.Errorf("failed to create directory %s: %v", versionDir, err)
-	}
-
-	// Construct download URL and filename with version
-	var filename string
-	if platformInfo.OS == "windows" {
-		// Special naming for Windows platforms to include OS name
-		if platformInfo.Arch == "amd64" {
-			filename = fmt.Sprintf("qoder-%s-windows-x64.exe", version)
-		} else if platformInfo.Arch == "arm64" {
-			filename = fmt.Sprintf("qoder-%s-windows-arm64.exe", version)
-		}
-	} else {
-		// Default naming for other platforms
-		filename = fmt.Sprintf("qoder-%s-%s.%s", version, platformInfo.Name, platformInfo.Extension)
+		return fmt.Errorf("failed to create directory %s: %v", filepath.Dir(outputPath), err)
 	}
-	url := platform.ConstructDownloadURL(version, platformInfo)
-	outputPath := filepath.Join(versionDir, filename)
 
 	// Check if file already exists
-	if _, err := os.Stat(outputPath); err == nil {
+	_, err = os.Stat(outputPath)
+	exists := err == nil && !replace
+	if exists && !d.isPresent(ctx, job, outputPath) {
+		fmt.Printf("%s does not match the manifest, downloading again\n", outputPath)
+	} else if exists {
+		existing := d.manifest.Find(version, platformInfo.Name, platformInfo.Artifact)
+		// Files downloaded before the manifest existed are adopted as they are
+		if existing == nil {
+			if d.verbose {
+				fmt.Printf("File already exists: %s\n", outputPath)
+			}
+			return d.adoptFile(version, platformInfo, url, outputPath)
+		}
+		if d.refresh {
+			if existing.URL != "" {
+				url = existing.URL
+			}
+			return d.refreshFile(ctx, existing, url, outputPath)
+		}
 		if d.verbose {
 			fmt.Printf("File already exists: %s\n", outputPath)
 		}
 		return nil
 	}
 
-	if d.verbose {
-		fmt.Printf("Downloading: %s\n", url)
-		fmt.Printf("Output: %s\n", outputPath)
+	partPath := outputPath + ".part"
+	var entry *manifest.Entry
+	for i, candidate := range candidates {
+		if d.verbose {
+			fmt.Printf("Downloading: %s\n", candidate.URL)
+			fmt.Printf("Output: %s\n", outputPath)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, candidate.URL, nil)
+		if err != nil {
+			return fmt.Errorf("failed to download %s: %v", candidate.URL, err)
+		}
+
+		entry, err = d.fetch(req, partPath, filename, true)
+		if errors.Is(err, errNotFound) && i < len(candidates)-1 {
+			if d.verbose {
+				fmt.Printf("Not found, trying the next URL rule\n")
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if candidate.Rule != platform.DefaultRule {
+			entry.Rule = candidate.Rule
+		}
+		break
+	}
+	if err := os.Rename(partPath, outputPath); err != nil {
+		return fmt.Errorf("failed to move %s into place: %v", partPath, err)
 	}
 
-	// Create HTTP request
-	resp, err := d.client.Get(url)
+	entry.Version = version
+	entry.Platform = platformInfo.Name
+	entry.Artifact = platformInfo.Artifact
+	return d.record(entry, outputPath)
+}
+
+// errNotModified is returned by fetch when a conditional request yields 304
+var errNotModified = errors.New("not modified")
+
+// errNotFound is wrapped by fetch when upstream does not serve the URL
+var errNotFound = errors.New("HTTP 404")
+
+// fetch performs req and writes a 200 response body to path, returning the
+// manifest fields describing what was written. The caller fills in the
+// version, platform and artifact. With resume set, an existing file at path is continued
+// with a range request, conditional on the validator recorded when it was
+// started, so that a file changed upstream comes back whole. If the request
+// is cancelled the partial file and its validator are kept.
+func (d *Downloader) fetch(req *http.Request, path, filename string, resume bool) (*manifest.Entry, error) {
+	url := req.URL.String()
+
+	// Hash while writing so the manifest never needs a second pass over the file
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+
+	var offset int64
+	if resume {
+		if size, validator := partial(path); size > 0 {
+			offset = size
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			req.Header.Set("If-Range", validator)
+		}
+	}
+
+	resp, err := d.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to download %s: %v", url, err)
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download %s: HTTP %d", url, resp.StatusCode)
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, errNotModified
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		if d.verbose {
+			fmt.Printf("Resuming %s at %.2f MB\n", filename, float64(offset)/1024/1024)
+		}
+		if _, err := hashInto(req.Context(), path, md5Hash, sha256Hash); err != nil {
+			return nil, err
+		}
+		flags = os.O_WRONLY | os.O_APPEND
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// The partial file does not fit what upstream serves now, start over
+		resp.Body.Close()
+		os.Remove(path)
+		os.Remove(validatorPath(path))
+		req.Header.Del("Range")
+		req.Header.Del("If-Range")
+		return d.fetch(req, path, filename, false)
+	case resp.StatusCode == http.StatusOK:
+		if offset > 0 && d.verbose {
+			fmt.Printf("%s changed upstream since it was started, downloading it again\n", filename)
+		}
+		offset = 0
+		if err := writeValidator(path, resp.Header); err != nil {
+			return nil, err
+		}
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("failed to download %s: %w", url, errNotFound)
+	default:
+		return nil, fmt.Errorf("failed to download %s: HTTP %d", url, resp.StatusCode)
 	}
 
 	// Create output file
-	outFile, err := os.Create(outputPath)
+	outFile, err := os.OpenFile(path, flags, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to create file %s: %v", outputPath, err)
+		return nil, fmt.Errorf("failed to create file %s: %v", path, err)
 	}
 	defer outFile.Close()
 
@@ -131,156 +286,288 @@ func (d *Downloader) DownloadVersion(version, platformName string) error {
 		}
 	}
 
+	writer := io.MultiWriter(outFile, md5Hash, sha256Hash)
+
 	var written int64
 	if d.verbose && totalSize > 0 {
 		// Use progress reader for verbose mode
 		progressReader := &ProgressReader{
 			Reader:    resp.Body,
-			Total:     totalSize,
+			Total:     offset + totalSize,
+			Current:   offset,
 			Filename:  filename,
 			Verbose:   d.verbose,
 		}
-		written, err = io.Copy(outFile, progressReader)
+		written, err = io.Copy(writer, progressReader)
 	} else {
-		written, err = io.Copy(outFile, resp.Body)
+		written, err = io.Copy(writer, resp.Body)
 	}
+	written += offset
 
+	if err == nil {
+		err = outFile.Close()
+	}
 	if err != nil {
-		return fmt.Errorf("failed to write file %s: %v", outputPath, err)
+		// Keep what we have when interrupted so the next run can resume
+		if req.Context().Err() != nil {
+			return nil, req.Context().Err()
+		}
+		os.Remove(path)
+		os.Remove(validatorPath(path))
+		return nil, fmt.Errorf("failed to write file %s: %v", path, err)
 	}
+	os.Remove(validatorPath(path))
 
 	if d.verbose {
-		fmt.Printf("\nDownload completed: %s (%.2f MB)\n", outputPath, float64(written)/1024/1024)
+		fmt.Printf("\nDownload completed: %s (%.2f MB)\n", filename, float64(written)/1024/1024)
 	}
 
-	return nil
+	return &manifest.Entry{
+		URL:          url,
+		Mirror:       mirrorOf(url),
+		Size:         written,
+		MD5:          hex.EncodeToString(md5Hash.Sum(nil)),
+		SHA256:       hex.EncodeToString(sha256Hash.Sum(nil)),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		DownloadedAt: time.Now().UTC(),
+	}, nil
 }
 
-// DownloadAllVersions downloads all existing versions for the specified platform
-func (d *Downloader) DownloadAllVersions(versions []string, platformName string) error {
-	if len(versions) == 0 {
-		return fmt.Errorf("no versions to download")
-	}
+// validatorPath returns where the validator of a partial download is kept
+func validatorPath(path string) string {
+	return path + ".validator"
+}
 
-	if d.verbose {
-		fmt.Printf("Starting batch download of %d versions for platform %s\n", len(versions), platformName)
+// writeValidator records the ETag, or else the Last-Modified date, that a
+// download was started with. Weak ETags cannot be used with If-Range, so
+// without a usable validator the download cannot be resumed.
+func writeValidator(path string, header http.Header) error {
+	validator := header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = header.Get("Last-Modified")
+	}
+	if validator == "" {
+		os.Remove(validatorPath(path))
+		return nil
+	}
+	if err := os.WriteFile(validatorPath(path), []byte(validator+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to record validator of %s: %v", path, err)
 	}
+	return nil
+}
 
-	successCount := 0
-	failCount := 0
+// partial returns the size of a partial download and the validator it was
+// started with. A partial without a validator cannot be resumed safely and
+// counts as empty.
+func partial(path string) (int64, string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, ""
+	}
+	data, err := os.ReadFile(validatorPath(path))
+	validator := strings.TrimSpace(string(data))
+	if err != nil || validator == "" {
+		return 0, ""
+	}
+	return info.Size(), validator
+}
 
-	for i, version := range versions {
+// refreshFile re-requests an existing download using the validators recorded
+// when it was fetched. A changed file replaces the old one, which is kept as a
+// timestamped backup and recorded as an upstream-modification event.
+func (d *Downloader) refreshFile(ctx context.Context, existing *manifest.Entry, url, outputPath string) error {
+	if existing.ETag == "" && existing.LastModified == "" {
 		if d.verbose {
-			fmt.Printf("\n[%d/%d] ", i+1, len(versions))
+			fmt.Printf("No validators recorded for %s, skipping refresh\n", outputPath)
 		}
+		return nil
+	}
 
-		err := d.DownloadVersion(version, platformName)
-		if err != nil {
-			fmt.Printf("Failed to download %s for %s: %v\n", version, platformName, err)
-			failCount++
-		} else {
-			successCount++
-		}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to refresh %s: %v", url, err)
+	}
+	if existing.ETag != "" {
+		req.Header.Set("If-None-Match", existing.ETag)
+	}
+	if existing.LastModified != "" {
+		req.Header.Set("If-Modified-Since", existing.LastModified)
 	}
 
 	if d.verbose {
-		fmt.Printf("\n\nBatch download completed:\n")
-		fmt.Printf("  Success: %d\n", successCount)
-		fmt.Printf("  Failed: %d\n", failCount)
-		fmt.Printf("  Total: %d\n", len(versions))
+		fmt.Printf("Checking for upstream changes: %s\n", url)
 	}
 
-	if failCount > 0 {
-		return fmt.Errorf("%d downloads failed out of %d total", failCount, len(versions))
+	filename := filepath.Base(outputPath)
+	partPath := outputPath + ".part"
+	entry, err := d.fetch(req, partPath, filename, false)
+	if err == errNotModified {
+		if d.verbose {
+			fmt.Printf("Not modified: %s\n", outputPath)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
-	return nil
-}
+	entry.Version = existing.Version
+	entry.Platform = existing.Platform
+	entry.Artifact = existing.Artifact
+	entry.Rule = existing.Rule
 
-// DownloadAllPlatforms downloads a specific version for all platforms
-func (d *Downloader) DownloadAllPlatforms(version string) error {
-	platforms := platform.GetAllPlatforms()
-	
-	if d.verbose {
-		fmt.Printf("Starting download of version %s for all %d platforms\n", version, len(platforms))
+	// The server may ignore validators; identical content only refreshes them
+	if entry.SHA256 == existing.SHA256 {
+		os.Remove(partPath)
+		entry.DownloadedAt = existing.DownloadedAt
+		return d.record(entry, outputPath)
 	}
 
-	successCount := 0
-	failCount := 0
-
-	for i, platformInfo := range platforms {
-		if d.verbose {
-			fmt.Printf("\n[%d/%d] ", i+1, len(platforms))
-		}
+	now := time.Now().UTC()
+	backupPath := fmt.Sprintf("%s.%s.bak", outputPath, now.Format("20060102T150405Z"))
+	if err := os.Rename(outputPath, backupPath); err != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("failed to back up %s: %v", outputPath, err)
+	}
+	if err := os.Rename(partPath, outputPath); err != nil {
+		return fmt.Errorf("failed to move %s into place: %v", partPath, err)
+	}
 
-		err := d.DownloadVersion(version, platformInfo.Name)
-		if err != nil {
-			fmt.Printf("Failed to download %s for %s: %v\n", version, platformInfo.Name, err)
-			failCount++
-		} else {
-			successCount++
-		}
+	backupRel, err := d.manifest.RelPath(backupPath)
+	if err != nil {
+		return err
 	}
+	d.manifest.AddEvent(&manifest.Event{
+		Type:            manifest.EventUpstreamModified,
+		Version:         existing.Version,
+		Platform:        existing.Platform,
+		Artifact:        existing.Artifact,
+		Path:            existing.Path,
+		BackupPath:      backupRel,
+		OldSize:         existing.Size,
+		NewSize:         entry.Size,
+		OldSHA256:       existing.SHA256,
+		NewSHA256:       entry.SHA256,
+		OldETag:         existing.ETag,
+		NewETag:         entry.ETag,
+		OldLastModified: existing.LastModified,
+		NewLastModified: entry.LastModified,
+		DetectedAt:      now,
+	})
+
+	fmt.Printf("Upstream modified %s %s, previous file kept as %s\n", existing.Version, existing.Platform, backupPath)
+
+	return d.record(entry, outputPath)
+}
 
-	if d.verbose {
-		fmt.Printf("\n\nAll platforms download completed:\n")
-		fmt.Printf("  Success: %d\n", successCount)
-		fmt.Printf("  Failed: %d\n", failCount)
-		fmt.Printf("  Total: %d\n", len(platforms))
+// adoptFile records an existing file that has no manifest entry yet
+func (d *Downloader) adoptFile(version string, platformInfo platform.PlatformInfo, url, path string) error {
+	size, md5Sum, sha256Sum, err := HashFile(path)
+	if err != nil {
+		return err
 	}
 
-	if failCount > 0 {
-		return fmt.Errorf("%d downloads failed out of %d total", failCount, len(platforms))
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return d.record(&manifest.Entry{
+		Version:      version,
+		Platform:     platformInfo.Name,
+		Artifact:     platformInfo.Artifact,
+		URL:          url,
+		Mirror:       mirrorOf(url),
+		Size:         size,
+		MD5:          md5Sum,
+		SHA256:       sha256Sum,
+		DownloadedAt: info.ModTime().UTC(),
+	}, path)
 }
 
-// DownloadAllVersionsAllPlatforms downloads all versions for all platforms
-func (d *Downloader) DownloadAllVersionsAllPlatforms(versions []string) error {
-	if len(versions) == 0 {
-		return fmt.Errorf("no versions to download")
+// record stores an entry for the file at path and persists the manifest
+func (d *Downloader) record(entry *manifest.Entry, path string) error {
+	rel, err := d.manifest.RelPath(path)
+	if err != nil {
+		return fmt.Errorf("failed to record %s in manifest: %v", path, err)
 	}
+	entry.Path = rel
 
-	platforms := platform.GetAllPlatforms()
-	totalDownloads := len(versions) * len(platforms)
-	
-	if d.verbose {
-		fmt.Printf("Starting massive download: %d versions × %d platforms = %d total downloads\n", 
-			len(versions), len(platforms), totalDownloads)
+	d.manifest.Put(entry)
+	return d.manifest.Save()
+}
+
+// HashFile returns the size, MD5 and SHA-256 of a file
+func HashFile(path string) (int64, string, string, error) {
+	return HashFileContext(context.Background(), path)
+}
+
+// HashFileContext is HashFile, giving up with ctx.Err() once ctx is cancelled
+func HashFileContext(ctx context.Context, path string) (int64, string, string, error) {
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	size, err := hashInto(ctx, path, md5Hash, sha256Hash)
+	if err != nil {
+		return 0, "", "", err
 	}
 
-	successCount := 0
-	failCount := 0
-	currentDownload := 0
+	return size, hex.EncodeToString(md5Hash.Sum(nil)), hex.EncodeToString(sha256Hash.Sum(nil)), nil
+}
 
-	for _, version := range versions {
-		for _, platformInfo := range platforms {
-			currentDownload++
-			if d.verbose {
-				fmt.Printf("\n[%d/%d] ", currentDownload, totalDownloads)
-			}
+// hashInto feeds the contents of a file to the given hashes
+func hashInto(ctx context.Context, path string, hashes ...hash.Hash) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
 
-			err := d.DownloadVersion(version, platformInfo.Name)
-			if err != nil {
-				fmt.Printf("Failed to download %s for %s: %v\n", version, platformInfo.Name, err)
-				failCount++
-			} else {
-				successCount++
-			}
-		}
+	writers := make([]io.Writer, len(hashes))
+	for i, h := range hashes {
+		writers[i] = h
+	}
+	size, err := io.Copy(io.MultiWriter(writers...), &contextReader{ctx: ctx, r: f})
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to hash %s: %v", path, err)
 	}
+	return size, nil
+}
 
-	if d.verbose {
-		fmt.Printf("\n\nMassive download completed:\n")
-		fmt.Printf("  Success: %d\n", successCount)
-		fmt.Printf("  Failed: %d\n", failCount)
-		fmt.Printf("  Total: %d\n", totalDownloads)
+// contextReader stops reading once its context is cancelled, so that hashing
+// a large file can be interrupted
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
 	}
+	return r.r.Read(p)
+}
 
-	if failCount > 0 {
-		return fmt.Errorf("%d downloads failed out of %d total", failCount, totalDownloads)
+// mirrorOf returns the host a URL was fetched from
+func mirrorOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
 	}
+	return u.Host
+}
 
-	return nil
-}
\ No newline at end of file
+// OutputPath returns where an artifact of a version and platform lives. Files
+// already recorded in the manifest stay where they are until relaid out;
+// new downloads follow the configured layout.
+func (d *Downloader) OutputPath(version string, platformInfo platform.PlatformInfo) string {
+	if entry := d.manifest.Find(version, platformInfo.Name, platformInfo.Artifact); entry != nil {
+		if _, err := os.Stat(d.manifest.AbsPath(entry)); err == nil {
+			return d.manifest.AbsPath(entry)
+		}
+	}
+	return filepath.Join(d.outputDir, filepath.FromSlash(d.layout.Format(version, platformInfo)))
+}
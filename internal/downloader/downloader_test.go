@@ -0,0 +1,75 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveFile serves contents with an ETag derived from them, honouring Range
+// and If-Range like a CDN, and records the Range headers it was sent
+func serveFile(t *testing.T, contents *string, ranges *[]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*ranges = append(*ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", `"`+*contents+`"`)
+		http.ServeContent(w, r, "Qoder.dmg", time.Time{}, strings.NewReader(*contents))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchResumesOnlyUnchangedFiles(t *testing.T) {
+	contents := "first half, second half"
+	var ranges []string
+	srv := serveFile(t, &contents, &ranges)
+	d, err := NewDownloader(false, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "Qoder.dmg.part")
+
+	fetch := func() string {
+		t.Helper()
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL, nil)
+		entry, err := d.fetch(req, path, "Qoder.dmg", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(path)
+		if entry.Size != int64(len(data)) {
+			t.Errorf("recorded size %d, wrote %d bytes", entry.Size, len(data))
+		}
+		if _, err := os.Stat(validatorPath(path)); !os.IsNotExist(err) {
+			t.Errorf("validator kept after the download finished")
+		}
+		return string(data)
+	}
+
+	// An interrupted download leaves the first half and its validator
+	os.WriteFile(path, []byte("first half, "), 0644)
+	os.WriteFile(validatorPath(path), []byte(`"`+contents+`"`), 0644)
+	if got := fetch(); got != contents || ranges[0] != "bytes=12-" {
+		t.Errorf("resumed download = %q with Range %q", got, ranges[0])
+	}
+
+	// Upstream changed the file in the meantime, so it comes back whole
+	os.WriteFile(path, []byte("first half, "), 0644)
+	os.WriteFile(validatorPath(path), []byte(`"`+contents+`"`), 0644)
+	contents = "FIRST HALF, other second half"
+	if got := fetch(); got != contents {
+		t.Errorf("download of a changed file = %q", got)
+	}
+
+	// A partial without a validator is started over
+	os.WriteFile(path, []byte("first half, "), 0644)
+	ranges = nil
+	if got := fetch(); got != contents || ranges[0] != "" {
+		t.Errorf("download without a validator = %q with Range %q", got, ranges[0])
+	}
+}
@@ -0,0 +1,259 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+)
+
+// Job is a single artifact of a version and platform to download
+type Job struct {
+	Version  string `json:"version"`
+	Platform string `json:"platform"`
+	Artifact string `json:"artifact,omitempty"` // Empty for the primary artifact
+}
+
+// Label names the platform and, for secondary artifacts, the artifact
+func (j Job) Label() string {
+	if j.Artifact == "" {
+		return j.Platform
+	}
+	return j.Platform + "/" + j.Artifact
+}
+
+// Jobs expands versions, platforms and artifacts into download jobs, version
+// by version. Without artifacts only the primary artifact of each platform is
+// downloaded; platforms that do not publish a requested artifact are skipped.
+func Jobs(versions []string, platformNames []string, artifacts []string) []Job {
+	jobs := make([]Job, 0, len(versions)*len(platformNames))
+	for _, version := range versions {
+		for _, platformName := range platformNames {
+			p, err := platform.GetPlatformByName(platformName)
+			if len(artifacts) == 0 || err != nil {
+				jobs = append(jobs, Job{Version: version, Platform: platformName})
+				continue
+			}
+			for _, name := range artifacts {
+				if artifact, ok := p.WithArtifact(name); ok {
+					jobs = append(jobs, Job{Version: version, Platform: platformName, Artifact: artifact.Artifact})
+				}
+			}
+		}
+	}
+	return jobs
+}
+
+// Plan item states
+const (
+	PlanDownload    = "download"    // Will be fetched
+	PlanPresent     = "present"     // Already downloaded and matches the manifest
+	PlanUnavailable = "unavailable" // Upstream does not serve this file
+	PlanFailed      = "failed"      // Upstream could not be asked; the download is still attempted
+)
+
+// PlanItem describes what a download job will do
+type PlanItem struct {
+	Job
+	URL        string
+	Path       string
+	Status     string
+	RemoteSize int64 // -1 when upstream does not report a Content-Length
+	Partial    int64 // Bytes already fetched by an interrupted download
+	Err        error
+}
+
+// Plan summarises a batch of download jobs before it starts
+type Plan struct {
+	Items       []PlanItem
+	TotalBytes  int64  // Size of everything upstream serves for the jobs
+	NeededBytes int64  // Size still to be downloaded
+	UnknownSize int    // Jobs to download whose size upstream did not report
+	FreeBytes   uint64 // Space available on the output volume
+	FreeErr     error
+}
+
+// Count returns the number of items in the given state
+func (p *Plan) Count(status string) int {
+	count := 0
+	for _, item := range p.Items {
+		if item.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Fits reports whether the data still to be downloaded fits on the output volume.
+// It is optimistic when free space could not be determined.
+func (p *Plan) Fits() bool {
+	return p.FreeErr != nil || uint64(p.NeededBytes) <= p.FreeBytes
+}
+
+// Plan HEADs every job, works out what still needs downloading and checks it
+// against the free space on the output volume
+func (d *Downloader) Plan(ctx context.Context, jobs []Job) (*Plan, error) {
+	plan := &Plan{Items: make([]PlanItem, len(jobs))}
+
+	var wg sync.WaitGroup
+	work := make(chan int)
+	for i := 0; i < planWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range work {
+				plan.Items[idx] = d.planJob(ctx, jobs[idx])
+			}
+		}()
+	}
+	for i := range jobs {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, item := range plan.Items {
+		if item.Status == PlanUnavailable {
+			continue
+		}
+		if item.RemoteSize > 0 {
+			plan.TotalBytes += item.RemoteSize
+		}
+		if item.Status == PlanDownload {
+			if item.RemoteSize < 0 {
+				plan.UnknownSize++
+			} else {
+				plan.NeededBytes += item.RemoteSize - item.Partial
+			}
+		}
+	}
+
+	plan.FreeBytes, plan.FreeErr = freeSpace(existingParent(d.outputDir))
+
+	return plan, nil
+}
+
+// planWorkers bounds the number of concurrent HEAD requests while planning
+const planWorkers = 8
+
+func (d *Downloader) planJob(ctx context.Context, job Job) PlanItem {
+	item := PlanItem{Job: job, RemoteSize: -1}
+
+	platformInfo, err := platform.GetArtifact(job.Platform, job.Artifact)
+	if err != nil {
+		item.Status = PlanUnavailable
+		item.Err = err
+		return item
+	}
+
+	candidates := platform.DownloadCandidates(job.Version, platformInfo)
+	item.URL = candidates[0].URL
+	item.Path = d.OutputPath(job.Version, platformInfo)
+
+	if d.isPresent(ctx, job, item.Path) && !d.refresh {
+		item.Status = PlanPresent
+		if entry := d.manifest.Find(job.Version, job.Platform, job.Artifact); entry != nil {
+			item.RemoteSize = entry.Size
+		}
+		return item
+	}
+
+	// Older versions may only exist under the names of a URL rule. Only a
+	// 404 means a name is not served; any other failure is reported unless
+	// another name is found.
+	var resp *http.Response
+	var failure error
+	for _, candidate := range candidates {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, candidate.URL, nil)
+		if err != nil {
+			failure = err
+			continue
+		}
+		r, err := d.client.Do(req)
+		if err != nil {
+			failure = err
+			continue
+		}
+		r.Body.Close()
+		if r.StatusCode == http.StatusOK {
+			item.URL = candidate.URL
+			resp = r
+			break
+		}
+		if r.StatusCode != http.StatusNotFound {
+			failure = fmt.Errorf("HTTP %d", r.StatusCode)
+		}
+	}
+
+	if resp == nil {
+		item.Status = PlanUnavailable
+		item.Err = fmt.Errorf("HTTP %d", http.StatusNotFound)
+		if failure != nil {
+			item.Status = PlanFailed
+			item.Err = failure
+		}
+		return item
+	}
+
+	item.RemoteSize = resp.ContentLength
+	item.Status = PlanDownload
+	if size, _ := partial(item.Path + ".part"); size < item.RemoteSize && !d.refresh {
+		item.Partial = size
+	}
+	if d.isPresent(ctx, job, item.Path) {
+		// Refreshing: the existing file stays unless upstream changed it
+		if entry := d.manifest.Find(job.Version, job.Platform, job.Artifact); entry != nil && entry.Size == resp.ContentLength {
+			item.Status = PlanPresent
+		}
+	}
+
+	return item
+}
+
+// isPresent reports whether a file exists and matches its manifest entry.
+// The size is always compared, the SHA-256 only when the file was modified
+// after the entry was recorded, so that an archive of every version is not
+// hashed on each run; verify hashes everything.
+func (d *Downloader) isPresent(ctx context.Context, job Job, path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	entry := d.manifest.Find(job.Version, job.Platform, job.Artifact)
+	if entry == nil {
+		return true
+	}
+	if entry.Size != info.Size() {
+		return false
+	}
+	if entry.SHA256 == "" || !info.ModTime().After(entry.DownloadedAt) {
+		return true
+	}
+	if d.verbose {
+		fmt.Printf("%s changed since it was downloaded, checking its SHA-256\n", path)
+	}
+	_, _, sum, err := HashFileContext(ctx, path)
+	return err == nil && sum == entry.SHA256
+}
+
+// existingParent returns dir or its closest ancestor that exists
+func existingParent(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
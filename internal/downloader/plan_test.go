@@ -0,0 +1,129 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+)
+
+func TestIsPresentHashesFilesChangedSinceDownload(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDownloader(false, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "0.2.1", "Qoder.dmg")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("disk image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	size, md5Sum, sha256Sum, err := HashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloaded := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, downloaded, downloaded); err != nil {
+		t.Fatal(err)
+	}
+	d.manifest.Put(&manifest.Entry{Version: "0.2.1", Platform: "darwin-arm64", Path: "0.2.1/Qoder.dmg", Size: size, MD5: md5Sum, SHA256: sha256Sum, DownloadedAt: downloaded})
+	job := Job{Version: "0.2.1", Platform: "darwin-arm64"}
+
+	if !d.isPresent(context.Background(), job, path) {
+		t.Fatal("the downloaded file is not present")
+	}
+
+	// Same size, other contents, written after the download
+	if err := os.WriteFile(path, []byte("disk imag3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if d.isPresent(context.Background(), job, path) {
+		t.Error("a file changed since the download counts as present")
+	}
+
+	// Put back, it matches again even though it is newer
+	if err := os.WriteFile(path, []byte("disk image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !d.isPresent(context.Background(), job, path) {
+		t.Error("a rewritten identical file is not present")
+	}
+
+	if err := os.WriteFile(path, []byte("short"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if d.isPresent(context.Background(), job, path) {
+		t.Error("a file of another size counts as present")
+	}
+}
+
+func TestPlanJobTriesEveryCandidate(t *testing.T) {
+	var statuses map[string]int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statuses[r.URL.Path])
+	}))
+	defer srv.Close()
+
+	// Versions before 1.0.0 are tried under an older name first
+	registry := platform.DefaultRegistry()
+	registry.BaseURL = srv.URL
+	registry.Rules = []platform.URLRule{{Name: "old", Versions: "<1.0.0", Filename: "Old-{platform}.{ext}"}}
+	platform.SetRegistry(registry)
+	defer platform.SetRegistry(nil)
+
+	const oldName, newName = "/0.2.1/Old-darwin-arm64.dmg", "/0.2.1/Qoder-darwin-arm64.dmg"
+	for _, tc := range []struct {
+		name     string
+		statuses map[string]int
+		status   string
+		url      string
+	}{
+		{"new name served", map[string]int{oldName: 404, newName: 200}, PlanDownload, newName},
+		{"old name served", map[string]int{oldName: 200, newName: 404}, PlanDownload, oldName},
+		{"error before the served name", map[string]int{oldName: 500, newName: 200}, PlanDownload, newName},
+		{"missing everywhere", map[string]int{oldName: 404, newName: 404}, PlanUnavailable, ""},
+		{"rate limited", map[string]int{oldName: 404, newName: 429}, PlanFailed, ""},
+		{"server error", map[string]int{oldName: 500, newName: 404}, PlanFailed, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			statuses = tc.statuses
+			d, err := NewDownloader(false, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			item := d.planJob(context.Background(), Job{Version: "0.2.1", Platform: "darwin-arm64"})
+			if item.Status != tc.status {
+				t.Errorf("status %q (%v), want %q", item.Status, item.Err, tc.status)
+			}
+			if tc.url != "" && item.URL != srv.URL+tc.url {
+				t.Errorf("URL %s, want %s", item.URL, srv.URL+tc.url)
+			}
+			if (item.Err != nil) != (tc.status != PlanDownload) {
+				t.Errorf("err = %v", item.Err)
+			}
+		})
+	}
+
+	// A transport error on one name does not hide the next
+	registry.Rules[0].URLTemplate = "http://127.0.0.1:1/{version}/{filename}"
+	statuses = map[string]int{newName: 200}
+	d, err := NewDownloader(false, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item := d.planJob(context.Background(), Job{Version: "0.2.1", Platform: "darwin-arm64"}); item.Status != PlanDownload || item.URL != srv.URL+newName {
+		t.Errorf("after a transport error: status %q at %s (%v)", item.Status, item.URL, item.Err)
+	}
+	statuses = map[string]int{newName: 404}
+	if item := d.planJob(context.Background(), Job{Version: "0.2.1", Platform: "darwin-arm64"}); item.Status != PlanFailed {
+		t.Errorf("transport error and 404: status %q (%v)", item.Status, item.Err)
+	}
+}
@@ -0,0 +1,285 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/detector"
+)
+
+// FileName is the name of the manifest file kept in the downloads directory
+const FileName = "manifest.json"
+
+// StateFileName is the batch state the downloader keeps while a batch is interrupted
+const StateFileName = "download-state.json"
+
+// CurrentVersion is the schema version written by this build
+const CurrentVersion = 1
+
+// Entry records a single downloaded artifact
+type Entry struct {
+	Version      string    `json:"version"`
+	Platform     string    `json:"platform"`
+	Artifact     string    `json:"artifact,omitempty"` // Empty for the platform's primary artifact
+	Path         string    `json:"path"`               // Relative to the downloads directory, slash separated
+	URL          string    `json:"url"`
+	Rule         string    `json:"rule,omitempty"` // URL rule the file was found with, when not the current naming
+	Mirror       string    `json:"mirror"`
+	Size         int64     `json:"size"`
+	MD5          string    `json:"md5"`
+	SHA256       string    `json:"sha256"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+
+	Releases []*Release `json:"releases,omitempty"` // Where the file has been published
+}
+
+// Release records that a file was published as a release asset
+type Release struct {
+	Forge       string    `json:"forge,omitempty"` // github when empty
+	Repo        string    `json:"repo"`            // owner/repo, or the GitLab project path
+	Tag         string    `json:"tag"`
+	Asset       string    `json:"asset"`
+	AssetID     int64     `json:"asset_id,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// RecordRelease notes that the entry is published in a repository, replacing
+// what was recorded for that repository before. It reports whether anything
+// changed.
+func (e *Entry) RecordRelease(r *Release) bool {
+	for i, existing := range e.Releases {
+		if existing.ForgeName() != r.ForgeName() || existing.Repo != r.Repo {
+			continue
+		}
+		if existing.Tag == r.Tag && existing.Asset == r.Asset && existing.AssetID == r.AssetID {
+			return false
+		}
+		e.Releases[i] = r
+		return true
+	}
+	e.Releases = append(e.Releases, r)
+	return true
+}
+
+// ForgeName returns the forge a release is on, treating records made before
+// other forges were supported as GitHub
+func (r *Release) ForgeName() string {
+	if r.Forge == "" {
+		return "github"
+	}
+	return r.Forge
+}
+
+// EventUpstreamModified is recorded when upstream republished an artifact under the same version
+const EventUpstreamModified = "upstream-modified"
+
+// Event records a notable change to a downloaded artifact
+type Event struct {
+	Type            string    `json:"type"`
+	Version         string    `json:"version"`
+	Platform        string    `json:"platform"`
+	Artifact        string    `json:"artifact,omitempty"`
+	Path            string    `json:"path"`
+	BackupPath      string    `json:"backup_path,omitempty"`
+	OldSize         int64     `json:"old_size"`
+	NewSize         int64     `json:"new_size"`
+	OldSHA256       string    `json:"old_sha256"`
+	NewSHA256       string    `json:"new_sha256"`
+	OldETag         string    `json:"old_etag,omitempty"`
+	NewETag         string    `json:"new_etag,omitempty"`
+	OldLastModified string    `json:"old_last_modified,omitempty"`
+	NewLastModified string    `json:"new_last_modified,omitempty"`
+	DetectedAt      time.Time `json:"detected_at"`
+}
+
+// Manifest is the index of everything in a downloads directory
+type Manifest struct {
+	SchemaVersion int      `json:"schema_version"`
+	Entries       []*Entry `json:"entries"`
+	Events        []*Event `json:"events,omitempty"`
+
+	path string
+}
+
+// Path returns the location of the manifest file for a downloads directory
+func Path(dir string) string {
+	return filepath.Join(dir, FileName)
+}
+
+// IsBookkeeping reports whether a path relative to the downloads directory is
+// maintained by the tool itself rather than being a downloaded artifact
+func IsBookkeeping(rel string) bool {
+	switch rel {
+	case FileName, FileName + ".tmp", StateFileName, StateFileName + ".tmp":
+		return true
+	}
+	// Partial downloads waiting to be resumed, and the validators they were started with
+	return strings.HasSuffix(rel, ".part") || strings.HasSuffix(rel, ".part.validator")
+}
+
+// Load reads the manifest from dir. A missing manifest yields an empty one.
+func Load(dir string) (*Manifest, error) {
+	m := &Manifest{SchemaVersion: CurrentVersion, path: Path(dir)}
+
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, fmt.Errorf("failed to read manifest %s: %w", m.path, err)
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", m.path, err)
+	}
+	if m.SchemaVersion > CurrentVersion {
+		return nil, fmt.Errorf("manifest %s has schema version %d, newer than supported %d", m.path, m.SchemaVersion, CurrentVersion)
+	}
+	m.SchemaVersion = CurrentVersion
+
+	return m, nil
+}
+
+// Save writes the manifest atomically next to the downloads it describes
+func (m *Manifest) Save() error {
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+
+	m.sort()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("failed to replace manifest: %w", err)
+	}
+
+	return nil
+}
+
+// Dir returns the downloads directory the manifest describes
+func (m *Manifest) Dir() string {
+	return filepath.Dir(m.path)
+}
+
+// AbsPath resolves an entry path against the downloads directory
+func (m *Manifest) AbsPath(e *Entry) string {
+	return filepath.Join(m.Dir(), filepath.FromSlash(e.Path))
+}
+
+// RelPath converts a file path inside the downloads directory to an entry path
+func (m *Manifest) RelPath(path string) (string, error) {
+	rel, err := filepath.Rel(m.Dir(), path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// Put adds an entry, replacing any existing entry for the same version, platform and artifact
+func (m *Manifest) Put(e *Entry) {
+	for i, existing := range m.Entries {
+		if existing.Version == e.Version && existing.Platform == e.Platform && existing.Artifact == e.Artifact {
+			m.Entries[i] = e
+			return
+		}
+	}
+	m.Entries = append(m.Entries, e)
+}
+
+// Find returns the entry for a version, platform and artifact, or nil. The
+// primary artifact of a platform has an empty artifact name.
+func (m *Manifest) Find(version, platformName, artifact string) *Entry {
+	for _, e := range m.Entries {
+		if e.Version == version && e.Platform == platformName && e.Artifact == artifact {
+			return e
+		}
+	}
+	return nil
+}
+
+// FindPath returns the entry recorded at the given relative path, or nil
+func (m *Manifest) FindPath(rel string) *Entry {
+	for _, e := range m.Entries {
+		if e.Path == rel {
+			return e
+		}
+	}
+	return nil
+}
+
+// ForVersion returns all entries for a version
+func (m *Manifest) ForVersion(version string) []*Entry {
+	var result []*Entry
+	for _, e := range m.Entries {
+		if e.Version == version {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Versions returns the distinct versions present in the manifest, oldest first
+func (m *Manifest) Versions() []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, e := range m.Entries {
+		if !seen[e.Version] {
+			seen[e.Version] = true
+			result = append(result, e.Version)
+		}
+	}
+	detector.SortVersionStrings(result)
+	return result
+}
+
+// Remove deletes the given entry from the manifest
+func (m *Manifest) Remove(e *Entry) {
+	for i, existing := range m.Entries {
+		if existing == e {
+			m.Entries = append(m.Entries[:i], m.Entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// AddEvent appends an event to the manifest history
+func (m *Manifest) AddEvent(e *Event) {
+	m.Events = append(m.Events, e)
+}
+
+// Backups returns the relative paths of backups kept by recorded events
+func (m *Manifest) Backups() []string {
+	var result []string
+	for _, e := range m.Events {
+		if e.BackupPath != "" {
+			result = append(result, e.BackupPath)
+		}
+	}
+	return result
+}
+
+func (m *Manifest) sort() {
+	sort.SliceStable(m.Entries, func(i, j int) bool {
+		if c := detector.CompareVersionStrings(m.Entries[i].Version, m.Entries[j].Version); c != 0 {
+			return c < 0
+		}
+		if m.Entries[i].Platform != m.Entries[j].Platform {
+			return m.Entries[i].Platform < m.Entries[j].Platform
+		}
+		return m.Entries[i].Artifact < m.Entries[j].Artifact
+	})
+}
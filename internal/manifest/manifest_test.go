@@ -0,0 +1,143 @@
+package manifest
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadMissingManifest(t *testing.T) {
+	m, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Entries) != 0 || m.SchemaVersion != CurrentVersion {
+		t.Errorf("missing manifest loaded as %+v", m)
+	}
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloaded := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	entry := &Entry{
+		Version:      "0.2.1",
+		Platform:     "darwin-arm64",
+		Path:         "0.2.1/Qoder-darwin-arm64.dmg",
+		URL:          "https://download.qoder.com/release/0.2.1/Qoder-darwin-arm64.dmg",
+		Size:         42,
+		MD5:          "d41d8cd98f00b204e9800998ecf8427e",
+		SHA256:       "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		ETag:         `"abc"`,
+		DownloadedAt: downloaded,
+		Releases: []*Release{
+			{Repo: "owner/repo", Tag: "v0.2.1", Asset: "Qoder-darwin-arm64.dmg", AssetID: 7, PublishedAt: downloaded},
+		},
+	}
+	m.Put(entry)
+	m.AddEvent(&Event{Version: "0.2.1", Platform: "darwin-arm64", BackupPath: "0.2.1/Qoder-darwin-arm64.dmg.old"})
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(Path(dir) + ".tmp"); !os.IsNotExist(err) {
+		t.Error("temporary file left behind after saving")
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Entries) != 1 || !reflect.DeepEqual(loaded.Entries[0], entry) {
+		t.Errorf("loaded entries %+v, want %+v", loaded.Entries, entry)
+	}
+	if got := loaded.Backups(); !reflect.DeepEqual(got, []string{"0.2.1/Qoder-darwin-arm64.dmg.old"}) {
+		t.Errorf("loaded backups %v", got)
+	}
+	if loaded.Dir() != dir {
+		t.Errorf("loaded manifest describes %s, want %s", loaded.Dir(), dir)
+	}
+}
+
+func TestLoadRejectsNewerSchema(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(Path(dir), []byte(`{"schema_version": 99, "entries": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Error("loaded a manifest with a newer schema version")
+	}
+}
+
+func TestFind(t *testing.T) {
+	m := &Manifest{}
+	dmg := &Entry{Version: "0.2.1", Platform: "darwin-arm64", Path: "0.2.1/Qoder.dmg"}
+	zip := &Entry{Version: "0.2.1", Platform: "darwin-arm64", Artifact: "zip", Path: "0.2.1/Qoder.zip"}
+	linux := &Entry{Version: "0.2.1", Platform: "linux-x64", Path: "0.2.1/Qoder.deb"}
+	older := &Entry{Version: "0.2.0", Platform: "darwin-arm64", Path: "0.2.0/Qoder.dmg"}
+	for _, e := range []*Entry{dmg, zip, linux, older} {
+		m.Put(e)
+	}
+
+	if got := m.Find("0.2.1", "darwin-arm64", ""); got != dmg {
+		t.Errorf("Find primary artifact = %+v", got)
+	}
+	if got := m.Find("0.2.1", "darwin-arm64", "zip"); got != zip {
+		t.Errorf("Find secondary artifact = %+v", got)
+	}
+	if got := m.Find("0.2.2", "darwin-arm64", ""); got != nil {
+		t.Errorf("Find unknown version = %+v", got)
+	}
+	if got := m.FindPath("0.2.1/Qoder.deb"); got != linux {
+		t.Errorf("FindPath = %+v", got)
+	}
+	if got := m.ForVersion("0.2.1"); !reflect.DeepEqual(got, []*Entry{dmg, zip, linux}) {
+		t.Errorf("ForVersion = %+v", got)
+	}
+
+	// Put replaces the entry of the same version, platform and artifact
+	replacement := &Entry{Version: "0.2.1", Platform: "darwin-arm64", Path: "0.2.1/Qoder-new.dmg"}
+	m.Put(replacement)
+	if got := m.Find("0.2.1", "darwin-arm64", ""); got != replacement || len(m.Entries) != 4 {
+		t.Errorf("Put did not replace the entry, found %+v among %d entries", got, len(m.Entries))
+	}
+
+	m.Remove(replacement)
+	if got := m.Find("0.2.1", "darwin-arm64", ""); got != nil || len(m.Entries) != 3 {
+		t.Errorf("Remove left %+v among %d entries", got, len(m.Entries))
+	}
+}
+
+func TestVersionOrder(t *testing.T) {
+	m := &Manifest{path: Path(t.TempDir())}
+	for _, v := range []string{"0.2.10", "0.10.0", "0.2.9", "0.2.10", "1.0.0", "0.2.9"} {
+		for _, p := range []string{"linux-x64", "darwin-arm64"} {
+			m.Put(&Entry{Version: v, Platform: p})
+		}
+	}
+
+	want := []string{"0.2.9", "0.2.10", "0.10.0", "1.0.0"}
+	if got := m.Versions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Versions() = %v, want %v", got, want)
+	}
+
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range m.Entries {
+		got = append(got, e.Version+" "+e.Platform)
+	}
+	wantEntries := []string{
+		"0.2.9 darwin-arm64", "0.2.9 linux-x64",
+		"0.2.10 darwin-arm64", "0.2.10 linux-x64",
+		"0.10.0 darwin-arm64", "0.10.0 linux-x64",
+		"1.0.0 darwin-arm64", "1.0.0 linux-x64",
+	}
+	if !reflect.DeepEqual(got, wantEntries) {
+		t.Errorf("saved entries in order %v, want %v", got, wantEntries)
+	}
+}
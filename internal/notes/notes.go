@@ -0,0 +1,104 @@
+package notes
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/retention"
+)
+
+// DefaultTemplate is used when no notes template is configured
+//
+//go:embed default.tmpl
+var DefaultTemplate string
+
+// Data is what a notes template is rendered with
+type Data struct {
+	Version   string
+	Tag       string
+	FirstSeen time.Time // Zero when unknown
+	Previous  *Link     // Nil for the oldest version
+	Next      *Link     // Nil for the newest version
+	Files     []File
+}
+
+// Split reports whether any file is attached in parts
+func (d Data) Split() bool {
+	for _, f := range d.Files {
+		if f.Parts > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Link refers to the release of another version
+type Link struct {
+	Version string
+	Tag     string
+	URL     string
+}
+
+// File is one published artifact
+type File struct {
+	Platform     string
+	Artifact     string // Empty for the platform's primary artifact
+	Asset        string // Name of the release asset
+	URL          string // Upstream download URL
+	Size         int64
+	PreviousSize int64 // Size in the previous version, when HasPrevious
+	HasPrevious  bool
+	SHA256       string
+	MD5          string
+	Parts        int // Number of parts the asset is split into; 0 when it is attached whole
+}
+
+// Funcs are the functions available to templates besides the built-in ones
+var Funcs = template.FuncMap{
+	"size":  retention.FormatSize,
+	"delta": Delta,
+	"date": func(t time.Time) string {
+		return t.Format("2006-01-02")
+	},
+}
+
+// Load reads a template from a file, or returns the default for an empty path
+func Load(path string) (string, error) {
+	if path == "" {
+		return DefaultTemplate, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read notes template: %w", err)
+	}
+	return string(data), nil
+}
+
+// Render executes a notes template
+func Render(text string, data Data) (string, error) {
+	tmpl, err := template.New("notes").Funcs(Funcs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid notes template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render notes: %w", err)
+	}
+	return b.String(), nil
+}
+
+// Delta describes the change from one size to another
+func Delta(size, previous int64) string {
+	switch {
+	case size == previous:
+		return "unchanged"
+	case size > previous:
+		return "+" + retention.FormatSize(size-previous)
+	default:
+		return "-" + retention.FormatSize(previous-size)
+	}
+}
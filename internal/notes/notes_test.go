@@ -0,0 +1,126 @@
+package notes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDelta(t *testing.T) {
+	for _, tc := range []struct {
+		size, previous int64
+		want           string
+	}{
+		{100, 100, "unchanged"},
+		{3 << 20, 1 << 20, "+2.00 MB"},
+		{1 << 20, 3 << 20, "-2.00 MB"},
+		{1024, 0, "+1.00 KB"},
+		{10, 15, "-5 B"},
+	} {
+		if got := Delta(tc.size, tc.previous); got != tc.want {
+			t.Errorf("Delta(%d, %d) = %q, want %q", tc.size, tc.previous, got, tc.want)
+		}
+	}
+}
+
+func testData() Data {
+	return Data{
+		Version:   "0.2.1",
+		Tag:       "v0.2.1",
+		FirstSeen: time.Date(2025, 9, 12, 8, 30, 0, 0, time.UTC),
+		Previous:  &Link{Version: "0.2.0", Tag: "v0.2.0", URL: "https://github.com/o/r/releases/tag/v0.2.0"},
+		Files: []File{
+			{
+				Platform: "darwin-arm64", Asset: "qoder-0.2.1-darwin-arm64.dmg",
+				URL:  "https://download.qoder.com/release/0.2.1/Qoder-darwin-arm64.dmg",
+				Size: 3 << 20, PreviousSize: 2 << 20, HasPrevious: true,
+				SHA256: "sha-dmg", MD5: "md5-dmg",
+			},
+			{
+				Platform: "linux-x64", Artifact: "deb", Asset: "qoder-0.2.1-linux-x64.deb",
+				URL:  "https://download.qoder.com/release/0.2.1/Qoder-linux-x64.deb",
+				Size: 1 << 20, SHA256: "sha-deb", MD5: "md5-deb",
+			},
+		},
+	}
+}
+
+func TestRenderDefaultTemplate(t *testing.T) {
+	text, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := testData()
+	out, err := Render(text, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"Qoder 0.2.1, first seen upstream on 2025-09-12.",
+		"| darwin-arm64 | [qoder-0.2.1-darwin-arm64.dmg](https://download.qoder.com/release/0.2.1/Qoder-darwin-arm64.dmg) | 3.00 MB | +1.00 MB | `sha-dmg` | `md5-dmg` |",
+		"| linux-x64 (deb) | [qoder-0.2.1-linux-x64.deb](https://download.qoder.com/release/0.2.1/Qoder-linux-x64.deb) | 1.00 MB | new | `sha-deb` | `md5-deb` |",
+		"Previous: [0.2.0](https://github.com/o/r/releases/tag/v0.2.0)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("notes do not contain %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"Next:", "parts", " · "} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("notes contain %q:\n%s", unwanted, out)
+		}
+	}
+
+	// Unknown first-seen date, a newer release and a split file
+	data.FirstSeen = time.Time{}
+	data.Next = &Link{Version: "0.2.2", URL: "https://github.com/o/r/releases/tag/v0.2.2"}
+	data.Files[0].Parts = 2
+	out, err = Render(text, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Qoder 0.2.1.\n",
+		"(https://download.qoder.com/release/0.2.1/Qoder-darwin-arm64.dmg) (2 parts) |",
+		"qoder-downloader join NAME.parts.json",
+		"Previous: [0.2.0](https://github.com/o/r/releases/tag/v0.2.0) · Next: [0.2.2](https://github.com/o/r/releases/tag/v0.2.2)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("notes do not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderCustomTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.tmpl")
+	template := "{{.Tag}} ({{date .FirstSeen}}){{range .Files}} {{.Platform}}={{size .Size}}{{end}}"
+	if err := os.WriteFile(path, []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+	text, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Render(text, testData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "v0.2.1 (2025-09-12) darwin-arm64=3.00 MB linux-x64=1.00 MB"; out != want {
+		t.Errorf("rendered %q, want %q", out, want)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Error("loaded a missing template")
+	}
+	if _, err := Render("{{.Version", testData()); err == nil || !strings.Contains(err.Error(), "invalid notes template") {
+		t.Errorf("unparsable template: err = %v", err)
+	}
+	if _, err := Render("{{.Unknown}}", testData()); err == nil || !strings.Contains(err.Error(), "failed to render notes") {
+		t.Errorf("unknown field: err = %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package platform
+
+import "fmt"
+
+// Artifact is an additional file published for a platform, such as a .deb
+// package next to the AppImage
+type Artifact struct {
+	// Name used to select the artifact; defaults to the extension
+	Name string `yaml:"name,omitempty" mapstructure:"name" json:"name"`
+	// File extension, also used for the local file name
+	Extension string `yaml:"extension" mapstructure:"extension" json:"extension"`
+	// Upstream filename template; defaults to the platform's
+	Filename string `yaml:"filename,omitempty" mapstructure:"filename" json:"filename,omitempty"`
+	// Overrides the URL template for this artifact
+	URLTemplate string `yaml:"url_template,omitempty" mapstructure:"url_template" json:"url_template,omitempty"`
+	// Removes the artifact when merged as an override
+	Disabled bool `yaml:"disabled,omitempty" mapstructure:"disabled" json:"-"`
+}
+
+// ArtifactName returns the selector name of an artifact
+func (a Artifact) ArtifactName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return a.Extension
+}
+
+// ArtifactNames returns the names of every artifact of the platform, the
+// primary one (named after its extension) first
+func (p PlatformInfo) ArtifactNames() []string {
+	names := []string{p.Extension}
+	for _, a := range p.Artifacts {
+		names = append(names, a.ArtifactName())
+	}
+	return names
+}
+
+// WithArtifact returns the platform as it describes one of its artifacts.
+// An empty name or the primary extension returns the platform unchanged.
+func (p PlatformInfo) WithArtifact(name string) (PlatformInfo, bool) {
+	if name == "" || name == p.Extension {
+		return p, true
+	}
+
+	for _, a := range p.Artifacts {
+		if a.ArtifactName() != name {
+			continue
+		}
+		derived := p
+		derived.Artifact = name
+		derived.Artifacts = nil
+		derived.Extension = a.Extension
+		if a.Filename != "" {
+			derived.Filename = a.Filename
+		}
+		if a.URLTemplate != "" {
+			derived.URLTemplate = a.URLTemplate
+		}
+		// Filename rules describe the primary artifact; URL rules apply to all
+		derived.Rules = nil
+		for _, rule := range p.Rules {
+			if rule.Filename == "" {
+				derived.Rules = append(derived.Rules, rule)
+			}
+		}
+		return derived, true
+	}
+	return PlatformInfo{}, false
+}
+
+// Variants returns the platform once for each of its artifacts
+func (p PlatformInfo) Variants() []PlatformInfo {
+	variants := []PlatformInfo{p}
+	for _, a := range p.Artifacts {
+		if derived, ok := p.WithArtifact(a.ArtifactName()); ok {
+			variants = append(variants, derived)
+		}
+	}
+	return variants
+}
+
+// GetArtifact returns platform info for one artifact of a platform. An empty
+// artifact name selects the primary artifact.
+func GetArtifact(platformName, artifact string) (PlatformInfo, error) {
+	p, err := GetPlatformByName(platformName)
+	if err != nil {
+		return PlatformInfo{}, err
+	}
+	derived, ok := p.WithArtifact(artifact)
+	if !ok {
+		return PlatformInfo{}, fmt.Errorf("platform %s has no %s artifact", platformName, artifact)
+	}
+	return derived, nil
+}
+
+// allVariants returns every artifact of every platform
+func allVariants() []PlatformInfo {
+	var variants []PlatformInfo
+	for _, p := range GetAllPlatforms() {
+		variants = append(variants, p.Variants()...)
+	}
+	return variants
+}
+
+// mergeArtifacts applies overlay artifacts by name, as Merge does for platforms
+func mergeArtifacts(existing, overlay []Artifact) []Artifact {
+	for _, o := range overlay {
+		idx := -1
+		for i, a := range existing {
+			if a.ArtifactName() == o.ArtifactName() {
+				idx = i
+				break
+			}
+		}
+
+		switch {
+		case o.Disabled:
+			if idx >= 0 {
+				existing = append(existing[:idx], existing[idx+1:]...)
+			}
+		case idx < 0:
+			existing = append(existing, o)
+		default:
+			existing[idx] = o
+		}
+	}
+	return existing
+}
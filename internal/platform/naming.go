@@ -0,0 +1,238 @@
+package platform
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Product is the product name used in artifact names
+const Product = "qoder"
+
+// DefaultLayout is the default template for paths in the downloads directory
+const DefaultLayout = "{version}/{product}-{version}-{platform}.{ext}"
+
+// DefaultAssetName is the default template for release asset names
+const DefaultAssetName = "{product}-{version}-{platform}.{ext}"
+
+// Template tokens understood by Layout
+var layoutTokens = []string{"version", "product", "platform", "os", "arch", "goos", "goarch", "ext"}
+
+// Tokens whose value is derived from the platform
+var platformTokens = []string{"platform", "os", "arch", "goos", "goarch", "ext"}
+
+var tokenPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// Layout formats and parses artifact names from a template such as
+// "{version}/{product}-{version}-{os}-{arch}.{ext}". {os} and {arch} are the
+// two halves of the platform name as used upstream (e.g. "win32" and "x64");
+// {goos} and {goarch} are the Go names (e.g. "windows" and "amd64").
+type Layout struct {
+	template string
+	tokens   []string // Token for each capture group, in order
+	re       *regexp.Regexp
+}
+
+// NewLayout validates a template and prepares it for formatting and parsing
+func NewLayout(template string) (*Layout, error) {
+	template = strings.TrimSpace(template)
+	if template == "" {
+		return nil, fmt.Errorf("empty name template")
+	}
+	if strings.HasPrefix(template, "/") || strings.Contains(template, "..") || strings.Contains(template, `\`) {
+		return nil, fmt.Errorf("name template %q must be a relative slash-separated path", template)
+	}
+
+	seen := make(map[string]bool)
+	for _, match := range tokenPattern.FindAllStringSubmatch(template, -1) {
+		if !isLayoutToken(match[1]) {
+			return nil, fmt.Errorf("unknown token {%s} in name template %q (valid: %s)", match[1], template, tokenList())
+		}
+		seen[match[1]] = true
+	}
+	if !seen["version"] {
+		return nil, fmt.Errorf("name template %q must contain {version}", template)
+	}
+	if !distinguishesPlatforms(seen) {
+		return nil, fmt.Errorf("name template %q does not tell platforms apart; use {ext} and either {platform} or both {os} and {arch}", template)
+	}
+
+	l := &Layout{template: template}
+	if err := l.compile(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// MustLayout is like NewLayout but panics on an invalid template
+func MustLayout(template string) *Layout {
+	l, err := NewLayout(template)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// String returns the template
+func (l *Layout) String() string {
+	return l.template
+}
+
+// Format returns the slash-separated name of an artifact
+func (l *Layout) Format(version string, p PlatformInfo) string {
+	values := tokenValues(version, p)
+	return tokenPattern.ReplaceAllStringFunc(l.template, func(token string) string {
+		return values[strings.Trim(token, "{}")]
+	})
+}
+
+// Base returns the last element of the formatted name
+func (l *Layout) Base(version string, p PlatformInfo) string {
+	return path.Base(l.Format(version, p))
+}
+
+// Parse recovers the version and platform from a slash-separated name
+func (l *Layout) Parse(name string) (string, PlatformInfo, error) {
+	matches := l.re.FindStringSubmatch(name)
+	if matches == nil {
+		return "", PlatformInfo{}, fmt.Errorf("%s does not match name template %q", name, l.template)
+	}
+
+	values := make(map[string]string)
+	for i, token := range l.tokens {
+		value := matches[i+1]
+		if previous, ok := values[token]; ok && previous != value {
+			return "", PlatformInfo{}, fmt.Errorf("%s has conflicting values for {%s}", name, token)
+		}
+		values[token] = value
+	}
+
+	// The artifact is the one whose derived tokens agree with every captured value
+	for _, p := range allVariants() {
+		expected := tokenValues(values["version"], p)
+		matched := true
+		for _, token := range platformTokens {
+			if value, ok := values[token]; ok && value != expected[token] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return values["version"], p, nil
+		}
+	}
+
+	return "", PlatformInfo{}, fmt.Errorf("%s does not name a known platform", name)
+}
+
+// distinguishesPlatforms reports whether the given tokens identify every
+// artifact of every platform uniquely
+func distinguishesPlatforms(tokens map[string]bool) bool {
+	seen := make(map[string]bool)
+	for _, p := range allVariants() {
+		values := tokenValues("", p)
+		var key strings.Builder
+		for _, token := range platformTokens {
+			if tokens[token] {
+				key.WriteString(values[token] + "\x00")
+			}
+		}
+		if seen[key.String()] {
+			return false
+		}
+		seen[key.String()] = true
+	}
+	return true
+}
+
+func (l *Layout) compile() error {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+
+	rest := l.template
+	for {
+		loc := tokenPattern.FindStringSubmatchIndex(rest)
+		if loc == nil {
+			pattern.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		pattern.WriteString(regexp.QuoteMeta(rest[:loc[0]]))
+		token := rest[loc[2]:loc[3]]
+		pattern.WriteString("(" + tokenRegexp(token) + ")")
+		l.tokens = append(l.tokens, token)
+		rest = rest[loc[1]:]
+	}
+	pattern.WriteString("$")
+
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return fmt.Errorf("invalid name template %q: %v", l.template, err)
+	}
+	l.re = re
+	return nil
+}
+
+// tokenValues returns the value of every template token for an artifact
+func tokenValues(version string, p PlatformInfo) map[string]string {
+	osPart, archPart := splitPlatformName(p.Name)
+	return map[string]string{
+		"version":  version,
+		"product":  Product,
+		"platform": p.Name,
+		"os":       osPart,
+		"arch":     archPart,
+		"goos":     p.OS,
+		"goarch":   p.Arch,
+		"ext":      p.Extension,
+	}
+}
+
+// tokenRegexp returns the pattern a token matches when parsing
+func tokenRegexp(token string) string {
+	switch token {
+	case "version":
+		// Lazy, so that a pre-release suffix does not take in the ".system"
+		// of an extension such as "system.exe" that follows it
+		return `\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+?)?`
+	case "product":
+		return regexp.QuoteMeta(Product)
+	}
+
+	// Platform-derived tokens match only the values known platforms produce
+	seen := make(map[string]bool)
+	var alternatives []string
+	for _, p := range allVariants() {
+		value := tokenValues("", p)[token]
+		if !seen[value] {
+			seen[value] = true
+			alternatives = append(alternatives, regexp.QuoteMeta(value))
+		}
+	}
+	return strings.Join(alternatives, "|")
+}
+
+// splitPlatformName splits "win32-x64" into "win32" and "x64"
+func splitPlatformName(name string) (string, string) {
+	if i := strings.Index(name, "-"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
+
+func isLayoutToken(token string) bool {
+	for _, t := range layoutTokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+func tokenList() string {
+	names := make([]string, len(layoutTokens))
+	for i, t := range layoutTokens {
+		names[i] = "{" + t + "}"
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,92 @@
+package platform
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLayoutRoundTrip(t *testing.T) {
+	SetRegistry(nil)
+
+	for _, template := range []string{
+		DefaultLayout,
+		DefaultAssetName,
+		"{version}/{os}-{arch}/{product}.{ext}",
+		"{goos}/{goarch}/{version}/qoder-{version}.{ext}",
+		"{product}_{platform}_{version}.{ext}",
+	} {
+		layout, err := NewLayout(template)
+		if err != nil {
+			t.Fatalf("%s: %v", template, err)
+		}
+		for _, version := range []string{"0.2.1", "10.0.12", "0.3.0-beta.1"} {
+			for _, p := range allVariants() {
+				name := layout.Format(version, p)
+				gotVersion, got, err := layout.Parse(name)
+				if err != nil {
+					t.Errorf("%s: Parse(%s): %v", template, name, err)
+					continue
+				}
+				if gotVersion != version || got.Name != p.Name || got.Artifact != p.Artifact {
+					t.Errorf("%s: Parse(%s) = %s %s/%s, want %s %s/%s",
+						template, name, gotVersion, got.Name, got.Artifact, version, p.Name, p.Artifact)
+				}
+			}
+		}
+	}
+}
+
+func TestLayoutFormat(t *testing.T) {
+	SetRegistry(nil)
+	p, err := GetArtifact("win32-x64", "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for template, want := range map[string]string{
+		DefaultLayout:                           "0.2.1/qoder-0.2.1-win32-x64.system.exe",
+		"{version}/{os}-{arch}/{product}.{ext}": "0.2.1/win32-x64/qoder.system.exe",
+		"{goos}-{goarch}/{version}.{ext}":       "windows-amd64/0.2.1.system.exe",
+	} {
+		if got := MustLayout(template).Format("0.2.1", p); got != want {
+			t.Errorf("%s: Format = %s, want %s", template, got, want)
+		}
+	}
+	if got := MustLayout(DefaultLayout).Base("0.2.1", p); got != "qoder-0.2.1-win32-x64.system.exe" {
+		t.Errorf("Base = %s", got)
+	}
+}
+
+func TestLayoutParseRejects(t *testing.T) {
+	SetRegistry(nil)
+	layout := MustLayout(DefaultLayout)
+	for _, name := range []string{
+		"0.2.1/qoder-0.2.1-linux-riscv64.AppImage", // Unknown platform
+		"0.2.1/qoder-0.2.1-linux-x64.dmg",          // Extension of another platform
+		"0.2.1/qoder-0.2.2-linux-x64.AppImage",     // Versions disagree
+		"qoder-0.2.1-linux-x64.AppImage",           // Missing directory
+		"0.2.1/Qoder-0.2.1-linux-x64.AppImage",     // Other product spelling
+	} {
+		if version, p, err := layout.Parse(name); err == nil {
+			t.Errorf("Parse(%s) = %s %s", name, version, p.Name)
+		}
+	}
+}
+
+func TestNewLayoutRejects(t *testing.T) {
+	SetRegistry(nil)
+	for template, problem := range map[string]string{
+		"":                                   "empty",
+		"/{version}/{platform}.{ext}":        "relative",
+		"../{version}/{platform}.{ext}":      "relative",
+		"{version}/{flavour}.{ext}":          "unknown token {flavour}",
+		"{platform}.{ext}":                   "must contain {version}",
+		"{version}/{product}.{ext}":          "does not tell platforms apart",
+		"{version}/{os}.{ext}":               "does not tell platforms apart",
+		"{version}/{product}-{platform}.zip": "does not tell platforms apart",
+	} {
+		if _, err := NewLayout(template); err == nil || !strings.Contains(err.Error(), problem) {
+			t.Errorf("NewLayout(%q) error %v, want %q", template, err, problem)
+		}
+	}
+}
@@ -3,44 +3,56 @@ package platform
 import (
 	"fmt"
 	"runtime"
+	"strings"
 )
 
 // PlatformInfo represents information about a platform
 type PlatformInfo struct {
-	Name      string // Platform name used in URLs
-	Extension string // File extension
-	OS        string // Operating system
-	Arch      string // Architecture
+	// Platform name used in URLs
+	Name string `yaml:"name" mapstructure:"name" json:"name"`
+	// File extension
+	Extension string `yaml:"extension,omitempty" mapstructure:"extension" json:"extension"`
+	// Operating system
+	OS string `yaml:"os,omitempty" mapstructure:"os" json:"os"`
+	// Architecture
+	Arch string `yaml:"arch,omitempty" mapstructure:"arch" json:"arch"`
+	// Upstream filename template
+	Filename string `yaml:"filename,omitempty" mapstructure:"filename" json:"filename"`
+	// Overrides the registry URL template for this platform
+	URLTemplate string `yaml:"url_template,omitempty" mapstructure:"url_template" json:"url_template,omitempty"`
+	// Filename and URL rules for older versions
+	Rules []URLRule `yaml:"rules,omitempty" mapstructure:"rules" json:"rules,omitempty"`
+	// Other names accepted for the platform
+	Aliases []string `yaml:"aliases,omitempty" mapstructure:"aliases" json:"aliases,omitempty"`
+	// Further artifacts published for the platform
+	Artifacts []Artifact `yaml:"artifacts,omitempty" mapstructure:"artifacts" json:"artifacts,omitempty"`
+	// Removes the platform when merged as an override
+	Disabled bool `yaml:"disabled,omitempty" mapstructure:"disabled" json:"-"`
+	// Where the definition came from
+	Source string `yaml:"-" mapstructure:"-" json:"source,omitempty"`
+	// Artifact this info describes, empty for the primary one (see WithArtifact)
+	Artifact string `yaml:"-" mapstructure:"-" json:"artifact,omitempty"`
 }
 
 // GetAllPlatforms returns all supported platforms
 func GetAllPlatforms() []PlatformInfo {
-	return []PlatformInfo{
-		// macOS platforms
-		{Name: "darwin-arm64", Extension: "dmg", OS: "darwin", Arch: "arm64"},
-		{Name: "darwin-x64", Extension: "dmg", OS: "darwin", Arch: "amd64"},
-		
-		// Windows platforms
-		{Name: "win32-x64", Extension: "exe", OS: "windows", Arch: "amd64"},
-		{Name: "win32-arm64", Extension: "exe", OS: "windows", Arch: "arm64"},
-		
-		// Linux platforms
-		{Name: "linux-x64", Extension: "AppImage", OS: "linux", Arch: "amd64"},
-		{Name: "linux-arm64", Extension: "AppImage", OS: "linux", Arch: "arm64"},
-	}
+	platforms := ActiveRegistry().Platforms
+	result := make([]PlatformInfo, len(platforms))
+	copy(result, platforms)
+	return result
 }
 
 // GetCurrentPlatform returns the platform info for the current system
 func GetCurrentPlatform() (PlatformInfo, error) {
 	currentOS := runtime.GOOS
 	currentArch := runtime.GOARCH
-	
+
 	for _, platform := range GetAllPlatforms() {
 		if platform.OS == currentOS && platform.Arch == currentArch {
 			return platform, nil
 		}
 	}
-	
+
 	return PlatformInfo{}, fmt.Errorf("unsupported platform: %s/%s", currentOS, currentArch)
 }
 
@@ -66,25 +78,10 @@ func GetPlatformNames() []string {
 
 // ConstructDownloadURL constructs the download URL for a given version and platform
 func ConstructDownloadURL(version string, platform PlatformInfo) string {
-	var filename string
-	
-	// Special handling for Windows platforms
-	if platform.OS == "windows" {
-		if platform.Arch == "amd64" {
-			filename = "QoderUserSetup-x64.exe"
-		} else if platform.Arch == "arm64" {
-			filename = "QoderUserSetup-arm64.exe"
-		}
-	} else {
-		// Default format for other platforms
-		filename = fmt.Sprintf("Qoder-%s.%s", platform.Name, platform.Extension)
-	}
-	
-	// Handle latest version
-	if version == "latest" {
-		return fmt.Sprintf("https://download.qoder.com/release/latest/%s", filename)
-	}
-	
-	// Handle specific versions
-	return fmt.Sprintf("https://download.qoder.com/release/%s/%s", version, filename)
-}
\ No newline at end of file
+	return ActiveRegistry().URL(version, platform)
+}
+
+// ConstructMD5URL returns the URL of the upstream MD5 checksum for a download URL
+func ConstructMD5URL(downloadURL string) string {
+	return strings.Replace(downloadURL, "/release/", "/release/md5/", 1)
+}
@@ -0,0 +1,317 @@
+package platform
+
+import (
+	_ "embed"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed platforms.yaml
+var builtinRegistry []byte
+
+// Registry is the set of platforms the tool knows how to download
+type Registry struct {
+	BaseURL     string         `yaml:"base_url,omitempty" mapstructure:"base_url" json:"base_url"`
+	URLTemplate string         `yaml:"url_template,omitempty" mapstructure:"url_template" json:"url_template"`
+	Rules       []URLRule      `yaml:"rules,omitempty" mapstructure:"rules" json:"rules,omitempty"`
+	Platforms   []PlatformInfo `yaml:"platforms" mapstructure:"platforms" json:"platforms"`
+}
+
+var (
+	registryMu sync.RWMutex
+	active     *Registry
+)
+
+// DefaultRegistry returns a fresh copy of the built-in registry
+func DefaultRegistry() *Registry {
+	r, err := ParseRegistry(builtinRegistry)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in platform registry: %v", err))
+	}
+	for i := range r.Platforms {
+		r.Platforms[i].Source = "builtin"
+	}
+	if err := r.Validate(); err != nil {
+		panic(fmt.Sprintf("invalid built-in platform registry: %v", err))
+	}
+	return r
+}
+
+// ParseRegistry decodes a registry document. The result is not validated.
+func ParseRegistry(data []byte) (*Registry, error) {
+	var r Registry
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse platform registry: %w", err)
+	}
+	return &r, nil
+}
+
+// SetRegistry makes r the registry used by the package-level lookups
+func SetRegistry(r *Registry) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	active = r
+}
+
+// ActiveRegistry returns the registry used by the package-level lookups
+func ActiveRegistry() *Registry {
+	registryMu.RLock()
+	r := active
+	registryMu.RUnlock()
+	if r != nil {
+		return r
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if active == nil {
+		active = DefaultRegistry()
+	}
+	return active
+}
+
+// Clone returns a deep copy of the registry
+func (r *Registry) Clone() *Registry {
+	c := *r
+	c.Rules = append([]URLRule(nil), r.Rules...)
+	c.Platforms = make([]PlatformInfo, len(r.Platforms))
+	for i, p := range r.Platforms {
+		p.Aliases = append([]string(nil), p.Aliases...)
+		p.Rules = append([]URLRule(nil), p.Rules...)
+		p.Artifacts = append([]Artifact(nil), p.Artifacts...)
+		c.Platforms[i] = p
+	}
+	return &c
+}
+
+// Merge applies an overlay on top of the registry. Platforms are matched by
+// name: non-empty fields of an overlay platform replace the existing ones,
+// unknown names are appended and "disabled: true" removes a platform.
+func (r *Registry) Merge(overlay *Registry, source string) {
+	if overlay.BaseURL != "" {
+		r.BaseURL = overlay.BaseURL
+	}
+	if overlay.URLTemplate != "" {
+		r.URLTemplate = overlay.URLTemplate
+	}
+	// Overlay rules are tried before the ones they are merged onto
+	r.Rules = append(append([]URLRule(nil), overlay.Rules...), r.Rules...)
+
+	for _, o := range overlay.Platforms {
+		idx := -1
+		for i, p := range r.Platforms {
+			if p.Name == o.Name {
+				idx = i
+				break
+			}
+		}
+
+		if o.Disabled {
+			if idx >= 0 {
+				r.Platforms = append(r.Platforms[:idx], r.Platforms[idx+1:]...)
+			}
+			continue
+		}
+
+		if idx < 0 {
+			o.Source = source
+			o.Artifacts = mergeArtifacts(nil, o.Artifacts)
+			r.Platforms = append(r.Platforms, o)
+			continue
+		}
+
+		p := &r.Platforms[idx]
+		if o.OS != "" {
+			p.OS = o.OS
+		}
+		if o.Arch != "" {
+			p.Arch = o.Arch
+		}
+		if o.Extension != "" {
+			p.Extension = o.Extension
+		}
+		if o.Filename != "" {
+			p.Filename = o.Filename
+		}
+		if o.URLTemplate != "" {
+			p.URLTemplate = o.URLTemplate
+		}
+		if len(o.Rules) > 0 {
+			p.Rules = o.Rules
+		}
+		if len(o.Aliases) > 0 {
+			p.Aliases = o.Aliases
+		}
+		if len(o.Artifacts) > 0 {
+			p.Artifacts = mergeArtifacts(p.Artifacts, o.Artifacts)
+		}
+		p.Source = source
+	}
+}
+
+// Validate checks that the registry is complete and consistent
+func (r *Registry) Validate() error {
+	var problems []string
+
+	if r.BaseURL == "" {
+		problems = append(problems, "base_url is required")
+	} else if u, err := url.Parse(r.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("base_url %q is not an absolute URL", r.BaseURL))
+	}
+	if r.URLTemplate == "" {
+		problems = append(problems, "url_template is required")
+	} else if err := checkTemplate(r.URLTemplate, true); err != nil {
+		problems = append(problems, fmt.Sprintf("url_template: %v", err))
+	}
+
+	for _, rule := range r.Rules {
+		problems = append(problems, rule.validate("registry")...)
+	}
+
+	if len(r.Platforms) == 0 {
+		problems = append(problems, "at least one platform is required")
+	}
+
+	seen := make(map[string]bool)
+	for i, p := range r.Platforms {
+		label := p.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+			problems = append(problems, fmt.Sprintf("platform %s: name is required", label))
+		} else if strings.ContainsAny(p.Name, "/\\ ") {
+			problems = append(problems, fmt.Sprintf("platform %s: name must not contain slashes or spaces", label))
+		}
+		if seen[p.Name] {
+			problems = append(problems, fmt.Sprintf("platform %s: duplicate name", label))
+		}
+		seen[p.Name] = true
+		for _, alias := range p.Aliases {
+			if seen[alias] {
+				problems = append(problems, fmt.Sprintf("platform %s: alias %s is already taken", label, alias))
+			}
+			seen[alias] = true
+		}
+
+		if p.OS == "" {
+			problems = append(problems, fmt.Sprintf("platform %s: os is required", label))
+		}
+		if p.Arch == "" {
+			problems = append(problems, fmt.Sprintf("platform %s: arch is required", label))
+		}
+		if p.Extension == "" {
+			problems = append(problems, fmt.Sprintf("platform %s: extension is required", label))
+		}
+		if p.Filename == "" {
+			problems = append(problems, fmt.Sprintf("platform %s: filename is required", label))
+		} else if err := checkTemplate(p.Filename, false); err != nil {
+			problems = append(problems, fmt.Sprintf("platform %s: filename: %v", label, err))
+		}
+		if p.URLTemplate != "" {
+			if err := checkTemplate(p.URLTemplate, true); err != nil {
+				problems = append(problems, fmt.Sprintf("platform %s: url_template: %v", label, err))
+			}
+		}
+		for _, rule := range p.Rules {
+			problems = append(problems, rule.validate("platform "+label)...)
+		}
+		problems = append(problems, validateArtifacts(label, p)...)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid platform registry:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// validateArtifacts checks the artifacts of a platform. Names select
+// artifacts and extensions name the local files, so both must be unique.
+func validateArtifacts(label string, p PlatformInfo) []string {
+	var problems []string
+	names := map[string]bool{p.Extension: true}
+	extensions := map[string]bool{p.Extension: true}
+	for i, a := range p.Artifacts {
+		artifactLabel := fmt.Sprintf("platform %s: artifact %s", label, a.ArtifactName())
+		if a.ArtifactName() == "" {
+			artifactLabel = fmt.Sprintf("platform %s: artifact #%d", label, i+1)
+		}
+
+		if a.Extension == "" {
+			problems = append(problems, artifactLabel+": extension is required")
+		} else if extensions[a.Extension] {
+			problems = append(problems, fmt.Sprintf("%s: extension %s is already used by another artifact", artifactLabel, a.Extension))
+		}
+		extensions[a.Extension] = true
+
+		if name := a.ArtifactName(); name != "" {
+			if names[name] {
+				problems = append(problems, artifactLabel+": duplicate name")
+			} else if strings.ContainsAny(name, ",/\\ ") {
+				problems = append(problems, artifactLabel+": name must not contain commas, slashes or spaces")
+			}
+			names[name] = true
+		}
+
+		if a.Filename != "" {
+			if err := checkTemplate(a.Filename, false); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: filename: %v", artifactLabel, err))
+			}
+		}
+		if a.URLTemplate != "" {
+			if err := checkTemplate(a.URLTemplate, true); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: url_template: %v", artifactLabel, err))
+			}
+		}
+	}
+	return problems
+}
+
+// Find returns the platform with the given name
+func (r *Registry) Find(name string) (PlatformInfo, bool) {
+	for _, p := range r.Platforms {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return PlatformInfo{}, false
+}
+
+// URL returns the most specific download URL of a version for a platform
+func (r *Registry) URL(version string, p PlatformInfo) string {
+	return r.Candidates(version, p)[0].URL
+}
+
+// url expands the URL template for a platform, ignoring version rules
+func (r *Registry) url(version string, p PlatformInfo) string {
+	values := tokenValues(version, p)
+	values["base_url"] = strings.TrimSuffix(r.BaseURL, "/")
+	values["filename"] = expandTemplate(p.Filename, values)
+
+	template := p.URLTemplate
+	if template == "" {
+		template = r.URLTemplate
+	}
+	return expandTemplate(template, values)
+}
+
+// expandTemplate replaces {token} placeholders with their values
+func expandTemplate(template string, values map[string]string) string {
+	return tokenPattern.ReplaceAllStringFunc(template, func(token string) string {
+		return values[strings.Trim(token, "{}")]
+	})
+}
+
+// checkTemplate rejects placeholders that would never be expanded
+func checkTemplate(template string, isURL bool) error {
+	for _, match := range tokenPattern.FindAllStringSubmatch(template, -1) {
+		token := match[1]
+		if isLayoutToken(token) || (isURL && (token == "base_url" || token == "filename")) {
+			continue
+		}
+		return fmt.Errorf("unknown token {%s} in %q", token, template)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package platform
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefaultRegistryURLs(t *testing.T) {
+	r := DefaultRegistry()
+	for _, tc := range []struct {
+		platform, artifact string
+		url                string
+	}{
+		{"darwin-arm64", "", "https://download.qoder.com/release/0.2.1/Qoder-darwin-arm64.dmg"},
+		{"darwin-arm64", "zip", "https://download.qoder.com/release/0.2.1/Qoder-darwin-arm64.zip"},
+		{"win32-x64", "", "https://download.qoder.com/release/0.2.1/QoderUserSetup-x64.exe"},
+		{"win32-x64", "system", "https://download.qoder.com/release/0.2.1/QoderSetup-x64.exe"},
+		{"linux-arm64", "tar.gz", "https://download.qoder.com/release/0.2.1/Qoder-linux-arm64.tar.gz"},
+	} {
+		p, ok := r.Find(tc.platform)
+		if !ok {
+			t.Fatalf("%s is not in the built-in registry", tc.platform)
+		}
+		variant, ok := p.WithArtifact(tc.artifact)
+		if !ok {
+			t.Fatalf("%s has no %q artifact", tc.platform, tc.artifact)
+		}
+		if url := r.URL("0.2.1", variant); url != tc.url {
+			t.Errorf("%s/%s: URL %s, want %s", tc.platform, tc.artifact, url, tc.url)
+		}
+	}
+}
+
+func TestMergeOverlay(t *testing.T) {
+	overlay, err := ParseRegistry([]byte(`
+base_url: https://mirror.example.com/qoder
+platforms:
+  - name: darwin-arm64
+    aliases: [apple-silicon]
+    artifacts:
+      - name: pkg
+        extension: pkg
+        filename: "QoderInstaller-{arch}.{ext}"
+      - extension: zip
+        disabled: true
+  - name: win32-arm64
+    disabled: true
+  - name: linux-riscv64
+    os: linux
+    arch: riscv64
+    extension: AppImage
+    filename: "Qoder-{platform}.{ext}"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	builtin := DefaultRegistry()
+	merged := builtin.Clone()
+	merged.Merge(overlay, "file")
+	if err := merged.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	darwin, _ := merged.Find("darwin-arm64")
+	if darwin.Source != "file" || !reflect.DeepEqual(darwin.Aliases, []string{"apple-silicon"}) {
+		t.Errorf("darwin-arm64 after merging: %+v", darwin)
+	}
+	// Fields the overlay leaves empty are kept
+	if darwin.Extension != "dmg" || darwin.Filename != "Qoder-{platform}.{ext}" {
+		t.Errorf("darwin-arm64 lost its settings: %+v", darwin)
+	}
+	if names := darwin.ArtifactNames(); !reflect.DeepEqual(names, []string{"dmg", "pkg"}) {
+		t.Errorf("darwin-arm64 artifacts %v, want [dmg pkg]", names)
+	}
+	pkg, _ := darwin.WithArtifact("pkg")
+	if url := merged.URL("0.2.1", pkg); url != "https://mirror.example.com/qoder/0.2.1/QoderInstaller-arm64.pkg" {
+		t.Errorf("pkg URL %s", url)
+	}
+
+	if _, ok := merged.Find("win32-arm64"); ok {
+		t.Error("disabled platform still present")
+	}
+	riscv, ok := merged.Find("linux-riscv64")
+	if !ok || riscv.Source != "file" {
+		t.Errorf("new platform merged as %+v", riscv)
+	}
+
+	// The built-in registry is left as it was
+	if _, ok := builtin.Find("win32-arm64"); !ok || builtin.BaseURL != "https://download.qoder.com/release" {
+		t.Error("merging changed the registry it was cloned from")
+	}
+	if original, _ := builtin.Find("darwin-arm64"); len(original.Aliases) != 0 || len(original.Artifacts) != 1 {
+		t.Errorf("merging changed the original darwin-arm64: %+v", original)
+	}
+}
+
+func TestValidateRejectsBadOverlays(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		overlay string
+		problem string
+	}{
+		{"alias taken", "platforms:\n  - name: darwin-x64\n    aliases: [darwin-arm64]\n", "alias darwin-arm64 is already taken"},
+		{"incomplete platform", "platforms:\n  - name: linux-riscv64\n    os: linux\n", "platform linux-riscv64: arch is required"},
+		{"unknown token", "platforms:\n  - name: linux-x64\n    filename: \"Qoder-{flavour}.{ext}\"\n", "unknown token {flavour}"},
+		{"duplicate extension", "platforms:\n  - name: linux-x64\n    artifacts:\n      - name: debian\n        extension: deb\n", "extension deb is already used"},
+		{"relative base URL", "base_url: /release\n", "is not an absolute URL"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			overlay, err := ParseRegistry([]byte(tc.overlay))
+			if err != nil {
+				t.Fatal(err)
+			}
+			merged := DefaultRegistry()
+			merged.Merge(overlay, "file")
+			err = merged.Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.problem) {
+				t.Errorf("Validate() = %v, want %q", err, tc.problem)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	registry := DefaultRegistry()
+	registry.Merge(&Registry{Platforms: []PlatformInfo{{Name: "darwin-arm64", Aliases: []string{"Apple-Silicon"}}}}, "file")
+	SetRegistry(registry)
+	defer SetRegistry(nil)
+
+	for input, want := range map[string]string{
+		"darwin-arm64":   "darwin-arm64",
+		" Linux-X64 ":    "linux-x64",
+		"apple-silicon":  "darwin-arm64",
+		"macos-arm":      "darwin-arm64",
+		"osx-x86_64":     "darwin-x64",
+		"win-x64":        "win32-x64",
+		"windows_arm64":  "win32-arm64",
+		"linux-aarch64":  "linux-arm64",
+		"linux/amd64":    "linux-x64",
+		"mac-arm64":      "darwin-arm64",
+		"win32-x86-64":   "win32-x64",
+		"linux x86_64":   "linux-x64",
+		"aarch64-darwin": "darwin-arm64",
+	} {
+		p, err := Resolve(input)
+		if err != nil {
+			t.Errorf("Resolve(%q): %v", input, err)
+		} else if p.Name != want {
+			t.Errorf("Resolve(%q) = %s, want %s", input, p.Name, want)
+		}
+	}
+
+	for input, problem := range map[string]string{
+		"solaris-x64":   "unknown platform",
+		"darwn-arm64":   "did you mean darwin-arm64",
+		"linux-riscv64": "unknown platform",
+	} {
+		if _, err := Resolve(input); err == nil || !strings.Contains(err.Error(), problem) {
+			t.Errorf("Resolve(%q) error %v, want %q", input, err, problem)
+		}
+	}
+}
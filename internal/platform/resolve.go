@@ -0,0 +1,170 @@
+package platform
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Common spellings of operating systems, mapped to Go's names
+var osAliases = map[string]string{
+	"darwin":  "darwin",
+	"mac":     "darwin",
+	"macos":   "darwin",
+	"osx":     "darwin",
+	"win":     "windows",
+	"win32":   "windows",
+	"win64":   "windows",
+	"windows": "windows",
+	"linux":   "linux",
+}
+
+// Common spellings of architectures, mapped to Go's names
+var archAliases = map[string]string{
+	"x64":     "amd64",
+	"amd64":   "amd64",
+	"x86_64":  "amd64",
+	"arm64":   "arm64",
+	"aarch64": "arm64",
+	"arm":     "arm64",
+}
+
+// Resolve returns the platform named by user input. Besides exact names it
+// accepts the aliases listed in the registry and combinations of common OS
+// and architecture spellings such as "macos-arm", "win-x64" or "aarch64".
+// A missing OS is taken from the host, as is a missing architecture when the
+// OS is the host's. Empty input selects the host platform.
+func Resolve(input string) (PlatformInfo, error) {
+	name := strings.ToLower(strings.TrimSpace(input))
+	if name == "" {
+		return GetCurrentPlatform()
+	}
+
+	platforms := GetAllPlatforms()
+	for _, p := range platforms {
+		if strings.ToLower(p.Name) == name {
+			return p, nil
+		}
+		for _, alias := range p.Aliases {
+			if strings.ToLower(alias) == name {
+				return p, nil
+			}
+		}
+	}
+
+	goos, goarch, ok := parseOSArch(name)
+	if !ok {
+		return PlatformInfo{}, fmt.Errorf("unknown platform %q%s", input, suggest(name, platforms))
+	}
+
+	// The host fills in what was left out, but its architecture says nothing
+	// about which build of another OS was meant
+	explicitOS, explicitArch := goos != "", goarch != ""
+	if !explicitOS {
+		goos = runtime.GOOS
+	}
+	if !explicitArch && goos == runtime.GOOS {
+		goarch = runtime.GOARCH
+	}
+
+	var matches []string
+	var match PlatformInfo
+	for _, p := range platforms {
+		if p.OS == goos && p.Arch == goarch {
+			matches = append(matches, p.Name)
+			match = p
+		}
+	}
+	if len(matches) == 1 {
+		return match, nil
+	}
+
+	// Nothing fits the host default, or the registry has several candidates
+	if len(matches) == 0 {
+		for _, p := range platforms {
+			if (!explicitOS || p.OS == goos) && (!explicitArch || p.Arch == goarch) {
+				matches = append(matches, p.Name)
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return PlatformInfo{}, fmt.Errorf("no platform for %s/%s (valid: %s)", goos, goarch, strings.Join(GetPlatformNames(), ", "))
+	}
+	return PlatformInfo{}, fmt.Errorf("platform %q is ambiguous; did you mean %s?", input, strings.Join(matches, " or "))
+}
+
+// parseOSArch splits input such as "macos-arm" into Go OS and architecture
+// names. Either may be empty; ok is false if any part is not recognised.
+func parseOSArch(name string) (string, string, bool) {
+	name = strings.ReplaceAll(name, "x86_64", "amd64")
+	name = strings.ReplaceAll(name, "x86-64", "amd64")
+
+	var goos, goarch string
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || r == '/' || r == ' '
+	})
+	for _, part := range parts {
+		if value, ok := osAliases[part]; ok && goos == "" {
+			goos = value
+		} else if value, ok := archAliases[part]; ok && goarch == "" {
+			goarch = value
+		} else {
+			return "", "", false
+		}
+	}
+	return goos, goarch, len(parts) > 0
+}
+
+// suggest returns a "did you mean" hint for an unknown platform name
+func suggest(name string, platforms []PlatformInfo) string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	for _, p := range platforms {
+		lower := strings.ToLower(p.Name)
+		distance := editDistance(name, lower)
+		if strings.Contains(lower, name) || strings.Contains(name, lower) {
+			distance = 0
+		}
+		if distance <= 2 {
+			candidates = append(candidates, candidate{p.Name, distance})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	valid := fmt.Sprintf(" (valid: %s)", strings.Join(GetPlatformNames(), ", "))
+	if len(candidates) == 0 {
+		return valid
+	}
+	names := make([]string, len(candidates))
+	for i, c := range candidates {
+		names[i] = c.name
+	}
+	return fmt.Sprintf("; did you mean %s?%s", strings.Join(names, " or "), valid)
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
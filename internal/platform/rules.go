@@ -0,0 +1,207 @@
+package platform
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// URLRule replaces the filename or URL template for a range of versions,
+// for builds published before upstream settled on today's names
+type URLRule struct {
+	// Short label recorded when the rule matched; defaults to the range
+	Name string `yaml:"name,omitempty" mapstructure:"name" json:"name,omitempty"`
+	// Version range, e.g. "<0.1.5" or ">=0.1.0, <0.2.0"
+	Versions string `yaml:"versions" mapstructure:"versions" json:"versions"`
+	// Upstream filename template for these versions
+	Filename string `yaml:"filename,omitempty" mapstructure:"filename" json:"filename,omitempty"`
+	// URL template for these versions
+	URLTemplate string `yaml:"url_template,omitempty" mapstructure:"url_template" json:"url_template,omitempty"`
+}
+
+// Label returns the name recorded for the rule
+func (r URLRule) Label() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return strings.Join(strings.Fields(r.Versions), "")
+}
+
+// DefaultRule is the label of the URL built without any version rule
+const DefaultRule = "default"
+
+// Candidate is one URL a version may be published under
+type Candidate struct {
+	Rule string // Label of the rule that produced the URL, or DefaultRule
+	URL  string
+}
+
+// Candidates returns every URL a version of a platform may be published
+// under, most specific first: the platform's rules, then the registry's, then
+// the current naming. Only rules whose range includes the version apply.
+func (r *Registry) Candidates(version string, p PlatformInfo) []Candidate {
+	var candidates []Candidate
+	seen := make(map[string]bool)
+	add := func(rule string, info PlatformInfo) {
+		url := r.url(version, info)
+		if !seen[url] {
+			seen[url] = true
+			candidates = append(candidates, Candidate{Rule: rule, URL: url})
+		}
+	}
+
+	for _, rules := range [][]URLRule{p.Rules, r.Rules} {
+		for _, rule := range rules {
+			if !rule.Matches(version) {
+				continue
+			}
+			info := p
+			if rule.Filename != "" {
+				info.Filename = rule.Filename
+			}
+			if rule.URLTemplate != "" {
+				info.URLTemplate = rule.URLTemplate
+			}
+			add(rule.Label(), info)
+		}
+	}
+	add(DefaultRule, p)
+
+	return candidates
+}
+
+// DownloadCandidates returns the URLs a version of a platform may be
+// published under, most specific first
+func DownloadCandidates(version string, p PlatformInfo) []Candidate {
+	return ActiveRegistry().Candidates(version, p)
+}
+
+// Matches reports whether a version falls in the rule's range. Versions that
+// cannot be parsed, such as "latest", match no range.
+func (r URLRule) Matches(version string) bool {
+	constraints, err := parseRange(r.Versions)
+	if err != nil {
+		return false
+	}
+	v, ok := parseSemver(version)
+	if !ok {
+		return false
+	}
+	for _, c := range constraints {
+		if !c.matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// validate checks a rule in the context of the given label
+func (r URLRule) validate(label string) []string {
+	var problems []string
+	ruleLabel := fmt.Sprintf("%s: rule %s", label, r.Label())
+	if r.Versions == "" {
+		problems = append(problems, ruleLabel+": versions is required")
+	} else if _, err := parseRange(r.Versions); err != nil {
+		problems = append(problems, fmt.Sprintf("%s: %v", ruleLabel, err))
+	}
+	if strings.ContainsAny(r.Name, " \t") {
+		problems = append(problems, ruleLabel+": name must not contain spaces")
+	}
+	if r.Filename == "" && r.URLTemplate == "" {
+		problems = append(problems, ruleLabel+": filename or url_template is required")
+	}
+	if r.Filename != "" {
+		if err := checkTemplate(r.Filename, false); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: filename: %v", ruleLabel, err))
+		}
+	}
+	if r.URLTemplate != "" {
+		if err := checkTemplate(r.URLTemplate, true); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: url_template: %v", ruleLabel, err))
+		}
+	}
+	return problems
+}
+
+// semver is the numeric part of a version; pre-release suffixes are ignored
+type semver [3]int
+
+var semverPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:-[0-9A-Za-z.-]+)?$`)
+
+func parseSemver(s string) (semver, bool) {
+	m := semverPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return semver{}, false
+	}
+	var v semver
+	for i := range v {
+		v[i], _ = strconv.Atoi(m[i+1])
+	}
+	return v, true
+}
+
+func (v semver) compare(other semver) int {
+	for i := range v {
+		if v[i] != other[i] {
+			if v[i] < other[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// constraint is one comparison of a version range, e.g. ">=0.1.0"
+type constraint struct {
+	op      string
+	version semver
+}
+
+func (c constraint) matches(v semver) bool {
+	cmp := v.compare(c.version)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	default:
+		return cmp == 0
+	}
+}
+
+var (
+	constraintPattern = regexp.MustCompile(`^(<=|>=|<|>|=)?(\S+)$`)
+	operatorSpace     = regexp.MustCompile(`(<=|>=|<|>|=)\s+`)
+)
+
+// parseRange parses comparisons separated by commas or spaces, all of which
+// must hold; "*" matches every version
+func parseRange(s string) ([]constraint, error) {
+	var constraints []constraint
+	// Join operators to their versions so "< 0.2.0" splits as one comparison
+	joined := operatorSpace.ReplaceAllString(s, "$1")
+	for _, part := range strings.FieldsFunc(joined, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if part == "*" {
+			continue
+		}
+		m := constraintPattern.FindStringSubmatch(part)
+		if m == nil {
+			return nil, fmt.Errorf("invalid version range %q", s)
+		}
+		v, ok := parseSemver(m[2])
+		if !ok {
+			return nil, fmt.Errorf("invalid version %q in range %q", m[2], s)
+		}
+		constraints = append(constraints, constraint{op: m[1], version: v})
+	}
+	if len(constraints) == 0 && strings.TrimSpace(s) != "*" {
+		return nil, fmt.Errorf("empty version range")
+	}
+	return constraints, nil
+}
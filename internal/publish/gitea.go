@@ -0,0 +1,322 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// giteaPageSize is the page size asked for; servers may cap it lower
+const giteaPageSize = 50
+
+// Gitea publishes releases through the Gitea API, which Forgejo shares
+type Gitea struct {
+	rest  restClient
+	web   string // Web base URL, without a trailing slash
+	owner string
+	repo  string
+	ref   string // Branch new tags are created from; empty for the default branch
+	forge string
+	// Largest asset accepted; GiteaMaxAssetSize unless the target overrides it
+	maxAssetSize int64
+	verbose      bool
+}
+
+type giteaRelease struct {
+	ID         int64         `json:"id"`
+	TagName    string        `json:"tag_name"`
+	Name       string        `json:"name"`
+	Body       string        `json:"body"`
+	HTMLURL    string        `json:"html_url"`
+	Assets     []*giteaAsset `json:"assets"`
+	Draft      bool          `json:"draft"`
+	Prerelease bool          `json:"prerelease"`
+}
+
+type giteaAsset struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// NewGitea creates a client for a repository on the Gitea or Forgejo
+// instance at webURL. forge is "gitea" or "forgejo" and only names the
+// target in messages.
+func NewGitea(forge, webURL, repo, token, ref string, verbose bool) (*Gitea, error) {
+	owner, name, err := ParseRepo(repo)
+	if err != nil {
+		return nil, err
+	}
+	web, err := baseURL(webURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s URL: %w", forge, err)
+	}
+	base := strings.TrimSuffix(web.String(), "/")
+
+	g := &Gitea{
+		rest: restClient{
+			http:  http.DefaultClient,
+			base:  fmt.Sprintf("%s/api/v1/repos/%s/%s", base, url.PathEscape(owner), url.PathEscape(name)),
+			forge: forge,
+		},
+		web:          base,
+		owner:        owner,
+		repo:         name,
+		ref:          ref,
+		forge:        forge,
+		maxAssetSize: GiteaMaxAssetSize,
+		verbose:      verbose,
+	}
+	if token != "" {
+		g.rest.auth = func(req *http.Request) {
+			req.Header.Set("Authorization", "token "+token)
+		}
+	}
+	return g, nil
+}
+
+// Forge returns "gitea" or "forgejo"
+func (g *Gitea) Forge() string {
+	return g.forge
+}
+
+// Repo returns the repository as "owner/repo"
+func (g *Gitea) Repo() string {
+	return g.owner + "/" + g.repo
+}
+
+// MaxAssetSize returns the largest attachment the instance accepts
+func (g *Gitea) MaxAssetSize() int64 {
+	return g.maxAssetSize
+}
+
+// ReleaseURL returns the web page of the release for a tag
+func (g *Gitea) ReleaseURL(tag string) string {
+	return fmt.Sprintf("%s/%s/releases/tag/%s", g.web, g.Repo(), url.PathEscape(tag))
+}
+
+// Releases returns every release of the repository
+func (g *Gitea) Releases(ctx context.Context) ([]*RemoteRelease, error) {
+	var all []*RemoteRelease
+	for page := 1; ; page++ {
+		var releases []*giteaRelease
+		query := url.Values{"page": {fmt.Sprint(page)}, "limit": {fmt.Sprint(giteaPageSize)}}
+		if _, err := g.rest.do(ctx, request{Method: http.MethodGet, Path: "releases", Query: query}, &releases); err != nil {
+			return nil, fmt.Errorf("failed to list releases: %w", err)
+		}
+		for _, release := range releases {
+			all = append(all, release.remote())
+		}
+		if len(releases) == 0 {
+			return all, nil
+		}
+	}
+}
+
+// ReleaseByTag returns the release for a tag, or nil if there is none
+func (g *Gitea) ReleaseByTag(ctx context.Context, tag string) (*RemoteRelease, error) {
+	var release giteaRelease
+	_, err := g.rest.do(ctx, request{Method: http.MethodGet, Path: "releases/tags/" + url.PathEscape(tag)}, &release)
+	if isNotFound(err) {
+		return g.draftByTag(ctx, tag)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up release %s: %w", tag, err)
+	}
+	return release.remote(), nil
+}
+
+// draftByTag finds a draft release among the listed releases, since drafts
+// are not found by tag
+func (g *Gitea) draftByTag(ctx context.Context, tag string) (*RemoteRelease, error) {
+	releases, err := g.Releases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, release := range releases {
+		if release.Draft && release.Tag == tag {
+			return release, nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateRelease creates a release, and its tag if the tag does not exist yet
+func (g *Gitea) CreateRelease(ctx context.Context, r Release) (*RemoteRelease, error) {
+	if g.verbose {
+		fmt.Printf("Creating release %s in %s\n", r.Tag, g.Repo())
+	}
+	body := map[string]interface{}{
+		"tag_name":   r.Tag,
+		"name":       r.Name,
+		"body":       r.Body,
+		"draft":      r.Draft,
+		"prerelease": r.Prerelease,
+	}
+	if g.ref != "" {
+		body["target_commitish"] = g.ref
+	}
+	var release giteaRelease
+	if _, err := g.rest.do(ctx, request{Method: http.MethodPost, Path: "releases", Body: jsonBody(body)}, &release); err != nil {
+		return nil, fmt.Errorf("failed to create release %s: %w", r.Tag, err)
+	}
+	return release.remote(), nil
+}
+
+// UpdateNotes replaces the title and body of a release
+func (g *Gitea) UpdateNotes(ctx context.Context, release *RemoteRelease, name, body string) (*RemoteRelease, error) {
+	var updated giteaRelease
+	_, err := g.rest.do(ctx, request{
+		Method: http.MethodPatch,
+		Path:   fmt.Sprintf("releases/%d", release.ID),
+		Body:   jsonBody(map[string]string{"name": name, "body": body}),
+	}, &updated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update release %s: %w", release.Tag, err)
+	}
+	return updated.remote(), nil
+}
+
+// Publish makes a draft release public. Gitea shows the newest published
+// release that is not a prerelease as latest.
+func (g *Gitea) Publish(ctx context.Context, release *RemoteRelease, want Release) (*RemoteRelease, error) {
+	if g.verbose {
+		fmt.Printf("Publishing release %s\n", release.Tag)
+	}
+	var updated giteaRelease
+	_, err := g.rest.do(ctx, request{
+		Method: http.MethodPatch,
+		Path:   fmt.Sprintf("releases/%d", release.ID),
+		Body:   jsonBody(map[string]bool{"draft": false, "prerelease": want.Prerelease}),
+	}, &updated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish release %s: %w", release.Tag, err)
+	}
+	return updated.remote(), nil
+}
+
+// Latest returns the release Gitea shows as latest, the newest published
+// one that is not a prerelease, or nil if there is none. It cannot be
+// chosen, so backfilled versions can take over.
+func (g *Gitea) Latest(ctx context.Context) (*RemoteRelease, error) {
+	var release giteaRelease
+	_, err := g.rest.do(ctx, request{Method: http.MethodGet, Path: "releases/latest"}, &release)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the latest release: %w", err)
+	}
+	return release.remote(), nil
+}
+
+// Assets returns every asset of a release
+func (g *Gitea) Assets(ctx context.Context, release *RemoteRelease) ([]*RemoteAsset, error) {
+	var assets []*giteaAsset
+	if _, err := g.rest.do(ctx, request{Method: http.MethodGet, Path: fmt.Sprintf("releases/%d/assets", release.ID)}, &assets); err != nil {
+		return nil, fmt.Errorf("failed to list assets of %s: %w", release.Tag, err)
+	}
+	all := make([]*RemoteAsset, 0, len(assets))
+	for _, asset := range assets {
+		all = append(all, asset.remote())
+	}
+	return all, nil
+}
+
+// UploadAsset uploads a file to a release under the given asset name
+func (g *Gitea) UploadAsset(ctx context.Context, release *RemoteRelease, name, path string) (*RemoteAsset, error) {
+	if g.verbose {
+		fmt.Printf("Uploading %s to release %s\n", name, release.Tag)
+	}
+
+	// The file is streamed through a pipe, so the boundary is fixed up front
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	body := func() (io.Reader, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		pr, pw := io.Pipe()
+		go func() {
+			defer f.Close()
+			mw := multipart.NewWriter(pw)
+			mw.SetBoundary(boundary)
+			part, err := mw.CreateFormFile("attachment", name)
+			if err == nil {
+				_, err = io.Copy(part, f)
+			}
+			if err == nil {
+				err = mw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		return pr, nil
+	}
+
+	var asset giteaAsset
+	_, err := g.rest.do(ctx, request{
+		Method:      http.MethodPost,
+		Path:        fmt.Sprintf("releases/%d/assets", release.ID),
+		Query:       url.Values{"name": {name}},
+		Body:        body,
+		ContentType: "multipart/form-data; boundary=" + boundary,
+	}, &asset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	return asset.remote(), nil
+}
+
+// DeleteAsset removes an asset from a release
+func (g *Gitea) DeleteAsset(ctx context.Context, release *RemoteRelease, asset *RemoteAsset) error {
+	if g.verbose {
+		fmt.Printf("Deleting asset %s\n", asset.Name)
+	}
+	if _, err := g.rest.do(ctx, request{Method: http.MethodDelete, Path: fmt.Sprintf("releases/%d/assets/%d", release.ID, asset.ID)}, nil); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", asset.Name, err)
+	}
+	return nil
+}
+
+// DownloadAsset opens the contents of an asset
+func (g *Gitea) DownloadAsset(ctx context.Context, release *RemoteRelease, asset *RemoteAsset) (io.ReadCloser, error) {
+	rc, err := g.rest.open(ctx, asset.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", asset.Name, err)
+	}
+	return rc, nil
+}
+
+func (r *giteaRelease) remote() *RemoteRelease {
+	release := &RemoteRelease{
+		ID:   r.ID,
+		Tag:  r.TagName,
+		Name: r.Name,
+		Body: r.Body,
+		URL:  r.HTMLURL,
+
+		Draft:      r.Draft,
+		Prerelease: r.Prerelease,
+	}
+	for _, asset := range r.Assets {
+		release.Assets = append(release.Assets, asset.remote())
+	}
+	return release
+}
+
+func (a *giteaAsset) remote() *RemoteAsset {
+	// Gitea only lists attachments once the upload has completed
+	return &RemoteAsset{
+		ID:       a.ID,
+		Name:     a.Name,
+		Size:     a.Size,
+		Uploaded: true,
+		URL:      a.BrowserDownloadURL,
+	}
+}
@@ -0,0 +1,266 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGitea is an in-memory stand-in for the release API of Gitea and Forgejo
+type fakeGitea struct {
+	mu       sync.Mutex
+	releases []*giteaRelease
+	files    map[int64]string // Attachment contents by ID
+	auth     []string         // Authorization headers seen
+	nextID   int64
+	srv      *httptest.Server
+}
+
+func newFakeGitea(t *testing.T) *fakeGitea {
+	t.Helper()
+	f := &fakeGitea{files: make(map[int64]string), nextID: 1}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeGitea) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+
+	if strings.HasPrefix(r.URL.Path, "/attachments/") {
+		id, _ := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/attachments/"), 10, 64)
+		io.WriteString(w, f.files[id])
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/repos/o/r/")
+	parts := strings.Split(path, "/")
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodGet && path == "releases":
+		// Paged like Gitea: an empty page ends the listing
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		start, end := (page-1)*limit, page*limit
+		if start > len(f.releases) {
+			start = len(f.releases)
+		}
+		if end > len(f.releases) {
+			end = len(f.releases)
+		}
+		json.NewEncoder(w).Encode(f.releases[start:end])
+	case r.Method == http.MethodGet && path == "releases/latest":
+		// The newest published release that is not a prerelease
+		for i := len(f.releases) - 1; i >= 0; i-- {
+			if release := f.releases[i]; !release.Draft && !release.Prerelease {
+				json.NewEncoder(w).Encode(release)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"message":"release not found"}`)
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[1] == "tags":
+		for _, release := range f.releases {
+			if release.TagName == parts[2] && !release.Draft {
+				json.NewEncoder(w).Encode(release)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"message":"release not found"}`)
+	case r.Method == http.MethodPost && path == "releases":
+		var body struct {
+			TagName    string `json:"tag_name"`
+			Name       string `json:"name"`
+			Body       string `json:"body"`
+			Draft      bool   `json:"draft"`
+			Prerelease bool   `json:"prerelease"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		release := &giteaRelease{ID: f.nextID, TagName: body.TagName, Name: body.Name, Body: body.Body, Draft: body.Draft, Prerelease: body.Prerelease}
+		f.nextID++
+		f.releases = append(f.releases, release)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(release)
+	case r.Method == http.MethodPatch && len(parts) == 2:
+		release := f.find(parts[1])
+		if release == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var edit map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&edit)
+		if v, ok := edit["body"].(string); ok {
+			release.Body = v
+		}
+		if v, ok := edit["draft"].(bool); ok {
+			release.Draft = v
+		}
+		json.NewEncoder(w).Encode(release)
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[2] == "assets":
+		release := f.find(parts[1])
+		json.NewEncoder(w).Encode(release.Assets)
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[2] == "assets":
+		release := f.find(parts[1])
+		file, header, err := r.FormFile("attachment")
+		if release == nil || err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		asset := &giteaAsset{ID: f.nextID, Name: r.URL.Query().Get("name"), Size: int64(len(data))}
+		asset.BrowserDownloadURL = fmt.Sprintf("%s/attachments/%d", f.srv.URL, asset.ID)
+		if header.Filename != asset.Name {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.nextID++
+		f.files[asset.ID] = string(data)
+		release.Assets = append(release.Assets, asset)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(asset)
+	case r.Method == http.MethodDelete && len(parts) == 4 && parts[2] == "assets":
+		release := f.find(parts[1])
+		id, _ := strconv.ParseInt(parts[3], 10, 64)
+		for i, asset := range release.Assets {
+			if asset.ID == id {
+				release.Assets = append(release.Assets[:i], release.Assets[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"message":"unhandled %s %s"}`, r.Method, r.URL.Path)
+	}
+}
+
+func (f *fakeGitea) find(id string) *giteaRelease {
+	n, _ := strconv.ParseInt(id, 10, 64)
+	for _, release := range f.releases {
+		if release.ID == n {
+			return release
+		}
+	}
+	return nil
+}
+
+func TestGiteaCreateUploadDelete(t *testing.T) {
+	f := newFakeGitea(t)
+	g, err := NewGitea("forgejo", f.srv.URL, "o/r", "secret", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	release, err := g.CreateRelease(ctx, Release{Tag: "v0.2.1", Name: "Qoder 0.2.1", Body: "notes", Draft: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !release.Draft {
+		t.Errorf("release was not created as a draft")
+	}
+	// Drafts are only found through the listing
+	found, err := g.ReleaseByTag(ctx, "v0.2.1")
+	if err != nil || found == nil || found.ID != release.ID {
+		t.Fatalf("ReleaseByTag = %+v, %v", found, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "file.dmg")
+	if err := os.WriteFile(path, []byte("disk image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	asset, err := g.UploadAsset(ctx, release, "qoder-0.2.1-darwin-arm64.dmg", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Name != "qoder-0.2.1-darwin-arm64.dmg" || asset.Size != int64(len("disk image")) {
+		t.Errorf("uploaded asset = %+v", asset)
+	}
+
+	rc, err := g.DownloadAsset(ctx, release, asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "disk image" {
+		t.Errorf("downloaded %q", data)
+	}
+
+	if err := g.DeleteAsset(ctx, release, asset); err != nil {
+		t.Fatal(err)
+	}
+	assets, err := g.Assets(ctx, release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assets) != 0 {
+		t.Errorf("assets after delete = %v", assets)
+	}
+
+	published, err := g.Publish(ctx, release, Release{})
+	if err != nil || published.Draft {
+		t.Errorf("Publish = %+v, %v", published, err)
+	}
+	for _, auth := range f.auth {
+		if auth != "token secret" {
+			t.Errorf("Authorization = %q, want token secret", auth)
+		}
+	}
+}
+
+func TestGiteaReleasesPages(t *testing.T) {
+	f := newFakeGitea(t)
+	for i := 0; i < giteaPageSize+5; i++ {
+		f.releases = append(f.releases, &giteaRelease{ID: int64(i + 1), TagName: fmt.Sprintf("v0.0.%d", i)})
+	}
+	g, err := NewGitea("gitea", f.srv.URL, "o/r", "", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	releases, err := g.Releases(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != giteaPageSize+5 {
+		t.Errorf("listed %d releases, want %d", len(releases), giteaPageSize+5)
+	}
+}
+
+func TestGiteaLatest(t *testing.T) {
+	f := newFakeGitea(t)
+	g, err := NewGitea("gitea", f.srv.URL, "o/r", "", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if latest, err := g.Latest(ctx); err != nil || latest != nil {
+		t.Fatalf("Latest without releases = %+v, %v", latest, err)
+	}
+	f.releases = []*giteaRelease{
+		{ID: 1, TagName: "v0.2.1"},
+		{ID: 2, TagName: "v0.1.9"}, // Backfilled, so Gitea shows it as latest
+		{ID: 3, TagName: "v0.3.0-beta", Prerelease: true},
+	}
+	latest, err := g.Latest(ctx)
+	if err != nil || latest == nil || latest.Tag != "v0.1.9" {
+		t.Errorf("Latest = %+v, %v; want v0.1.9", latest, err)
+	}
+	if _, ok := interface{}(g).(LatestMarker); ok {
+		t.Errorf("Gitea claims the latest release can be chosen")
+	}
+}
@@ -0,0 +1,404 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+	"golang.org/x/oauth2"
+)
+
+// Options configures access to a GitHub repository
+type Options struct {
+	Token        string
+	Repo         string // owner/repo
+	APIURL       string // REST API base; empty for github.com
+	UploadURL    string // Asset upload base; derived from APIURL when empty
+	CacheDir     string // Where API responses are cached for conditional requests; empty disables caching
+	MaxAssetSize int64  // Largest asset accepted; GitHubMaxAssetSize when zero
+	Verbose      bool
+}
+
+// GitHub publishes releases through the GitHub REST API
+type GitHub struct {
+	client       *github.Client
+	owner        string
+	repo         string
+	maxAssetSize int64
+	verbose      bool
+}
+
+// NewGitHub creates a client for the repository in opts
+func NewGitHub(ctx context.Context, opts Options) (*GitHub, error) {
+	owner, repo, err := ParseRepo(opts.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{Transport: http.DefaultTransport}
+	if opts.CacheDir != "" {
+		httpClient.Transport = &etagTransport{dir: opts.CacheDir, base: http.DefaultTransport}
+	}
+	if opts.Token != "" {
+		// The token is added before the cache sees the request
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+		httpClient = oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: opts.Token}))
+	}
+	client := github.NewClient(httpClient)
+
+	if opts.APIURL != "" {
+		base, err := baseURL(opts.APIURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid API URL: %w", err)
+		}
+		client.BaseURL = base
+
+		upload := opts.UploadURL
+		if upload == "" {
+			// GitHub Enterprise serves uploads next to the API
+			upload = strings.Replace(base.String(), "/api/v3/", "/api/uploads/", 1)
+		}
+		if client.UploadURL, err = baseURL(upload); err != nil {
+			return nil, fmt.Errorf("invalid upload URL: %w", err)
+		}
+	}
+
+	maxAssetSize := opts.MaxAssetSize
+	if maxAssetSize <= 0 {
+		maxAssetSize = GitHubMaxAssetSize
+	}
+	return &GitHub{client: client, owner: owner, repo: repo, maxAssetSize: maxAssetSize, verbose: opts.Verbose}, nil
+}
+
+// ParseRepo splits "owner/repo"
+func ParseRepo(repo string) (string, string, error) {
+	parts := strings.Split(repo, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid repo format %q, expected 'owner/repo'", repo)
+	}
+	return parts[0], parts[1], nil
+}
+
+// baseURL parses an API base URL, which go-github requires to end in a slash
+func baseURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute URL", raw)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u, nil
+}
+
+// Forge returns "github"
+func (g *GitHub) Forge() string {
+	return "github"
+}
+
+// Repo returns the repository as "owner/repo"
+func (g *GitHub) Repo() string {
+	return g.owner + "/" + g.repo
+}
+
+// MaxAssetSize returns the largest asset the repository accepts
+func (g *GitHub) MaxAssetSize() int64 {
+	return g.maxAssetSize
+}
+
+// ReleaseURL returns the web page of the release for a tag
+func (g *GitHub) ReleaseURL(tag string) string {
+	return fmt.Sprintf("%s/%s/releases/tag/%s", g.webURL(), g.Repo(), url.PathEscape(tag))
+}
+
+// AssetURL returns the browser download link of an asset of the release for
+// a tag, which works without the asset's ID
+func (g *GitHub) AssetURL(tag, name string) string {
+	return fmt.Sprintf("%s/%s/releases/download/%s/%s", g.webURL(), g.Repo(), url.PathEscape(tag), url.PathEscape(name))
+}
+
+// webURL returns the web host of the API URL, following the GitHub Enterprise
+// layout
+func (g *GitHub) webURL() string {
+	if u := *g.client.BaseURL; u.Host != "api.github.com" {
+		u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/api/v3")
+		return strings.TrimSuffix(u.String(), "/")
+	}
+	return "https://github.com"
+}
+
+// Releases returns every release of the repository
+func (g *GitHub) Releases(ctx context.Context) ([]*RemoteRelease, error) {
+	var all []*RemoteRelease
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		var releases []*github.RepositoryRelease
+		var resp *github.Response
+		err := g.withRetry(ctx, func() (err error) {
+			releases, resp, err = g.client.Repositories.ListReleases(ctx, g.owner, g.repo, opts)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list releases: %w", err)
+		}
+		for _, release := range releases {
+			all = append(all, githubRelease(release))
+		}
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// ReleaseByTag returns the release for a tag, or nil if there is none
+func (g *GitHub) ReleaseByTag(ctx context.Context, tag string) (*RemoteRelease, error) {
+	var release *github.RepositoryRelease
+	var resp *github.Response
+	err := g.withRetry(ctx, func() (err error) {
+		release, resp, err = g.client.Repositories.GetReleaseByTag(ctx, g.owner, g.repo, tag)
+		return err
+	})
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return g.draftByTag(ctx, tag)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up release %s: %w", tag, err)
+	}
+	return githubRelease(release), nil
+}
+
+// draftByTag finds a draft release among the listed releases. Drafts have
+// no tag yet, so looking them up by tag finds nothing.
+func (g *GitHub) draftByTag(ctx context.Context, tag string) (*RemoteRelease, error) {
+	releases, err := g.Releases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, release := range releases {
+		if release.Draft && release.Tag == tag {
+			return release, nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateRelease creates a release, and its tag if the tag does not exist yet
+func (g *GitHub) CreateRelease(ctx context.Context, r Release) (*RemoteRelease, error) {
+	if g.verbose {
+		fmt.Printf("Creating release %s in %s\n", r.Tag, g.Repo())
+	}
+	var release *github.RepositoryRelease
+	err := g.withRetry(ctx, func() (err error) {
+		release, _, err = g.client.Repositories.CreateRelease(ctx, g.owner, g.repo, &github.RepositoryRelease{
+			TagName:    github.String(r.Tag),
+			Name:       github.String(r.Name),
+			Body:       github.String(r.Body),
+			Draft:      github.Bool(r.Draft),
+			Prerelease: github.Bool(r.Prerelease),
+			// Backfilled versions must not become latest; see MarkLatest
+			MakeLatest: github.String("false"),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create release %s: %w", r.Tag, err)
+	}
+	return githubRelease(release), nil
+}
+
+// UpdateNotes replaces the title and body of a release
+func (g *GitHub) UpdateNotes(ctx context.Context, release *RemoteRelease, name, body string) (*RemoteRelease, error) {
+	var updated *github.RepositoryRelease
+	err := g.withRetry(ctx, func() (err error) {
+		updated, _, err = g.client.Repositories.EditRelease(ctx, g.owner, g.repo, release.ID, &github.RepositoryRelease{
+			Name: github.String(name),
+			Body: github.String(body),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update release %s: %w", release.Tag, err)
+	}
+	return githubRelease(updated), nil
+}
+
+// Publish makes a draft release public. It is not made latest, which is
+// left to MarkLatest.
+func (g *GitHub) Publish(ctx context.Context, release *RemoteRelease, want Release) (*RemoteRelease, error) {
+	if g.verbose {
+		fmt.Printf("Publishing release %s\n", release.Tag)
+	}
+	var updated *github.RepositoryRelease
+	err := g.withRetry(ctx, func() (err error) {
+		updated, _, err = g.client.Repositories.EditRelease(ctx, g.owner, g.repo, release.ID, &github.RepositoryRelease{
+			Draft:      github.Bool(false),
+			Prerelease: github.Bool(want.Prerelease),
+			MakeLatest: github.String("false"),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish release %s: %w", release.Tag, err)
+	}
+	return githubRelease(updated), nil
+}
+
+// Latest returns the release GitHub shows as latest, or nil if there is none
+func (g *GitHub) Latest(ctx context.Context) (*RemoteRelease, error) {
+	var release *github.RepositoryRelease
+	var resp *github.Response
+	err := g.withRetry(ctx, func() (err error) {
+		release, resp, err = g.client.Repositories.GetLatestRelease(ctx, g.owner, g.repo)
+		return err
+	})
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the latest release: %w", err)
+	}
+	return githubRelease(release), nil
+}
+
+// MarkLatest shows a published release as latest
+func (g *GitHub) MarkLatest(ctx context.Context, release *RemoteRelease) error {
+	if g.verbose {
+		fmt.Printf("Marking release %s as latest\n", release.Tag)
+	}
+	err := g.withRetry(ctx, func() error {
+		_, _, err := g.client.Repositories.EditRelease(ctx, g.owner, g.repo, release.ID, &github.RepositoryRelease{
+			MakeLatest: github.String("true"),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark release %s as latest: %w", release.Tag, err)
+	}
+	return nil
+}
+
+// githubDigestAsset is a release asset with the digest GitHub reports for
+// newer uploads, which go-github does not decode yet
+type githubDigestAsset struct {
+	github.ReleaseAsset
+	Digest string `json:"digest"` // "sha256:" and the hex checksum
+}
+
+// Assets returns every asset of a release, with the SHA-256 GitHub reports
+func (g *GitHub) Assets(ctx context.Context, release *RemoteRelease) ([]*RemoteAsset, error) {
+	var all []*RemoteAsset
+	for page := 1; page != 0; {
+		var assets []*githubDigestAsset
+		var resp *github.Response
+		err := g.withRetry(ctx, func() error {
+			req, err := g.client.NewRequest(http.MethodGet, fmt.Sprintf("repos/%s/%s/releases/%d/assets?per_page=100&page=%d", g.owner, g.repo, release.ID, page), nil)
+			if err != nil {
+				return err
+			}
+			assets = nil
+			resp, err = g.client.Do(ctx, req, &assets)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list assets of %s: %w", release.Tag, err)
+		}
+		for _, a := range assets {
+			asset := githubAsset(&a.ReleaseAsset)
+			if sum, ok := strings.CutPrefix(a.Digest, "sha256:"); ok {
+				asset.SHA256 = sum
+			}
+			all = append(all, asset)
+		}
+		page = resp.NextPage
+	}
+	return all, nil
+}
+
+// UploadAsset uploads a file to a release under the given asset name
+func (g *GitHub) UploadAsset(ctx context.Context, release *RemoteRelease, name, path string) (*RemoteAsset, error) {
+	if g.verbose {
+		fmt.Printf("Uploading %s to release %s\n", name, release.Tag)
+	}
+	var asset *github.ReleaseAsset
+	err := g.withRetry(ctx, func() error {
+		// Reopened for every attempt, since a failed upload consumes the file
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		asset, _, err = g.client.Repositories.UploadReleaseAsset(ctx, g.owner, g.repo, release.ID, &github.UploadOptions{
+			Name:      name,
+			MediaType: ContentType(name),
+		}, f)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	return githubAsset(asset), nil
+}
+
+// DeleteAsset removes an asset from a release
+func (g *GitHub) DeleteAsset(ctx context.Context, release *RemoteRelease, asset *RemoteAsset) error {
+	if g.verbose {
+		fmt.Printf("Deleting asset %s\n", asset.Name)
+	}
+	err := g.withRetry(ctx, func() error {
+		_, err := g.client.Repositories.DeleteReleaseAsset(ctx, g.owner, g.repo, asset.ID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", asset.Name, err)
+	}
+	return nil
+}
+
+// DownloadAsset opens the contents of an asset
+func (g *GitHub) DownloadAsset(ctx context.Context, release *RemoteRelease, asset *RemoteAsset) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	err := g.withRetry(ctx, func() (err error) {
+		rc, _, err = g.client.Repositories.DownloadReleaseAsset(ctx, g.owner, g.repo, asset.ID, http.DefaultClient)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", asset.Name, err)
+	}
+	return rc, nil
+}
+
+func githubRelease(r *github.RepositoryRelease) *RemoteRelease {
+	release := &RemoteRelease{
+		ID:   r.GetID(),
+		Tag:  r.GetTagName(),
+		Name: r.GetName(),
+		Body: r.GetBody(),
+		URL:  r.GetHTMLURL(),
+
+		Draft:      r.GetDraft(),
+		Prerelease: r.GetPrerelease(),
+	}
+	for _, asset := range r.Assets {
+		release.Assets = append(release.Assets, githubAsset(asset))
+	}
+	return release
+}
+
+func githubAsset(a *github.ReleaseAsset) *RemoteAsset {
+	return &RemoteAsset{
+		ID:       a.GetID(),
+		Name:     a.GetName(),
+		Size:     int64(a.GetSize()),
+		Uploaded: a.GetState() == "uploaded",
+		URL:      a.GetBrowserDownloadURL(),
+	}
+}
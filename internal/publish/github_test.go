@@ -0,0 +1,333 @@
+package publish
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGitHub is an in-memory stand-in for the parts of the GitHub REST API
+// the publisher uses. Handlers can be overridden per test with intercept.
+type fakeGitHub struct {
+	mu       sync.Mutex
+	releases []map[string]interface{}
+	uploads  map[string]string // Asset name to the Content-Type it was uploaded with
+	nextID   int64
+	requests []string
+
+	// intercept may answer a request itself by returning true
+	intercept func(w http.ResponseWriter, r *http.Request) bool
+}
+
+func newFakeGitHub(t *testing.T) (*fakeGitHub, *httptest.Server) {
+	t.Helper()
+	f := &fakeGitHub{uploads: make(map[string]string), nextID: 1}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeGitHub) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	intercept := f.intercept
+	f.mu.Unlock()
+	if intercept != nil && intercept(w, r) {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	path := r.URL.Path
+	switch {
+	case r.Method == http.MethodGet && path == "/api/v3/repos/o/r/releases":
+		json.NewEncoder(w).Encode(f.releases)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/api/v3/repos/o/r/releases/tags/"):
+		tag := strings.TrimPrefix(path, "/api/v3/repos/o/r/releases/tags/")
+		for _, release := range f.releases {
+			if release["tag_name"] == tag && release["draft"] != true {
+				json.NewEncoder(w).Encode(release)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"message":"Not Found"}`)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/assets") && strings.HasPrefix(path, "/api/v3/repos/o/r/releases/"):
+		release := f.find(strings.TrimSuffix(strings.TrimPrefix(path, "/api/v3/repos/o/r/releases/"), "/assets"))
+		if release == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(release["assets"])
+	case r.Method == http.MethodPost && path == "/api/v3/repos/o/r/releases":
+		var release map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&release)
+		release["id"] = f.nextID
+		release["assets"] = []interface{}{}
+		f.nextID++
+		f.releases = append(f.releases, release)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(release)
+	case r.Method == http.MethodPatch && strings.HasPrefix(path, "/api/v3/repos/o/r/releases/"):
+		release := f.find(strings.TrimPrefix(path, "/api/v3/repos/o/r/releases/"))
+		if release == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var edit map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&edit)
+		for key, value := range edit {
+			release[key] = value
+		}
+		json.NewEncoder(w).Encode(release)
+	case r.Method == http.MethodPost && strings.HasPrefix(path, "/api/uploads/repos/o/r/releases/"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/api/uploads/repos/o/r/releases/"), "/assets")
+		release := f.find(id)
+		if release == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		name := r.URL.Query().Get("name")
+		f.uploads[name] = r.Header.Get("Content-Type")
+		sum := sha256.Sum256(data)
+		asset := map[string]interface{}{"id": f.nextID, "name": name, "size": len(data), "state": "uploaded", "digest": "sha256:" + hex.EncodeToString(sum[:])}
+		f.nextID++
+		release["assets"] = append(release["assets"].([]interface{}), asset)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(asset)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"message":"unhandled %s %s"}`, r.Method, path)
+	}
+}
+
+func (f *fakeGitHub) find(id string) map[string]interface{} {
+	n, _ := strconv.ParseInt(id, 10, 64)
+	for _, release := range f.releases {
+		if release["id"] == n || release["id"] == float64(n) {
+			return release
+		}
+	}
+	return nil
+}
+
+func (f *fakeGitHub) count(request string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, r := range f.requests {
+		if r == request {
+			n++
+		}
+	}
+	return n
+}
+
+func newTestGitHub(t *testing.T, srv *httptest.Server, cacheDir string) *GitHub {
+	t.Helper()
+	g, err := NewGitHub(context.Background(), Options{Repo: "o/r", APIURL: srv.URL + "/api/v3/", CacheDir: cacheDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestGitHubCreateUploadUpdate(t *testing.T) {
+	f, srv := newFakeGitHub(t)
+	g := newTestGitHub(t, srv, "")
+	ctx := context.Background()
+
+	release, err := g.CreateRelease(ctx, Release{Tag: "v0.2.1", Name: "Qoder 0.2.1", Body: "notes", Prerelease: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if release.Tag != "v0.2.1" || release.Name != "Qoder 0.2.1" || !release.Prerelease {
+		t.Fatalf("created release = %+v", release)
+	}
+	if latest := f.releases[0]["make_latest"]; latest != "false" {
+		t.Errorf("make_latest = %v, want false", latest)
+	}
+
+	dir := t.TempDir()
+	for name, want := range map[string]string{
+		"qoder-0.2.1-darwin-arm64.dmg":   "application/x-apple-diskimage",
+		"qoder-0.2.1-linux-x64.tar.gz":   "application/gzip",
+		"qoder-0.2.1-linux-x64.AppImage": "application/vnd.appimage",
+		"SHA256SUMS.asc":                 "application/pgp-signature",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("contents of "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		asset, err := g.UploadAsset(ctx, release, name, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if asset.Name != name || asset.Size != int64(len("contents of "+name)) || !asset.Uploaded {
+			t.Errorf("uploaded asset = %+v", asset)
+		}
+		if got := f.uploads[name]; got != want {
+			t.Errorf("%s uploaded as %q, want %q", name, got, want)
+		}
+	}
+
+	assets, err := g.Assets(ctx, release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assets) != 4 {
+		t.Fatalf("listed %d assets, want 4", len(assets))
+	}
+	for _, asset := range assets {
+		sum := sha256.Sum256([]byte("contents of " + asset.Name))
+		if asset.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("%s has digest %q", asset.Name, asset.SHA256)
+		}
+	}
+
+	updated, err := g.UpdateNotes(ctx, release, "Qoder 0.2.1", "new notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Body != "new notes" {
+		t.Errorf("body = %q, want new notes", updated.Body)
+	}
+}
+
+func TestGitHubReleaseByTagFindsDraft(t *testing.T) {
+	f, srv := newFakeGitHub(t)
+	g := newTestGitHub(t, srv, "")
+	ctx := context.Background()
+
+	if _, err := g.CreateRelease(ctx, Release{Tag: "v0.2.1", Draft: true}); err != nil {
+		t.Fatal(err)
+	}
+	release, err := g.ReleaseByTag(ctx, "v0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if release == nil || !release.Draft {
+		t.Fatalf("ReleaseByTag = %+v, want the draft", release)
+	}
+	if f.count("GET /api/v3/repos/o/r/releases") != 1 {
+		t.Errorf("draft was not looked up in the listing")
+	}
+
+	missing, err := g.ReleaseByTag(ctx, "v9.9.9")
+	if err != nil || missing != nil {
+		t.Errorf("ReleaseByTag(v9.9.9) = %+v, %v; want nil, nil", missing, err)
+	}
+}
+
+func TestGitHubRetriesRateLimits(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		limit func(w http.ResponseWriter)
+	}{
+		{"primary", func(w http.ResponseWriter) {
+			// Reset already passed, so no time is spent waiting
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(-2*time.Second).Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"message":"API rate limit exceeded"}`)
+		}},
+		{"secondary", func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"message":"You have exceeded a secondary rate limit","documentation_url":"https://docs.github.com/rest/overview/resources-in-the-rest-api#secondary-rate-limits"}`)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, srv := newFakeGitHub(t)
+			g := newTestGitHub(t, srv, "")
+			limited := 2
+			f.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+				if limited == 0 {
+					return false
+				}
+				limited--
+				w.Header().Set("Content-Type", "application/json")
+				tc.limit(w)
+				return true
+			}
+
+			if _, err := g.Releases(context.Background()); err != nil {
+				t.Fatalf("Releases failed after rate limiting: %v", err)
+			}
+			if n := f.count("GET /api/v3/repos/o/r/releases"); n != 3 {
+				t.Errorf("%d requests, want 3", n)
+			}
+		})
+	}
+}
+
+func TestGitHubRateLimitGivesUp(t *testing.T) {
+	f, srv := newFakeGitHub(t)
+	g := newTestGitHub(t, srv, "")
+	f.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(2*maxRateLimitWait).Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `{"message":"API rate limit exceeded"}`)
+		return true
+	}
+	if _, err := g.Releases(context.Background()); err == nil || !strings.Contains(err.Error(), "not waiting") {
+		t.Errorf("err = %v, want a refusal to wait", err)
+	}
+}
+
+func TestETagTransportServesNotModifiedFromCache(t *testing.T) {
+	f, srv := newFakeGitHub(t)
+	served := 0
+	f.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodGet {
+			return false
+		}
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(100-served))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+		served++
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, `[{"id":7,"tag_name":"v0.2.1","name":"Qoder 0.2.1"}]`)
+		return true
+	}
+
+	cacheDir := t.TempDir()
+	for i := 0; i < 3; i++ {
+		// A fresh client each time, so only the disk cache carries over
+		g := newTestGitHub(t, srv, cacheDir)
+		releases, err := g.Releases(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(releases) != 1 || releases[0].Tag != "v0.2.1" || releases[0].ID != 7 {
+			t.Fatalf("attempt %d: releases = %+v", i, releases)
+		}
+	}
+	if served != 1 {
+		t.Errorf("full response served %d times, want 1", served)
+	}
+	if n := f.count("GET /api/v3/repos/o/r/releases"); n != 3 {
+		t.Errorf("%d requests, want 3", n)
+	}
+}
@@ -0,0 +1,398 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultGitLabPackage is the generic package GitLab release files are
+// uploaded to when none is configured
+const DefaultGitLabPackage = "qoder"
+
+// GitLab publishes releases through the GitLab REST API. GitLab releases
+// have no attachments of their own: files are uploaded to the project's
+// generic package registry, one package version per tag, and linked from
+// the release.
+type GitLab struct {
+	rest    restClient
+	api     string // API base URL, without a trailing slash
+	web     string // Web base URL, without a trailing slash
+	project string // Path such as "group/project"
+	ref     string // Branch new tags are created from
+	pkg     string // Generic package name
+	// Largest asset accepted; GitLabMaxAssetSize unless the target overrides it
+	maxAssetSize int64
+	verbose      bool
+}
+
+type gitlabRelease struct {
+	TagName     string `json:"tag_name"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Links       struct {
+		Self string `json:"self"`
+	} `json:"_links"`
+	Assets struct {
+		Links []*gitlabLink `json:"links"`
+	} `json:"assets"`
+}
+
+type gitlabLink struct {
+	ID             int64  `json:"id"`
+	Name           string `json:"name"`
+	URL            string `json:"url"`
+	DirectAssetURL string `json:"direct_asset_url"`
+}
+
+type gitlabPackage struct {
+	ID int64 `json:"id"`
+}
+
+type gitlabPackageFile struct {
+	ID       int64  `json:"id"`
+	FileName string `json:"file_name"`
+	Size     int64  `json:"size"`
+}
+
+// NewGitLab creates a client for a project on the GitLab instance at
+// webURL. ref is the branch new tags are created from (default "main") and
+// pkg the generic package files are uploaded to.
+func NewGitLab(webURL, project, token, ref, pkg string, verbose bool) (*GitLab, error) {
+	project = strings.Trim(project, "/")
+	if !strings.Contains(project, "/") {
+		return nil, fmt.Errorf("invalid project %q, expected 'group/project'", project)
+	}
+	web, err := baseURL(webURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitLab URL: %w", err)
+	}
+	base := strings.TrimSuffix(web.String(), "/")
+	if ref == "" {
+		ref = "main"
+	}
+	if pkg == "" {
+		pkg = DefaultGitLabPackage
+	}
+
+	api := base + "/api/v4"
+	g := &GitLab{
+		rest: restClient{
+			http:  http.DefaultClient,
+			base:  api + "/projects/" + url.PathEscape(project),
+			forge: "GitLab",
+		},
+		api:          api,
+		web:          base,
+		project:      project,
+		ref:          ref,
+		pkg:          pkg,
+		maxAssetSize: GitLabMaxAssetSize,
+		verbose:      verbose,
+	}
+	if token != "" {
+		g.rest.auth = func(req *http.Request) {
+			req.Header.Set("PRIVATE-TOKEN", token)
+		}
+	}
+	return g, nil
+}
+
+// Forge returns "gitlab"
+func (g *GitLab) Forge() string {
+	return "gitlab"
+}
+
+// Repo returns the project path
+func (g *GitLab) Repo() string {
+	return g.project
+}
+
+// MaxAssetSize returns the largest package file the instance accepts
+func (g *GitLab) MaxAssetSize() int64 {
+	return g.maxAssetSize
+}
+
+// ReleaseURL returns the web page of the release for a tag
+func (g *GitLab) ReleaseURL(tag string) string {
+	return fmt.Sprintf("%s/%s/-/releases/%s", g.web, g.project, url.PathEscape(tag))
+}
+
+// Releases returns every release of the project
+func (g *GitLab) Releases(ctx context.Context) ([]*RemoteRelease, error) {
+	var all []*RemoteRelease
+	page := "1"
+	for page != "" {
+		var releases []*gitlabRelease
+		resp, err := g.rest.do(ctx, request{
+			Method: http.MethodGet,
+			Path:   "releases",
+			Query:  url.Values{"page": {page}, "per_page": {"100"}},
+		}, &releases)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list releases: %w", err)
+		}
+		for _, release := range releases {
+			all = append(all, release.remote())
+		}
+		page = resp.Header.Get("X-Next-Page")
+	}
+	return all, nil
+}
+
+// ReleaseByTag returns the release for a tag, or nil if there is none
+func (g *GitLab) ReleaseByTag(ctx context.Context, tag string) (*RemoteRelease, error) {
+	var release gitlabRelease
+	_, err := g.rest.do(ctx, request{Method: http.MethodGet, Path: "releases/" + url.PathEscape(tag)}, &release)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up release %s: %w", tag, err)
+	}
+	return release.remote(), nil
+}
+
+// CreateRelease creates a release, and its tag from the configured branch
+// if the tag does not exist yet. GitLab shows the release with the latest
+// released_at as latest, so it is set to the date of the version to keep
+// backfilled versions from taking over.
+func (g *GitLab) CreateRelease(ctx context.Context, r Release) (*RemoteRelease, error) {
+	if g.verbose {
+		fmt.Printf("Creating release %s in %s\n", r.Tag, g.project)
+	}
+	body := map[string]string{
+		"tag_name":    r.Tag,
+		"name":        r.Name,
+		"description": r.Body,
+		"ref":         g.ref,
+	}
+	if !r.Date.IsZero() {
+		body["released_at"] = r.Date.UTC().Format(time.RFC3339)
+	}
+	var release gitlabRelease
+	_, err := g.rest.do(ctx, request{
+		Method: http.MethodPost,
+		Path:   "releases",
+		Body:   jsonBody(body),
+	}, &release)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create release %s: %w", r.Tag, err)
+	}
+	return release.remote(), nil
+}
+
+// Latest returns the release GitLab shows as latest, the one released last,
+// or nil if there is none
+func (g *GitLab) Latest(ctx context.Context) (*RemoteRelease, error) {
+	var releases []*gitlabRelease
+	_, err := g.rest.do(ctx, request{
+		Method: http.MethodGet,
+		Path:   "releases",
+		Query:  url.Values{"order_by": {"released_at"}, "sort": {"desc"}, "per_page": {"1"}},
+	}, &releases)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the latest release: %w", err)
+	}
+	if len(releases) == 0 {
+		return nil, nil
+	}
+	return releases[0].remote(), nil
+}
+
+// UpdateNotes replaces the title and description of a release
+func (g *GitLab) UpdateNotes(ctx context.Context, release *RemoteRelease, name, body string) (*RemoteRelease, error) {
+	var updated gitlabRelease
+	_, err := g.rest.do(ctx, request{
+		Method: http.MethodPut,
+		Path:   "releases/" + url.PathEscape(release.Tag),
+		Body:   jsonBody(map[string]string{"name": name, "description": body}),
+	}, &updated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update release %s: %w", release.Tag, err)
+	}
+	return updated.remote(), nil
+}
+
+// Publish returns the release as it is: GitLab has neither drafts nor
+// prereleases, so releases are public from the start
+func (g *GitLab) Publish(ctx context.Context, release *RemoteRelease, want Release) (*RemoteRelease, error) {
+	return release, nil
+}
+
+// Assets returns the links of a release. Sizes are taken from the package
+// files the links point to; links elsewhere have an unknown size.
+func (g *GitLab) Assets(ctx context.Context, release *RemoteRelease) ([]*RemoteAsset, error) {
+	var links []*gitlabLink
+	page := "1"
+	for page != "" {
+		var batch []*gitlabLink
+		resp, err := g.rest.do(ctx, request{
+			Method: http.MethodGet,
+			Path:   fmt.Sprintf("releases/%s/assets/links", url.PathEscape(release.Tag)),
+			Query:  url.Values{"page": {page}, "per_page": {"100"}},
+		}, &batch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list assets of %s: %w", release.Tag, err)
+		}
+		links = append(links, batch...)
+		page = resp.Header.Get("X-Next-Page")
+	}
+
+	sizes, err := g.packageSizes(ctx, release.Tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list package files of %s: %w", release.Tag, err)
+	}
+
+	assets := make([]*RemoteAsset, 0, len(links))
+	for _, link := range links {
+		asset := link.remote()
+		if size, ok := sizes[link.Name]; ok && link.URL == g.packageURL(release.Tag, link.Name) {
+			asset.Size = size
+		}
+		assets = append(assets, asset)
+	}
+	return assets, nil
+}
+
+// packageSizes returns the size of every file in the package version of a
+// tag. When a file was uploaded more than once the latest upload counts, as
+// it is the one GitLab serves.
+func (g *GitLab) packageSizes(ctx context.Context, tag string) (map[string]int64, error) {
+	var packages []*gitlabPackage
+	_, err := g.rest.do(ctx, request{
+		Method: http.MethodGet,
+		Path:   "packages",
+		Query: url.Values{
+			"package_type":    {"generic"},
+			"package_name":    {g.pkg},
+			"package_version": {tag},
+		},
+	}, &packages)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]int64)
+	for _, pkg := range packages {
+		page := "1"
+		for page != "" {
+			var files []*gitlabPackageFile
+			resp, err := g.rest.do(ctx, request{
+				Method: http.MethodGet,
+				Path:   fmt.Sprintf("packages/%d/package_files", pkg.ID),
+				Query:  url.Values{"page": {page}, "per_page": {"100"}},
+			}, &files)
+			if err != nil {
+				return nil, err
+			}
+			// Listed oldest first
+			for _, file := range files {
+				sizes[file.FileName] = file.Size
+			}
+			page = resp.Header.Get("X-Next-Page")
+		}
+	}
+	return sizes, nil
+}
+
+// packageURL returns the API URL of a file in the package version of a tag
+func (g *GitLab) packageURL(tag, name string) string {
+	return fmt.Sprintf("%s/packages/generic/%s/%s/%s", g.rest.base, url.PathEscape(g.pkg), url.PathEscape(tag), url.PathEscape(name))
+}
+
+// UploadAsset uploads a file to the package version of the release's tag
+// and links it from the release
+func (g *GitLab) UploadAsset(ctx context.Context, release *RemoteRelease, name, path string) (*RemoteAsset, error) {
+	if g.verbose {
+		fmt.Printf("Uploading %s to release %s\n", name, release.Tag)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	target := g.packageURL(release.Tag, name)
+	_, err = g.rest.do(ctx, request{
+		Method: http.MethodPut,
+		Path:   target,
+		Body: func() (io.Reader, error) {
+			return os.Open(path)
+		},
+		ContentType: ContentType(name),
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+
+	var link gitlabLink
+	_, err = g.rest.do(ctx, request{
+		Method: http.MethodPost,
+		Path:   fmt.Sprintf("releases/%s/assets/links", url.PathEscape(release.Tag)),
+		Body: jsonBody(map[string]string{
+			"name":      name,
+			"url":       target,
+			"link_type": "package",
+		}),
+	}, &link)
+	if err != nil {
+		return nil, fmt.Errorf("failed to link %s: %w", name, err)
+	}
+
+	asset := link.remote()
+	asset.Size = info.Size()
+	return asset, nil
+}
+
+// DeleteAsset removes the link to an asset from a release. The package file
+// stays behind; a later upload under the same name supersedes it.
+func (g *GitLab) DeleteAsset(ctx context.Context, release *RemoteRelease, asset *RemoteAsset) error {
+	if g.verbose {
+		fmt.Printf("Deleting asset %s\n", asset.Name)
+	}
+	_, err := g.rest.do(ctx, request{
+		Method: http.MethodDelete,
+		Path:   fmt.Sprintf("releases/%s/assets/links/%d", url.PathEscape(release.Tag), asset.ID),
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", asset.Name, err)
+	}
+	return nil
+}
+
+// DownloadAsset opens the contents of an asset
+func (g *GitLab) DownloadAsset(ctx context.Context, release *RemoteRelease, asset *RemoteAsset) (io.ReadCloser, error) {
+	rc, err := g.rest.open(ctx, asset.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", asset.Name, err)
+	}
+	return rc, nil
+}
+
+func (r *gitlabRelease) remote() *RemoteRelease {
+	release := &RemoteRelease{
+		Tag:  r.TagName,
+		Name: r.Name,
+		Body: r.Description,
+		URL:  r.Links.Self,
+	}
+	for _, link := range r.Assets.Links {
+		release.Assets = append(release.Assets, link.remote())
+	}
+	return release
+}
+
+func (l *gitlabLink) remote() *RemoteAsset {
+	return &RemoteAsset{
+		ID:       l.ID,
+		Name:     l.Name,
+		Size:     -1,
+		Uploaded: true,
+		URL:      l.URL,
+	}
+}
@@ -0,0 +1,298 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGitLab is an in-memory stand-in for the release, release link and
+// generic package APIs of a GitLab project. Listings are paged with
+// X-Next-Page, pageSize items at a time.
+type fakeGitLab struct {
+	mu       sync.Mutex
+	releases []*gitlabRelease
+	packages map[string][]*gitlabPackageFile // Files by package version
+	uploads  map[string]string               // Content-Type of each uploaded file
+	created  []map[string]string             // Bodies of release creations
+	released map[string]string               // released_at of each release, by tag
+	pageSize int
+	nextID   int64
+	srv      *httptest.Server
+}
+
+func newFakeGitLab(t *testing.T) *fakeGitLab {
+	t.Helper()
+	f := &fakeGitLab{
+		packages: make(map[string][]*gitlabPackageFile),
+		uploads:  make(map[string]string),
+		released: make(map[string]string),
+		pageSize: 100,
+		nextID:   1,
+	}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeGitLab) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/api/v4/projects/g%2Fp/")
+	parts := strings.Split(path, "/")
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodGet && path == "releases":
+		releases := f.releases
+		if r.URL.Query().Get("order_by") == "released_at" && r.URL.Query().Get("sort") == "desc" {
+			releases = append([]*gitlabRelease(nil), f.releases...)
+			sort.SliceStable(releases, func(i, j int) bool {
+				return f.released[releases[i].TagName] > f.released[releases[j].TagName]
+			})
+		}
+		f.page(w, r, len(releases), func(i int) interface{} { return releases[i] })
+	case r.Method == http.MethodPost && path == "releases":
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		f.created = append(f.created, body)
+		release := &gitlabRelease{TagName: body["tag_name"], Name: body["name"], Description: body["description"]}
+		// Without released_at, a release counts as released when created
+		f.released[release.TagName] = body["released_at"]
+		if f.released[release.TagName] == "" {
+			f.released[release.TagName] = time.Now().UTC().Format(time.RFC3339)
+		}
+		f.releases = append(f.releases, release)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(release)
+	case len(parts) == 2 && parts[0] == "releases":
+		release := f.find(parts[1])
+		if release == nil {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"message":"404 Not Found"}`)
+			return
+		}
+		if r.Method == http.MethodPut {
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			release.Description = body["description"]
+		}
+		json.NewEncoder(w).Encode(release)
+	case len(parts) == 4 && parts[0] == "releases" && parts[2] == "assets":
+		release := f.find(parts[1])
+		switch r.Method {
+		case http.MethodGet:
+			f.page(w, r, len(release.Assets.Links), func(i int) interface{} { return release.Assets.Links[i] })
+		case http.MethodPost:
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			link := &gitlabLink{ID: f.nextID, Name: body["name"], URL: body["url"]}
+			f.nextID++
+			release.Assets.Links = append(release.Assets.Links, link)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(link)
+		}
+	case r.Method == http.MethodDelete && len(parts) == 5 && parts[0] == "releases":
+		release := f.find(parts[1])
+		id, _ := strconv.ParseInt(parts[4], 10, 64)
+		for i, link := range release.Assets.Links {
+			if link.ID == id {
+				release.Assets.Links = append(release.Assets.Links[:i], release.Assets.Links[i+1:]...)
+				json.NewEncoder(w).Encode(link)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodPut && len(parts) == 5 && parts[0] == "packages" && parts[1] == "generic":
+		data, _ := io.ReadAll(r.Body)
+		version, name := parts[3], parts[4]
+		f.packages[version] = append(f.packages[version], &gitlabPackageFile{ID: f.nextID, FileName: name, Size: int64(len(data))})
+		f.nextID++
+		f.uploads[name] = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"message":"201 Created"}`)
+	case r.Method == http.MethodGet && path == "packages":
+		if _, ok := f.packages[r.URL.Query().Get("package_version")]; !ok {
+			io.WriteString(w, `[]`)
+			return
+		}
+		// The package ID is not needed, so the version stands in for it
+		fmt.Fprintf(w, `[{"id":%d}]`, len(f.packages))
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "packages":
+		var files []*gitlabPackageFile
+		for _, version := range f.packages {
+			files = append(files, version...)
+		}
+		f.page(w, r, len(files), func(i int) interface{} { return files[i] })
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"message":"unhandled %s %s"}`, r.Method, r.URL.Path)
+	}
+}
+
+// page writes one page of a listing and points X-Next-Page at the next
+func (f *fakeGitLab) page(w http.ResponseWriter, r *http.Request, n int, item func(int) interface{}) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	start, end := (page-1)*f.pageSize, page*f.pageSize
+	if end >= n {
+		end = n
+	} else {
+		w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+	}
+	items := []interface{}{}
+	for i := start; i < end; i++ {
+		items = append(items, item(i))
+	}
+	json.NewEncoder(w).Encode(items)
+}
+
+func (f *fakeGitLab) find(tag string) *gitlabRelease {
+	for _, release := range f.releases {
+		if release.TagName == tag {
+			return release
+		}
+	}
+	return nil
+}
+
+func newTestGitLab(t *testing.T, f *fakeGitLab) *GitLab {
+	t.Helper()
+	g, err := NewGitLab(f.srv.URL, "g/p", "secret", "", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestGitLabCreateUploadDelete(t *testing.T) {
+	f := newFakeGitLab(t)
+	g := newTestGitLab(t, f)
+	ctx := context.Background()
+
+	release, err := g.CreateRelease(ctx, Release{Tag: "v0.2.1", Name: "Qoder 0.2.1", Body: "notes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.created[0]["ref"] != "main" {
+		t.Errorf("tag created from %q, want main", f.created[0]["ref"])
+	}
+
+	path := filepath.Join(t.TempDir(), "file.deb")
+	if err := os.WriteFile(path, []byte("package"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	asset, err := g.UploadAsset(ctx, release, "qoder-0.2.1-linux-x64.deb", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := g.packageURL("v0.2.1", "qoder-0.2.1-linux-x64.deb"); asset.URL != want {
+		t.Errorf("asset URL = %q, want %q", asset.URL, want)
+	}
+	if got := f.uploads["qoder-0.2.1-linux-x64.deb"]; got != "application/vnd.debian.binary-package" {
+		t.Errorf("uploaded as %q", got)
+	}
+
+	assets, err := g.Assets(ctx, release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assets) != 1 || assets[0].Size != int64(len("package")) {
+		t.Fatalf("assets = %+v", assets)
+	}
+
+	if err := g.DeleteAsset(ctx, release, assets[0]); err != nil {
+		t.Fatal(err)
+	}
+	if assets, err = g.Assets(ctx, release); err != nil || len(assets) != 0 {
+		t.Errorf("assets after delete = %v, %v", assets, err)
+	}
+
+	if missing, err := g.ReleaseByTag(ctx, "v9.9.9"); err != nil || missing != nil {
+		t.Errorf("ReleaseByTag(v9.9.9) = %+v, %v; want nil, nil", missing, err)
+	}
+}
+
+func TestGitLabFollowsNextPage(t *testing.T) {
+	f := newFakeGitLab(t)
+	f.pageSize = 2
+	release := &gitlabRelease{TagName: "v0.2.1"}
+	for i := 0; i < 5; i++ {
+		f.releases = append(f.releases, &gitlabRelease{TagName: fmt.Sprintf("v0.1.%d", i)})
+		release.Assets.Links = append(release.Assets.Links, &gitlabLink{ID: int64(i + 1), Name: fmt.Sprintf("file%d", i), URL: "https://example.com/elsewhere"})
+	}
+	f.releases = append(f.releases, release)
+	g := newTestGitLab(t, f)
+
+	releases, err := g.Releases(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 6 {
+		t.Errorf("listed %d releases, want 6", len(releases))
+	}
+
+	assets, err := g.Assets(context.Background(), &RemoteRelease{Tag: "v0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assets) != 5 {
+		t.Errorf("listed %d assets, want 5", len(assets))
+	}
+	for _, asset := range assets {
+		if asset.Size != -1 {
+			t.Errorf("%s links elsewhere but has size %d", asset.Name, asset.Size)
+		}
+	}
+}
+
+func TestGitLabDatesReleases(t *testing.T) {
+	f := newFakeGitLab(t)
+	g := newTestGitLab(t, f)
+	ctx := context.Background()
+
+	if latest, err := g.Latest(ctx); err != nil || latest != nil {
+		t.Fatalf("Latest without releases = %+v, %v", latest, err)
+	}
+
+	// A version backfilled after a newer one keeps its own date, so the
+	// newer one stays latest
+	if _, err := g.CreateRelease(ctx, Release{Tag: "v0.2.1", Date: time.Date(2025, 9, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*3600))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.CreateRelease(ctx, Release{Tag: "v0.1.9", Date: time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.created[0]["released_at"]; got != "2025-09-01T10:00:00Z" {
+		t.Errorf("released_at = %q, want 2025-09-01T10:00:00Z", got)
+	}
+	latest, err := g.Latest(ctx)
+	if err != nil || latest == nil || latest.Tag != "v0.2.1" {
+		t.Errorf("Latest = %+v, %v; want v0.2.1", latest, err)
+	}
+
+	// Without a date GitLab uses the time of creation
+	if _, err := g.CreateRelease(ctx, Release{Tag: "v0.2.2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.created[2]["released_at"]; ok {
+		t.Errorf("released_at sent without a date: %q", f.created[2]["released_at"])
+	}
+}
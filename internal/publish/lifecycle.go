@@ -0,0 +1,63 @@
+package publish
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/detector"
+)
+
+// The notice at the top of the notes of a retired version is delimited so
+// that it can be found and taken out again
+const (
+	retiredStart = "<!-- qoder-downloader:retired -->"
+	retiredEnd   = "<!-- /qoder-downloader:retired -->"
+)
+
+var retiredNotice = regexp.MustCompile(`(?s)^` + regexp.QuoteMeta(retiredStart) + `\n?(.*?)\n?` + regexp.QuoteMeta(retiredEnd) + `\n*`)
+
+// Retired reports whether release notes carry a retirement notice
+func Retired(body string) bool {
+	return retiredNotice.MatchString(body)
+}
+
+// RetiredNotice returns the retirement notice of release notes, or "" if
+// there is none
+func RetiredNotice(body string) string {
+	if m := retiredNotice.FindStringSubmatch(body); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// WithRetiredNotice returns release notes starting with notice, replacing
+// any earlier retirement notice
+func WithRetiredNotice(body, notice string) string {
+	return fmt.Sprintf("%s\n%s\n%s\n\n%s", retiredStart, strings.TrimSpace(notice), retiredEnd, WithoutRetiredNotice(body))
+}
+
+// WithoutRetiredNotice returns release notes with the retirement notice removed
+func WithoutRetiredNotice(body string) string {
+	return retiredNotice.ReplaceAllString(body, "")
+}
+
+// LatestCandidate returns the release that should be shown as latest: the one
+// of the highest version that is published, not a prerelease and not
+// retired. It returns nil when no release qualifies.
+func LatestCandidate(releases []*RemoteRelease, tags TagScheme) *RemoteRelease {
+	var best *RemoteRelease
+	var bestVersion detector.Version
+	for version := range tags.ByVersion(releases) {
+		v, err := detector.ParseVersion(version)
+		if err != nil || v.Prerelease() || (best != nil && v.Compare(bestVersion) <= 0) {
+			continue
+		}
+		release := tags.Find(releases, version)
+		if release == nil || release.Draft || release.Prerelease || Retired(release.Body) {
+			continue
+		}
+		best, bestVersion = release, v
+	}
+	return best
+}
@@ -0,0 +1,139 @@
+package publish
+
+import (
+	"context"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ReleasePublisher is a forge that releases are published to. GitHub,
+// Gitea/Forgejo and GitLab implement it; Reconcile builds on it.
+type ReleasePublisher interface {
+	// Forge returns the kind of forge, such as "github"
+	Forge() string
+	// Repo returns the repository or project releases go to
+	Repo() string
+	// ReleaseURL returns the web page of the release for a tag
+	ReleaseURL(tag string) string
+	// MaxAssetSize returns the size of the largest file accepted as an asset
+	MaxAssetSize() int64
+
+	// Releases returns every release of the repository
+	Releases(ctx context.Context) ([]*RemoteRelease, error)
+	// ReleaseByTag returns the release for a tag, or nil if there is none
+	ReleaseByTag(ctx context.Context, tag string) (*RemoteRelease, error)
+	// CreateRelease creates a release, and its tag if needed
+	CreateRelease(ctx context.Context, r Release) (*RemoteRelease, error)
+	// UpdateNotes replaces the title and body of a release
+	UpdateNotes(ctx context.Context, release *RemoteRelease, name, body string) (*RemoteRelease, error)
+	// Publish makes a draft release public and applies the prerelease flag of
+	// want. Forges without drafts or prereleases apply what they support.
+	Publish(ctx context.Context, release *RemoteRelease, want Release) (*RemoteRelease, error)
+
+	// Assets returns the assets of a release
+	Assets(ctx context.Context, release *RemoteRelease) ([]*RemoteAsset, error)
+	// UploadAsset uploads a file to a release under the given asset name
+	UploadAsset(ctx context.Context, release *RemoteRelease, name, path string) (*RemoteAsset, error)
+	// DeleteAsset removes an asset from a release
+	DeleteAsset(ctx context.Context, release *RemoteRelease, asset *RemoteAsset) error
+	// DownloadAsset opens the contents of an asset
+	DownloadAsset(ctx context.Context, release *RemoteRelease, asset *RemoteAsset) (io.ReadCloser, error)
+}
+
+// Default per-file size limits of the forges. Self-hosted instances may be
+// configured differently, so targets can override them.
+const (
+	GitHubMaxAssetSize = 2<<30 - 1 // Assets must be smaller than 2 GiB
+	GiteaMaxAssetSize  = 2 << 30   // [attachment] MAX_SIZE defaults to 2048 MB
+	GitLabMaxAssetSize = 5 << 30   // Generic package files default to 5 GiB
+)
+
+// Retagger is implemented by publishers that can move a release to another tag
+type Retagger interface {
+	// Retag moves a release to a new tag, deleting the old tag unless keepOld is set
+	Retag(ctx context.Context, release *RemoteRelease, tag string, keepOld bool) error
+}
+
+// LatestReporter is implemented by publishers whose forge reports which
+// release it shows as "latest"
+type LatestReporter interface {
+	// Latest returns the release shown as latest, or nil if there is none
+	Latest(ctx context.Context) (*RemoteRelease, error)
+}
+
+// LatestMarker is implemented by publishers whose forge lets the release
+// shown as latest be chosen, rather than deriving it from release dates
+type LatestMarker interface {
+	LatestReporter
+	// MarkLatest shows a published release as latest
+	MarkLatest(ctx context.Context, release *RemoteRelease) error
+}
+
+// Release describes a release to create
+type Release struct {
+	Tag        string
+	Aliases    []string // Other tags an existing release may have been created under
+	Name       string
+	Body       string
+	Draft      bool // Create the release hidden until Publish is called
+	Prerelease bool
+	Date       time.Time // When the version came out, for forges that order releases by date; zero for now
+}
+
+// RemoteRelease is a release as a forge reports it
+type RemoteRelease struct {
+	ID     int64
+	Tag    string
+	Name   string
+	Body   string
+	URL    string         // Web page of the release
+	Assets []*RemoteAsset // As included in listings; may be incomplete
+
+	Draft      bool
+	Prerelease bool
+}
+
+// RemoteAsset is a file attached to a release
+type RemoteAsset struct {
+	ID       int64
+	Name     string
+	Size     int64 // -1 when the forge does not report it
+	Uploaded bool  // False for an upload that did not finish
+	URL      string
+	SHA256   string // As computed by the forge; empty when it does not report one
+}
+
+// Content types of the files this tool publishes, by suffix. Checked in
+// order so that ".tar.gz" wins over ".gz".
+var contentTypes = []struct {
+	suffix      string
+	contentType string
+}{
+	{".dmg", "application/x-apple-diskimage"},
+	{".exe", "application/vnd.microsoft.portable-executable"},
+	{".msi", "application/x-msi"},
+	{".AppImage", "application/vnd.appimage"},
+	{".deb", "application/vnd.debian.binary-package"},
+	{".rpm", "application/x-rpm"},
+	{".tar.gz", "application/gzip"},
+	{".zip", "application/zip"},
+	{".md5", "text/plain; charset=utf-8"},
+	{".asc", "application/pgp-signature"},
+	{".json", "application/json"},
+}
+
+// ContentType returns the media type an asset is uploaded with
+func ContentType(name string) string {
+	for _, ct := range contentTypes {
+		if strings.HasSuffix(name, ct.suffix) {
+			return ct.contentType
+		}
+	}
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,282 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/chunks"
+)
+
+// Placeholder is the content older releases uploaded in place of an MD5
+// checksum that could not be downloaded
+const Placeholder = "PLACEHOLDER_MD5_VALUE"
+
+// Asset is a local file that should be attached to a release
+type Asset struct {
+	Name   string
+	Path   string
+	Size   int64  // Computed from the file when zero
+	SHA256 string // Computed from the file when empty
+}
+
+// Action is one change made, or planned, by Reconcile
+type Action struct {
+	Op     string // "create", "upload", "replace", "delete" or "keep"
+	Name   string // Asset name, or the tag for "create"
+	Reason string
+	ID     int64 // ID of the asset after the action, when it was carried out
+}
+
+func (a Action) String() string {
+	if a.Reason == "" {
+		return a.Op + " " + a.Name
+	}
+	return fmt.Sprintf("%s %s (%s)", a.Op, a.Name, a.Reason)
+}
+
+// The checksums of uploaded assets are kept in a comment at the end of the
+// release notes, since the API does not report them
+var checksumMarker = regexp.MustCompile(`(?s)\n*<!-- qoder-downloader:assets (\{.*?\}) -->\s*$`)
+
+// Checksums returns the asset checksums recorded in release notes
+func Checksums(body string) map[string]string {
+	sums := make(map[string]string)
+	if m := checksumMarker.FindStringSubmatch(body); m != nil {
+		_ = json.Unmarshal([]byte(m[1]), &sums)
+	}
+	return sums
+}
+
+// WithChecksums returns release notes with the recorded checksums replaced
+func WithChecksums(body string, sums map[string]string) string {
+	body = checksumMarker.ReplaceAllString(body, "")
+	if len(sums) == 0 {
+		return body
+	}
+	data, _ := json.Marshal(sums) // Map keys are sorted, so the notes only change with the sums
+	return fmt.Sprintf("%s\n\n<!-- qoder-downloader:assets %s -->\n", strings.TrimRight(body, "\n"), data)
+}
+
+// isPlaceholder reports whether an asset holds the placeholder MD5. Only
+// assets of the right size are downloaded to check.
+func isPlaceholder(ctx context.Context, p ReleasePublisher, release *RemoteRelease, asset *RemoteAsset) (bool, error) {
+	if !strings.HasSuffix(asset.Name, ".md5") || asset.Size > int64(len(Placeholder))+2 {
+		return false, nil
+	}
+	rc, err := p.DownloadAsset(ctx, release, asset)
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, 1024))
+	if err != nil {
+		return false, err
+	}
+	return string(bytes.TrimSpace(data)) == Placeholder, nil
+}
+
+// Reconcile makes a release match the desired state: the release is created
+// if it does not exist, assets that are missing or whose size or checksum
+// differs are uploaded, and placeholder MD5 assets are deleted. Assets not
+// in the list are otherwise left alone. Running it again after a failure
+// picks up where it stopped. With dryRun set, nothing is changed and the
+// actions that would be taken are returned. The release is returned as well,
+// unless it does not exist.
+func Reconcile(ctx context.Context, p ReleasePublisher, want Release, assets []Asset, dryRun bool) (*RemoteRelease, []Action, error) {
+	for i := range assets {
+		if err := assets[i].fill(); err != nil {
+			return nil, nil, err
+		}
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].Name < assets[j].Name
+	})
+
+	var actions []Action
+	var err error
+	var release *RemoteRelease
+	for _, tag := range append([]string{want.Tag}, want.Aliases...) {
+		if release, err = p.ReleaseByTag(ctx, tag); err != nil {
+			return nil, nil, err
+		}
+		if release != nil {
+			break
+		}
+	}
+	if release == nil {
+		actions = append(actions, Action{Op: "create", Name: want.Tag})
+		if dryRun {
+			for _, a := range assets {
+				actions = append(actions, Action{Op: "upload", Name: a.Name, Reason: "missing"})
+			}
+			return nil, actions, nil
+		}
+		if release, err = p.CreateRelease(ctx, want); err != nil {
+			return nil, actions, err
+		}
+	}
+
+	existing, err := p.Assets(ctx, release)
+	if err != nil {
+		return release, actions, err
+	}
+	byName := make(map[string]*RemoteAsset, len(existing))
+	for _, a := range existing {
+		byName[a.Name] = a
+	}
+
+	sums := Checksums(release.Body)
+	desired := make(map[string]bool, len(assets))
+
+	for _, a := range assets {
+		desired[a.Name] = true
+		remote := byName[a.Name]
+
+		var reason string
+		switch {
+		case remote == nil:
+			reason = "missing"
+		case !remote.Uploaded:
+			reason = "incomplete upload"
+		case remote.Size >= 0 && remote.Size != a.Size:
+			reason = fmt.Sprintf("size %d, want %d", remote.Size, a.Size)
+		case sums[a.Name] != "" && sums[a.Name] != a.SHA256:
+			reason = "checksum differs"
+		case remote.Size < 0 && sums[a.Name] == "":
+			// Nothing to compare with, so the upload may not have finished
+			reason = "unverified"
+		}
+
+		if reason == "" {
+			sums[a.Name] = a.SHA256
+			actions = append(actions, Action{Op: "keep", Name: a.Name, ID: remote.ID})
+			continue
+		}
+
+		op := "upload"
+		if remote != nil {
+			op = "replace"
+		}
+		actions = append(actions, Action{Op: op, Name: a.Name, Reason: reason})
+		if dryRun {
+			continue
+		}
+		if remote != nil {
+			// Asset names are unique within a release
+			if err := p.DeleteAsset(ctx, release, remote); err != nil {
+				return release, actions, err
+			}
+			delete(sums, a.Name)
+		}
+		uploaded, err := p.UploadAsset(ctx, release, a.Name, a.Path)
+		if err != nil {
+			return release, actions, saveChecksums(ctx, p, release, sums, err)
+		}
+		actions[len(actions)-1].ID = uploaded.ID
+		sums[a.Name] = a.SHA256
+	}
+
+	for _, remote := range existing {
+		if desired[remote.Name] {
+			continue
+		}
+		placeholder, err := isPlaceholder(ctx, p, release, remote)
+		if err != nil {
+			return release, actions, saveChecksums(ctx, p, release, sums, err)
+		}
+		if !placeholder {
+			continue
+		}
+		actions = append(actions, Action{Op: "delete", Name: remote.Name, Reason: "placeholder"})
+		if dryRun {
+			continue
+		}
+		if err := p.DeleteAsset(ctx, release, remote); err != nil {
+			return release, actions, saveChecksums(ctx, p, release, sums, err)
+		}
+		delete(sums, remote.Name)
+	}
+
+	if dryRun {
+		return release, actions, nil
+	}
+	return release, actions, saveChecksums(ctx, p, release, sums, nil)
+}
+
+// saveChecksums records the checksums in the release notes if they changed,
+// and in release, returning cause, or the failure to save when there is no
+// cause
+func saveChecksums(ctx context.Context, p ReleasePublisher, release *RemoteRelease, sums map[string]string, cause error) error {
+	body := WithChecksums(release.Body, sums)
+	if body == release.Body {
+		return cause
+	}
+	_, err := p.UpdateNotes(ctx, release, release.Name, body)
+	if err == nil {
+		release.Body = body
+	}
+	if cause != nil {
+		return cause
+	}
+	return err
+}
+
+// fill computes the size and checksum of an asset that does not carry them
+func (a *Asset) fill() error {
+	if a.Size != 0 && a.SHA256 != "" {
+		return nil
+	}
+	f, err := os.Open(a.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", a.Path, err)
+	}
+	a.Size = n
+	a.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// Pending returns the names that a listed release does not yet hold as
+// complete uploads. It needs no further requests, so it can be used to decide
+// which files are worth fetching before calling Reconcile.
+func Pending(release *RemoteRelease, names []string) []string {
+	uploaded := make(map[string]bool, len(release.Assets))
+	for _, a := range release.Assets {
+		uploaded[a.Name] = a.Uploaded
+	}
+
+	var pending []string
+	for _, name := range names {
+		// A file too large for the forge is published as parts, the manifest last
+		if !uploaded[name] && !uploaded[chunks.ManifestName(name)] {
+			pending = append(pending, name)
+		}
+	}
+	return pending
+}
+
+// MayHavePlaceholders reports whether a listed release has MD5 assets small
+// enough to be placeholders
+func MayHavePlaceholders(release *RemoteRelease) bool {
+	for _, a := range release.Assets {
+		if strings.HasSuffix(a.Name, ".md5") && a.Size >= 0 && a.Size <= int64(len(Placeholder))+2 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// restClient makes JSON requests to the REST APIs of Gitea and GitLab
+type restClient struct {
+	http  *http.Client
+	base  string // API base URL, without a trailing slash
+	auth  func(*http.Request)
+	forge string // Named in messages
+}
+
+// apiError is a response with an error status
+type apiError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Message    string
+}
+
+func (e *apiError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s: HTTP %d", e.Method, e.URL, e.StatusCode)
+	}
+	return fmt.Sprintf("%s %s: HTTP %d: %s", e.Method, e.URL, e.StatusCode, e.Message)
+}
+
+// isNotFound reports whether err is a 404 response
+func isNotFound(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// request describes one API call. Body is called again for every attempt,
+// since a failed attempt may have consumed the previous reader.
+type request struct {
+	Method      string
+	Path        string // Relative to the API base, or an absolute URL
+	Query       url.Values
+	Body        func() (io.Reader, error)
+	ContentType string
+}
+
+// jsonBody returns a request body that encodes v
+func jsonBody(v interface{}) func() (io.Reader, error) {
+	return func() (io.Reader, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(string(data)), nil
+	}
+}
+
+// do performs a request and decodes a JSON response into out, which may be
+// nil. Responses with status 429, or 403 with Retry-After, are retried after
+// waiting as long as the server asks.
+func (c *restClient) do(ctx context.Context, r request, out interface{}) (*http.Response, error) {
+	target := r.Path
+	if !strings.Contains(target, "://") {
+		target = c.base + "/" + strings.TrimPrefix(target, "/")
+	}
+	if len(r.Query) > 0 {
+		target += "?" + r.Query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, r, target)
+		if err != nil {
+			return nil, err
+		}
+
+		wait, limited := retryAfter(resp)
+		if limited && attempt < maxRateLimitRetries && wait <= maxRateLimitWait {
+			resp.Body.Close()
+			fmt.Printf("%s rate limit reached, waiting %s\n", c.forge, wait.Round(time.Second))
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+				continue
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			return resp, &apiError{Method: r.Method, URL: target, StatusCode: resp.StatusCode, Message: errorMessage(data)}
+		}
+		if out != nil && resp.StatusCode != http.StatusNoContent {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return resp, fmt.Errorf("%s %s: invalid response: %w", r.Method, target, err)
+			}
+		}
+		return resp, nil
+	}
+}
+
+func (c *restClient) send(ctx context.Context, r request, target string) (*http.Response, error) {
+	var body io.Reader
+	if r.Body != nil {
+		var err error
+		if body, err = r.Body(); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if r.ContentType != "" {
+		req.Header.Set("Content-Type", r.ContentType)
+	} else if r.Body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.auth != nil {
+		c.auth(req)
+	}
+	return c.http.Do(req)
+}
+
+// open performs a GET request and returns the response body as is
+func (c *restClient) open(ctx context.Context, target string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, request{Method: http.MethodGet}, target)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &apiError{Method: http.MethodGet, URL: target, StatusCode: resp.StatusCode}
+	}
+	return resp.Body, nil
+}
+
+// retryAfter returns how long a rate limited response asks to wait
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	header := resp.Header.Get("Retry-After")
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+	case resp.StatusCode == http.StatusForbidden && header != "":
+	default:
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return time.Until(t), true
+	}
+	return defaultAbuseWait, true
+}
+
+// errorMessage extracts the message of a JSON error response
+func errorMessage(data []byte) string {
+	var body struct {
+		Message interface{} `json:"message"`
+		Error   string      `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		if body.Message != nil {
+			return fmt.Sprint(body.Message)
+		}
+		if body.Error != "" {
+			return body.Error
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
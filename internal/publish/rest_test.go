@@ -0,0 +1,81 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRestClientRetriesRateLimits(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		status    int
+		header    string
+		requests  int
+		wantError bool
+	}{
+		{"429 with Retry-After", http.StatusTooManyRequests, "0", 2, false},
+		{"403 with Retry-After", http.StatusForbidden, "0", 2, false},
+		{"403 without Retry-After", http.StatusForbidden, "", 1, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests == 1 {
+					if tc.header != "" {
+						w.Header().Set("Retry-After", tc.header)
+					}
+					w.WriteHeader(tc.status)
+					io.WriteString(w, `{"message":"slow down"}`)
+					return
+				}
+				io.WriteString(w, `{"ok":true}`)
+			}))
+			defer srv.Close()
+
+			c := restClient{http: srv.Client(), base: srv.URL, forge: "test"}
+			var out struct{ OK bool }
+			_, err := c.do(context.Background(), request{Method: http.MethodGet, Path: "thing"}, &out)
+			if requests != tc.requests {
+				t.Errorf("%d requests, want %d", requests, tc.requests)
+			}
+			if tc.wantError {
+				var apiErr *apiError
+				if err == nil || !errors.As(err, &apiErr) || apiErr.Message != "slow down" {
+					t.Errorf("err = %v, want an API error with the server's message", err)
+				}
+				return
+			}
+			if err != nil || !out.OK {
+				t.Errorf("do = %+v, %v", out, err)
+			}
+		})
+	}
+}
+
+func TestRestClientResendsBody(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		if len(bodies) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := restClient{http: srv.Client(), base: srv.URL, forge: "test"}
+	if _, err := c.do(context.Background(), request{Method: http.MethodPost, Path: "thing", Body: jsonBody(map[string]string{"a": "b"})}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 || bodies[0] != `{"a":"b"}` || bodies[1] != bodies[0] {
+		t.Errorf("bodies = %q", bodies)
+	}
+}
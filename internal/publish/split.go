@@ -0,0 +1,62 @@
+package publish
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/chunks"
+)
+
+// PartSize returns the size of the parts a file is split into for a forge
+// that accepts assets of up to limit bytes. Parts are whole MiB when the
+// limit allows, which keeps them readable in listings.
+func PartSize(limit int64) int64 {
+	if limit >= 1<<20 {
+		return limit / (1 << 20) * (1 << 20)
+	}
+	return limit
+}
+
+// PartCount returns how many parts a file of the given size is split into,
+// or 0 when it fits within limit as it is
+func PartCount(size, limit int64) int {
+	if limit <= 0 || size <= limit {
+		return 0
+	}
+	partSize := PartSize(limit)
+	return int((size + partSize - 1) / partSize)
+}
+
+// SplitOversized replaces every asset larger than limit with numbered parts
+// and a parts manifest, written to dir. Assets within the limit are returned
+// unchanged. The second result maps the name of each parts manifest to the
+// asset it stands for.
+func SplitOversized(assets []Asset, limit int64, dir string) ([]Asset, map[string]string, error) {
+	var out []Asset
+	split := make(map[string]string)
+	for _, a := range assets {
+		if err := a.fill(); err != nil {
+			return nil, nil, err
+		}
+		if PartCount(a.Size, limit) == 0 {
+			out = append(out, a)
+			continue
+		}
+
+		m, manifestPath, err := chunks.Split(a.Path, a.Name, a.SHA256, PartSize(limit), dir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to split %s: %w", a.Name, err)
+		}
+		for _, part := range m.Parts {
+			out = append(out, Asset{
+				Name:   part.Name,
+				Path:   filepath.Join(dir, part.Name),
+				Size:   part.Size,
+				SHA256: part.SHA256,
+			})
+		}
+		out = append(out, Asset{Name: chunks.ManifestName(a.Name), Path: manifestPath})
+		split[chunks.ManifestName(a.Name)] = a.Name
+	}
+	return out, split, nil
+}
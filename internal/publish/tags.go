@@ -0,0 +1,162 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// DefaultTagFormat is the tag template used when none is configured
+const DefaultTagFormat = "v{version}"
+
+// Tag formats earlier releases were created with
+var historicalTagFormats = []string{"v{version}", "{version}"}
+
+// TagScheme turns versions into release tags and back
+type TagScheme struct {
+	Format string // Template containing {version}
+}
+
+// NewTagScheme checks a tag template; an empty one selects DefaultTagFormat
+func NewTagScheme(format string) (TagScheme, error) {
+	if format == "" {
+		format = DefaultTagFormat
+	}
+	if strings.Count(format, "{version}") != 1 {
+		return TagScheme{}, fmt.Errorf("tag format %q must contain {version} exactly once", format)
+	}
+	return TagScheme{Format: format}, nil
+}
+
+// Tag returns the canonical tag of a version
+func (s TagScheme) Tag(version string) string {
+	return strings.Replace(s.Format, "{version}", version, 1)
+}
+
+// Variants returns every tag a version may have been released under, the
+// canonical one first
+func (s TagScheme) Variants(version string) []string {
+	tags := []string{s.Tag(version)}
+	for _, format := range historicalTagFormats {
+		tag := strings.Replace(format, "{version}", version, 1)
+		if tag != tags[0] {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ParseTag returns the version a tag refers to, accepting the canonical
+// format and the historical ones
+func (s TagScheme) ParseTag(tag string) (string, bool) {
+	for _, format := range append([]string{s.Format}, historicalTagFormats...) {
+		if m := tagPattern(format).FindStringSubmatch(tag); m != nil {
+			return m[1], true
+		}
+	}
+	return "", false
+}
+
+// IsCanonical reports whether a tag is in the configured format
+func (s TagScheme) IsCanonical(tag string) bool {
+	version, ok := s.ParseTag(tag)
+	return ok && s.Tag(version) == tag
+}
+
+// tagPattern matches the tags of a format, prerelease versions such as
+// 0.3.0-beta.1 included
+func tagPattern(format string) *regexp.Regexp {
+	parts := strings.SplitN(format, "{version}", 2)
+	return regexp.MustCompile("^" + regexp.QuoteMeta(parts[0]) + `(\d+(?:\.\d+)*(?:-[0-9A-Za-z.-]+)?)` + regexp.QuoteMeta(parts[1]) + "$")
+}
+
+// ByVersion groups releases by the version their tag refers to. Releases
+// whose tag is not a version are left out.
+func (s TagScheme) ByVersion(releases []*RemoteRelease) map[string][]*RemoteRelease {
+	byVersion := make(map[string][]*RemoteRelease)
+	for _, release := range releases {
+		if version, ok := s.ParseTag(release.Tag); ok {
+			byVersion[version] = append(byVersion[version], release)
+		}
+	}
+	return byVersion
+}
+
+// Find returns the release of a version among listed releases, preferring
+// the canonical tag, or nil if there is none
+func (s TagScheme) Find(releases []*RemoteRelease, version string) *RemoteRelease {
+	for _, tag := range s.Variants(version) {
+		for _, release := range releases {
+			if release.Tag == tag {
+				return release
+			}
+		}
+	}
+	return nil
+}
+
+// Retag moves a release to a new tag. The new tag is created on the commit
+// of the old one, and the old tag is deleted unless keepOld is set.
+func (g *GitHub) Retag(ctx context.Context, release *RemoteRelease, tag string, keepOld bool) error {
+	old := release.Tag
+
+	var ref *github.Reference
+	var resp *github.Response
+	err := g.withRetry(ctx, func() (err error) {
+		ref, resp, err = g.client.Git.GetRef(ctx, g.owner, g.repo, "tags/"+old)
+		return err
+	})
+	switch {
+	case resp != nil && resp.StatusCode == http.StatusNotFound:
+		// Draft releases have no tag yet; the release alone is renamed
+		ref = nil
+	case err != nil:
+		return fmt.Errorf("failed to look up tag %s: %w", old, err)
+	}
+
+	if ref != nil {
+		err := g.withRetry(ctx, func() error {
+			_, _, err := g.client.Git.CreateRef(ctx, g.owner, g.repo, &github.Reference{
+				Ref:    github.String("refs/tags/" + tag),
+				Object: &github.GitObject{SHA: ref.Object.SHA},
+			})
+			return err
+		})
+		if err != nil && !g.tagPointsTo(ctx, tag, ref.Object.GetSHA()) {
+			return fmt.Errorf("failed to create tag %s: %w", tag, err)
+		}
+	}
+
+	err = g.withRetry(ctx, func() error {
+		_, _, err := g.client.Repositories.EditRelease(ctx, g.owner, g.repo, release.ID, &github.RepositoryRelease{
+			TagName: github.String(tag),
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move release %s to %s: %w", old, tag, err)
+	}
+
+	if ref == nil || keepOld {
+		return nil
+	}
+	err = g.withRetry(ctx, func() error {
+		_, err := g.client.Git.DeleteRef(ctx, g.owner, g.repo, "tags/"+old)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete tag %s: %w", old, err)
+	}
+	return nil
+}
+
+// tagPointsTo reports whether a tag exists and refers to the given object,
+// as it does when an interrupted migration is run again
+func (g *GitHub) tagPointsTo(ctx context.Context, tag, sha string) bool {
+	ref, _, err := g.client.Git.GetRef(ctx, g.owner, g.repo, "tags/"+tag)
+	return err == nil && ref.Object.GetSHA() == sha
+}
@@ -0,0 +1,92 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/retention"
+)
+
+// Target configures one forge that releases are published to
+type Target struct {
+	Name      string `mapstructure:"name"`       // Selects the target on the command line; defaults to Type
+	Type      string `mapstructure:"type"`       // github, gitea, forgejo or gitlab
+	URL       string `mapstructure:"url"`        // Web base URL of a Gitea, Forgejo or GitLab instance
+	APIURL    string `mapstructure:"api_url"`    // GitHub REST API base; empty for github.com
+	UploadURL string `mapstructure:"upload_url"` // GitHub asset upload base
+	Repo      string `mapstructure:"repo"`       // owner/repo, or the GitLab project path
+	Token     string `mapstructure:"token"`
+	TokenEnv  string `mapstructure:"token_env"` // Environment variable holding the token when Token is empty
+	Ref       string `mapstructure:"ref"`       // Branch new tags are created from
+	Package   string `mapstructure:"package"`   // GitLab generic package name
+	// Largest asset the forge accepts, such as "2GB"; larger files are split
+	// into parts. Defaults to the forge's usual limit.
+	MaxAssetSize string `mapstructure:"max_asset_size"`
+}
+
+// Label returns the name the target is selected and reported by
+func (t Target) Label() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.Type
+}
+
+// ResolveToken fills in the token from TokenEnv when none is configured
+func (t *Target) ResolveToken() {
+	if t.Token == "" && t.TokenEnv != "" {
+		t.Token = os.Getenv(t.TokenEnv)
+	}
+}
+
+// New creates the publisher for a target. cacheDir is where GitHub API
+// responses are cached; empty disables caching.
+func New(ctx context.Context, t Target, cacheDir string, verbose bool) (ReleasePublisher, error) {
+	t.ResolveToken()
+
+	var maxAssetSize int64
+	if t.MaxAssetSize != "" {
+		size, err := retention.ParseSize(t.MaxAssetSize)
+		if err != nil {
+			return nil, fmt.Errorf("target %s: max_asset_size: %w", t.Label(), err)
+		}
+		if size <= 0 {
+			return nil, fmt.Errorf("target %s: max_asset_size must be positive", t.Label())
+		}
+		maxAssetSize = size
+	}
+
+	switch t.Type {
+	case "github", "":
+		return NewGitHub(ctx, Options{
+			Token:        t.Token,
+			Repo:         t.Repo,
+			APIURL:       t.APIURL,
+			UploadURL:    t.UploadURL,
+			CacheDir:     cacheDir,
+			MaxAssetSize: maxAssetSize,
+			Verbose:      verbose,
+		})
+	case "gitea", "forgejo":
+		if t.URL == "" {
+			return nil, fmt.Errorf("target %s: url is required", t.Label())
+		}
+		g, err := NewGitea(t.Type, t.URL, t.Repo, t.Token, t.Ref, verbose)
+		if err == nil && maxAssetSize > 0 {
+			g.maxAssetSize = maxAssetSize
+		}
+		return g, err
+	case "gitlab":
+		if t.URL == "" {
+			t.URL = "https://gitlab.com"
+		}
+		g, err := NewGitLab(t.URL, t.Repo, t.Token, t.Ref, t.Package, verbose)
+		if err == nil && maxAssetSize > 0 {
+			g.maxAssetSize = maxAssetSize
+		}
+		return g, err
+	default:
+		return nil, fmt.Errorf("target %s: unknown type %q, expected github, gitea, forgejo or gitlab", t.Label(), t.Type)
+	}
+}
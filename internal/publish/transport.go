@@ -0,0 +1,177 @@
+package publish
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// Limits on waiting out rate limits before giving up
+const (
+	maxRateLimitRetries = 5
+	maxRateLimitWait    = time.Hour
+	defaultAbuseWait    = time.Minute
+)
+
+// withRetry runs an API call, sleeping and trying again when GitHub reports
+// that the primary rate limit is exhausted (until the limit resets) or that a
+// secondary limit was hit (for as long as Retry-After asks)
+func (g *GitHub) withRetry(ctx context.Context, call func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := call()
+		wait, limited := rateLimitWait(err)
+		if !limited || attempt >= maxRateLimitRetries {
+			return err
+		}
+		if wait > maxRateLimitWait {
+			return fmt.Errorf("rate limit resets in %s, not waiting: %w", wait.Round(time.Second), err)
+		}
+
+		fmt.Printf("GitHub rate limit reached, waiting %s\n", wait.Round(time.Second))
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// rateLimitWait returns how long to wait before retrying after err
+func rateLimitWait(err error) (time.Duration, bool) {
+	var rateErr *github.RateLimitError
+	if errors.As(err, &rateErr) {
+		// A second of slack for clock skew
+		wait := time.Until(rateErr.Rate.Reset.Time) + time.Second
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		if abuseErr.RetryAfter != nil {
+			return *abuseErr.RetryAfter, true
+		}
+		return defaultAbuseWait, true
+	}
+	return 0, false
+}
+
+// etagTransport caches JSON GET responses on disk and revalidates them with
+// If-None-Match, so that unchanged listings cost no rate limit
+type etagTransport struct {
+	dir  string
+	base http.RoundTripper
+}
+
+func (t *etagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	path := filepath.Join(t.dir, cacheKey(req)+".http")
+	cached := readCachedResponse(path, req)
+	if cached != nil {
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("If-None-Match", etag)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		resp.Body.Close()
+		// Rate limit headers come from the live response
+		for key, values := range resp.Header {
+			if strings.HasPrefix(key, "X-Ratelimit-") {
+				cached.Header[key] = values
+			}
+		}
+		return cached, nil
+	}
+	if cached != nil {
+		cached.Body.Close()
+	}
+
+	if resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "" &&
+		strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		saveCachedResponse(path, resp)
+	}
+	return resp, nil
+}
+
+// cacheKey identifies a request, including who made it, since responses
+// differ between tokens
+func cacheKey(req *http.Request) string {
+	h := sha256.New()
+	io.WriteString(h, req.URL.String())
+	io.WriteString(h, "\n"+req.Header.Get("Accept"))
+	io.WriteString(h, "\n"+req.Header.Get("Authorization"))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func readCachedResponse(path string, req *http.Request) *http.Response {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		return nil
+	}
+	return resp
+}
+
+// saveCachedResponse stores a response, replacing its body with the buffered
+// copy. Failing to cache is not an error.
+func saveCachedResponse(path string, resp *http.Response) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err}))
+		return
+	}
+
+	stored := *resp
+	stored.Body = io.NopCloser(bytes.NewReader(body))
+	stored.ContentLength = int64(len(body))
+	stored.TransferEncoding = nil
+	data, err := httputil.DumpResponse(&stored, true)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return
+	}
+	os.Rename(tmp, path)
+}
+
+// errReader hands a read error back to the caller of a buffered body
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
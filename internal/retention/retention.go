@@ -0,0 +1,233 @@
+package retention
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/detector"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+)
+
+// Policy describes which downloads to keep. An entry is kept when any of the
+// keep rules retains it; with no keep rules set every entry is retained. The
+// size cap is applied afterwards by evicting the oldest versions first.
+type Policy struct {
+	KeepLatest   int       // Keep the newest N versions per platform
+	KeepSince    time.Time // Keep every version first seen on or after this date
+	KeepPerMinor bool      // Keep the newest build of every minor line
+	MaxSize      int64     // Total size cap in bytes, 0 for no cap
+	Pinned       []string  // Versions that are never pruned
+
+	// FirstSeen looks up when a version was first seen upstream, usually
+	// cache.Manager.FirstSeen. KeepSince falls back to the download date of
+	// an entry when it is nil or has no record of the version.
+	FirstSeen func(version string) (time.Time, bool)
+}
+
+// HasKeepRules reports whether any keep rule is configured
+func (p Policy) HasKeepRules() bool {
+	return p.KeepLatest > 0 || !p.KeepSince.IsZero() || p.KeepPerMinor
+}
+
+// IsEmpty reports whether the policy would never remove anything
+func (p Policy) IsEmpty() bool {
+	return !p.HasKeepRules() && p.MaxSize <= 0
+}
+
+// Decision records why an entry is removed
+type Decision struct {
+	Entry  *manifest.Entry
+	Reason string
+}
+
+// Plan is the outcome of applying a policy to a manifest
+type Plan struct {
+	Keep        []*manifest.Entry
+	Remove      []Decision
+	KeptSize    int64
+	RemovedSize int64
+}
+
+type candidate struct {
+	entry   *manifest.Entry
+	version detector.Version
+}
+
+// Apply computes which manifest entries a policy removes. Nothing is touched on disk.
+func Apply(m *manifest.Manifest, policy Policy) (*Plan, error) {
+	pinned := make(map[string]bool, len(policy.Pinned))
+	for _, v := range policy.Pinned {
+		pinned[strings.TrimPrefix(v, "v")] = true
+	}
+
+	var candidates []candidate
+	for _, entry := range m.Entries {
+		v, err := detector.ParseVersion(entry.Version)
+		if err != nil {
+			return nil, fmt.Errorf("manifest entry %s: %w", entry.Path, err)
+		}
+		candidates = append(candidates, candidate{entry: entry, version: v})
+	}
+
+	// Newest first, so the "keep N" rules can take a prefix
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].version.Compare(candidates[j].version) > 0
+	})
+
+	kept := make(map[*manifest.Entry]bool)
+	if !policy.HasKeepRules() {
+		for _, c := range candidates {
+			kept[c.entry] = true
+		}
+	}
+
+	if policy.KeepLatest > 0 {
+		// Each artifact of a platform is counted separately
+		perPlatform := make(map[string]int)
+		for _, c := range candidates {
+			key := c.entry.Platform + "/" + c.entry.Artifact
+			if perPlatform[key] < policy.KeepLatest {
+				kept[c.entry] = true
+				perPlatform[key]++
+			}
+		}
+	}
+
+	if !policy.KeepSince.IsZero() {
+		for _, c := range candidates {
+			seen := c.entry.DownloadedAt
+			if policy.FirstSeen != nil {
+				if t, ok := policy.FirstSeen(c.entry.Version); ok {
+					seen = t
+				}
+			}
+			if !seen.Before(policy.KeepSince) {
+				kept[c.entry] = true
+			}
+		}
+	}
+
+	if policy.KeepPerMinor {
+		newest := make(map[string]string)
+		for _, c := range candidates {
+			key := fmt.Sprintf("%s/%s/%d.%d", c.entry.Platform, c.entry.Artifact, c.version.Major, c.version.Minor)
+			if _, ok := newest[key]; !ok {
+				newest[key] = c.entry.Version
+			}
+			if newest[key] == c.entry.Version {
+				kept[c.entry] = true
+			}
+		}
+	}
+
+	plan := &Plan{}
+	reasons := make(map[*manifest.Entry]string)
+	for _, c := range candidates {
+		if pinned[c.entry.Version] {
+			kept[c.entry] = true
+		}
+		if kept[c.entry] {
+			plan.KeptSize += c.entry.Size
+		} else {
+			reasons[c.entry] = "not retained by any keep rule"
+		}
+	}
+
+	// Evict the oldest unpinned versions until the cap is met
+	if policy.MaxSize > 0 {
+		for i := len(candidates) - 1; i >= 0 && plan.KeptSize > policy.MaxSize; i-- {
+			c := candidates[i]
+			if !kept[c.entry] || pinned[c.entry.Version] {
+				continue
+			}
+			kept[c.entry] = false
+			plan.KeptSize -= c.entry.Size
+			reasons[c.entry] = fmt.Sprintf("evicted to stay under %s", FormatSize(policy.MaxSize))
+		}
+	}
+
+	for _, c := range candidates {
+		if kept[c.entry] {
+			plan.Keep = append(plan.Keep, c.entry)
+		} else {
+			plan.Remove = append(plan.Remove, Decision{Entry: c.entry, Reason: reasons[c.entry]})
+			plan.RemovedSize += c.entry.Size
+		}
+	}
+
+	return plan, nil
+}
+
+// Execute deletes the files a plan removes and drops them from the manifest.
+// The manifest is saved even when a file cannot be removed, so that it keeps
+// matching the files that are still on disk.
+func Execute(m *manifest.Manifest, plan *Plan, verbose bool) error {
+	for _, decision := range plan.Remove {
+		path := m.AbsPath(decision.Entry)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			if saveErr := m.Save(); saveErr != nil {
+				return fmt.Errorf("failed to remove %s: %w (and to save the manifest: %v)", path, err, saveErr)
+			}
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		if verbose {
+			fmt.Printf("Removed %s\n", path)
+		}
+		m.Remove(decision.Entry)
+
+		// Drop the version directory once it is empty
+		os.Remove(filepath.Dir(path))
+	}
+
+	return m.Save()
+}
+
+// ParseSize parses sizes like "500MB", "20G" or "1073741824"
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(strings.ToUpper(s))
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+
+	units := []struct {
+		suffix string
+		factor int64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+		{"B", 1},
+	}
+
+	factor := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(s, unit.suffix) {
+			factor = unit.factor
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	return int64(value * float64(factor)), nil
+}
+
+// FormatSize renders a byte count for humans
+func FormatSize(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.2f GB", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.2f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.2f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
@@ -0,0 +1,178 @@
+package retention
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// testManifest records one darwin file per version, each downloaded on the given day
+func testManifest(t *testing.T, downloads map[string]string) *manifest.Manifest {
+	t.Helper()
+	m, err := manifest.Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for version, downloaded := range downloads {
+		m.Put(&manifest.Entry{
+			Version:      version,
+			Platform:     "darwin-arm64",
+			Path:         version + "/Qoder-darwin-arm64.dmg",
+			Size:         100,
+			DownloadedAt: day(downloaded),
+		})
+	}
+	return m
+}
+
+func versions(entries []*manifest.Entry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.Version)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestApply(t *testing.T) {
+	downloads := map[string]string{
+		"0.1.9":  "2025-06-01",
+		"0.1.10": "2025-06-01",
+		"0.2.0":  "2025-06-01",
+		"0.2.1":  "2025-09-01",
+		"0.2.2":  "2025-09-01",
+	}
+	firstSeen := map[string]time.Time{
+		"0.1.9":  day("2025-01-10"),
+		"0.1.10": day("2025-02-10"),
+		"0.2.0":  day("2025-05-01"),
+	}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		keep   []string
+	}{
+		{
+			name:   "no keep rules",
+			policy: Policy{},
+			keep:   []string{"0.1.10", "0.1.9", "0.2.0", "0.2.1", "0.2.2"},
+		},
+		{
+			name:   "keep latest",
+			policy: Policy{KeepLatest: 2},
+			keep:   []string{"0.2.1", "0.2.2"},
+		},
+		{
+			name:   "keep latest compares versions numerically",
+			policy: Policy{KeepLatest: 4},
+			keep:   []string{"0.1.10", "0.2.0", "0.2.1", "0.2.2"},
+		},
+		{
+			name:   "keep since download date",
+			policy: Policy{KeepSince: day("2025-09-01")},
+			keep:   []string{"0.2.1", "0.2.2"},
+		},
+		{
+			name: "keep since first seen",
+			policy: Policy{
+				KeepSince: day("2025-02-01"),
+				FirstSeen: func(v string) (time.Time, bool) {
+					t, ok := firstSeen[v]
+					return t, ok
+				},
+			},
+			// 0.2.1 and 0.2.2 have no record and fall back to their download date
+			keep: []string{"0.1.10", "0.2.0", "0.2.1", "0.2.2"},
+		},
+		{
+			name:   "keep per minor",
+			policy: Policy{KeepPerMinor: true},
+			keep:   []string{"0.1.10", "0.2.2"},
+		},
+		{
+			name:   "pinned",
+			policy: Policy{KeepLatest: 1, Pinned: []string{"v0.1.9"}},
+			keep:   []string{"0.1.9", "0.2.2"},
+		},
+		{
+			name:   "size cap evicts the oldest",
+			policy: Policy{MaxSize: 300},
+			keep:   []string{"0.2.0", "0.2.1", "0.2.2"},
+		},
+		{
+			name:   "size cap spares pinned versions",
+			policy: Policy{MaxSize: 200, Pinned: []string{"0.1.9"}},
+			keep:   []string{"0.1.9", "0.2.2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := Apply(testManifest(t, downloads), tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := versions(plan.Keep); !reflect.DeepEqual(got, tt.keep) {
+				t.Errorf("kept %v, want %v", got, tt.keep)
+			}
+			if plan.KeptSize != int64(100*len(tt.keep)) {
+				t.Errorf("kept size %d, want %d", plan.KeptSize, 100*len(tt.keep))
+			}
+			if len(plan.Keep)+len(plan.Remove) != len(downloads) {
+				t.Errorf("%d kept and %d removed of %d entries", len(plan.Keep), len(plan.Remove), len(downloads))
+			}
+		})
+	}
+}
+
+func TestExecuteSavesManifestWhenRemovalFails(t *testing.T) {
+	m := testManifest(t, map[string]string{"0.1.9": "2025-06-01", "0.2.0": "2025-06-01"})
+	for _, e := range m.Entries {
+		path := m.AbsPath(e)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Newest versions go first; a non-empty directory in place of the
+	// older file cannot be removed
+	blocked := m.AbsPath(m.Find("0.1.9", "darwin-arm64", ""))
+	if err := os.Remove(blocked); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(blocked, "inside"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := Apply(m, Policy{MaxSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Execute(m, plan, false); err == nil {
+		t.Fatal("Execute succeeded although a file could not be removed")
+	}
+
+	saved, err := manifest.Load(m.Dir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(saved.Entries); !reflect.DeepEqual(got, []string{"0.1.9"}) {
+		t.Errorf("saved manifest lists %v, want only the file left on disk", got)
+	}
+}
@@ -0,0 +1,90 @@
+package signing
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// Signer makes detached OpenPGP signatures with a private key
+type Signer struct {
+	entity *openpgp.Entity
+}
+
+// LoadSigner reads an armored private key, decrypting it with passphrase
+// when it is protected. The first key in the file is used.
+func LoadSigner(path string, passphrase []byte) (*Signer, error) {
+	keys, err := readKeyRing(path)
+	if err != nil {
+		return nil, err
+	}
+	entity := keys[0]
+	if entity.PrivateKey == nil {
+		return nil, fmt.Errorf("%s holds no private key", path)
+	}
+
+	if entity.PrivateKey.Encrypted {
+		if len(passphrase) == 0 {
+			return nil, fmt.Errorf("%s is protected by a passphrase", path)
+		}
+		if err := entity.PrivateKey.Decrypt(passphrase); err != nil {
+			return nil, fmt.Errorf("failed to unlock %s: %w", path, err)
+		}
+	}
+	// Signatures may be made by a signing subkey
+	for _, subkey := range entity.Subkeys {
+		if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+			if err := subkey.PrivateKey.Decrypt(passphrase); err != nil {
+				return nil, fmt.Errorf("failed to unlock %s: %w", path, err)
+			}
+		}
+	}
+	return &Signer{entity: entity}, nil
+}
+
+// KeyID returns the ID of the key, in hex
+func (s *Signer) KeyID() string {
+	return s.entity.PrimaryKey.KeyIdString()
+}
+
+// Sign returns an armored detached signature of data
+func (s *Signer) Sign(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&buf, s.entity, bytes.NewReader(data), nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Verify checks an armored detached signature of data against the public
+// keys in an armored key file, returning the ID of the key that made it
+func Verify(data, signature []byte, publicKeyPath string) (string, error) {
+	keys, err := readKeyRing(publicKeyPath)
+	if err != nil {
+		return "", err
+	}
+	signer, err := openpgp.CheckArmoredDetachedSignature(keys, bytes.NewReader(data), bytes.NewReader(signature), nil)
+	if err != nil {
+		return "", fmt.Errorf("bad signature: %w", err)
+	}
+	return signer.PrimaryKey.KeyIdString(), nil
+}
+
+func readKeyRing(path string) (openpgp.EntityList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	keys, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s: %w", path, err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys in %s", path)
+	}
+	return keys, nil
+}
@@ -0,0 +1,179 @@
+package signing
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+func TestSumsRoundTrip(t *testing.T) {
+	sums := map[string]string{
+		"Qoder-linux-x64.AppImage":   strings.Repeat("a", 64),
+		"Qoder-darwin-arm64.dmg":     strings.Repeat("b", 64),
+		"QoderUserSetup-x64.exe.001": strings.Repeat("c", 64),
+	}
+	data := FormatSums(sums)
+
+	want := strings.Repeat("b", 64) + "  Qoder-darwin-arm64.dmg\n" +
+		strings.Repeat("a", 64) + "  Qoder-linux-x64.AppImage\n" +
+		strings.Repeat("c", 64) + "  QoderUserSetup-x64.exe.001\n"
+	if string(data) != want {
+		t.Errorf("FormatSums =\n%s\nwant\n%s", data, want)
+	}
+
+	parsed, err := ParseSums(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, sums) {
+		t.Errorf("ParseSums(FormatSums(sums)) = %v", parsed)
+	}
+}
+
+func TestParseSums(t *testing.T) {
+	sum := strings.Repeat("0123456789abcdef", 4)
+
+	// Binary mode, upper case digests and blank lines, as written by other tools
+	parsed, err := ParseSums([]byte(sum + " *Qoder.dmg\n\n" + strings.ToUpper(sum) + "  Qoder.zip\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Qoder.dmg": sum, "Qoder.zip": sum}
+	if !reflect.DeepEqual(parsed, want) {
+		t.Errorf("parsed %v, want %v", parsed, want)
+	}
+
+	for _, bad := range []string{
+		"abc  Qoder.dmg\n",
+		sum + "\n",
+		sum + "  *\n",
+	} {
+		if _, err := ParseSums([]byte(bad)); err == nil {
+			t.Errorf("parsed %q", bad)
+		}
+	}
+}
+
+// writeKey generates a key pair and writes the armored private and public
+// keys to dir. A non-empty passphrase protects the private key.
+func writeKey(t *testing.T, dir, name string, passphrase []byte) (*openpgp.Entity, string, string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var public bytes.Buffer
+	w, err := armor.Encode(&public, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	if len(passphrase) > 0 {
+		if err := entity.PrivateKey.Encrypt(passphrase); err != nil {
+			t.Fatal(err)
+		}
+		for _, subkey := range entity.Subkeys {
+			if err := subkey.PrivateKey.Encrypt(passphrase); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var private bytes.Buffer
+	w, err = armor.Encode(&private, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.SerializePrivateWithoutSigning(w, nil); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	privatePath := filepath.Join(dir, name+".key")
+	publicPath := filepath.Join(dir, name+".pub")
+	if err := os.WriteFile(privatePath, private.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicPath, public.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return entity, privatePath, publicPath
+}
+
+func TestSignVerify(t *testing.T) {
+	dir := t.TempDir()
+	entity, privatePath, publicPath := writeKey(t, dir, "release", nil)
+	_, _, otherPublic := writeKey(t, dir, "other", nil)
+
+	signer, err := LoadSigner(privatePath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.KeyID() != entity.PrimaryKey.KeyIdString() {
+		t.Errorf("KeyID = %s, want %s", signer.KeyID(), entity.PrimaryKey.KeyIdString())
+	}
+
+	sums := FormatSums(map[string]string{"Qoder.dmg": strings.Repeat("a", 64)})
+	signature, err := signer.Sign(sums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(signature, []byte("-----BEGIN PGP SIGNATURE-----")) {
+		t.Errorf("signature is not armored:\n%s", signature)
+	}
+
+	keyID, err := Verify(sums, signature, publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyID != signer.KeyID() {
+		t.Errorf("verified by %s, want %s", keyID, signer.KeyID())
+	}
+
+	tampered := append([]byte(nil), sums...)
+	tampered[0] = 'b'
+	if _, err := Verify(tampered, signature, publicPath); err == nil {
+		t.Error("verified a signature of other data")
+	}
+	if _, err := Verify(sums, signature, otherPublic); err == nil {
+		t.Error("verified a signature against another key")
+	}
+	if _, err := LoadSigner(publicPath, nil); err == nil {
+		t.Error("loaded a signer from a public key")
+	}
+}
+
+func TestLoadSignerWithPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	_, privatePath, publicPath := writeKey(t, dir, "release", []byte("secret"))
+
+	if _, err := LoadSigner(privatePath, nil); err == nil {
+		t.Error("loaded a protected key without its passphrase")
+	}
+	if _, err := LoadSigner(privatePath, []byte("wrong")); err == nil {
+		t.Error("loaded a protected key with the wrong passphrase")
+	}
+
+	signer, err := LoadSigner(privatePath, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := signer.Sign([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify([]byte("data"), signature, publicPath); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,62 @@
+// Package signing produces and checks the SHA256SUMS file published with
+// every release, and its detached OpenPGP signature.
+package signing
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Names of the checksum file and its signature as release assets
+const (
+	SumsName      = "SHA256SUMS"
+	SignatureName = SumsName + ".asc"
+)
+
+// IsSumsAsset reports whether an asset is the checksum file or its signature,
+// which the checksum file does not list
+func IsSumsAsset(name string) bool {
+	return name == SumsName || name == SignatureName
+}
+
+// FormatSums renders checksums by file name in the format of sha256sum,
+// sorted by name
+func FormatSums(sums map[string]string) []byte {
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s  %s\n", sums[name], name)
+	}
+	return buf.Bytes()
+}
+
+// ParseSums reads a file in the format of sha256sum. Names marked as binary
+// ("*name") are accepted as well.
+func ParseSums(data []byte) (map[string]string, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 2)
+		if len(fields) != 2 || len(fields[0]) != 64 {
+			return nil, fmt.Errorf("line %d: expected '<sha256>  <name>'", line)
+		}
+		name := strings.TrimPrefix(strings.TrimLeft(fields[1], " "), "*")
+		if name == "" {
+			return nil, fmt.Errorf("line %d: missing file name", line)
+		}
+		sums[name] = strings.ToLower(fields[0])
+	}
+	return sums, scanner.Err()
+}
@@ -0,0 +1,71 @@
+// Package sitedata describes the version data file the website is built
+// from, and the JSON Schema it follows.
+package sitedata
+
+import (
+	_ "embed"
+	"time"
+)
+
+// SchemaVersion is raised whenever a change would break existing readers.
+// Fields may be added without raising it.
+const SchemaVersion = 1
+
+// Default names of the data file and its schema, written side by side
+const (
+	FileName       = "versions.json"
+	SchemaFileName = "versions.schema.json"
+)
+
+// Schema is the JSON Schema of the data file
+//
+//go:embed schema.json
+var Schema []byte
+
+// Data is the content of the data file
+type Data struct {
+	Schema        string     `json:"$schema,omitempty"` // Reference to the schema file
+	SchemaVersion int        `json:"schema_version"`
+	GeneratedAt   time.Time  `json:"generated_at"`
+	Repo          string     `json:"repo,omitempty"`   // GitHub repository release links point to
+	Latest        string     `json:"latest,omitempty"` // Highest version that is not a prerelease
+	Versions      []*Version `json:"versions"`         // Newest first
+}
+
+// Version is one upstream version
+type Version struct {
+	Version     string      `json:"version"`
+	ReleaseDate *time.Time  `json:"release_date,omitempty"` // When the version was first seen upstream
+	Prerelease  bool        `json:"prerelease,omitempty"`
+	Release     *Release    `json:"release,omitempty"` // Nil when the version has not been published
+	Platforms   []*Platform `json:"platforms"`
+}
+
+// Release links to the GitHub release of a version
+type Release struct {
+	Tag string `json:"tag"`
+	URL string `json:"url"`
+}
+
+// Platform groups the files of one platform
+type Platform struct {
+	Name      string      `json:"name"`
+	OS        string      `json:"os"`
+	Arch      string      `json:"arch"`
+	Artifacts []*Artifact `json:"artifacts"` // The primary artifact first
+}
+
+// Artifact is one file of a platform. Sizes and checksums are only known for
+// archived files.
+type Artifact struct {
+	Name        string `json:"name"` // Selector name, as accepted by download --artifact
+	Primary     bool   `json:"primary,omitempty"`
+	Extension   string `json:"extension"`
+	FileName    string `json:"file_name"` // Release asset name
+	Size        int64  `json:"size,omitempty"`
+	MD5         string `json:"md5,omitempty"`
+	SHA256      string `json:"sha256,omitempty"`
+	UpstreamURL string `json:"upstream_url"`
+	DownloadURL string `json:"download_url,omitempty"` // Release asset; the parts manifest when Split
+	Split       bool   `json:"split,omitempty"`        // Attached in parts that must be joined
+}
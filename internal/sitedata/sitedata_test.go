@@ -0,0 +1,152 @@
+package sitedata
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type schemaObject struct {
+	Required   []string                   `json:"required"`
+	Properties map[string]json.RawMessage `json:"properties"`
+}
+
+type schemaDocument struct {
+	schemaObject
+	Defs map[string]schemaObject `json:"$defs"`
+}
+
+func loadSchema(t *testing.T) schemaDocument {
+	t.Helper()
+	var doc schemaDocument
+	if err := json.Unmarshal(Schema, &doc); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	return doc
+}
+
+// jsonFields returns the JSON names of a struct's fields, and those that are
+// always written
+func jsonFields(typ reflect.Type) (fields, required []string) {
+	for i := 0; i < typ.NumField(); i++ {
+		name, options, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		fields = append(fields, name)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	return fields, required
+}
+
+func TestSchemaMatchesTypes(t *testing.T) {
+	doc := loadSchema(t)
+
+	for _, tc := range []struct {
+		def    string
+		object schemaObject
+		typ    reflect.Type
+	}{
+		{"(root)", doc.schemaObject, reflect.TypeOf(Data{})},
+		{"version", doc.Defs["version"], reflect.TypeOf(Version{})},
+		{"release", doc.Defs["release"], reflect.TypeOf(Release{})},
+		{"platform", doc.Defs["platform"], reflect.TypeOf(Platform{})},
+		{"artifact", doc.Defs["artifact"], reflect.TypeOf(Artifact{})},
+	} {
+		fields, required := jsonFields(tc.typ)
+
+		var properties []string
+		for name := range tc.object.Properties {
+			properties = append(properties, name)
+		}
+		sort.Strings(fields)
+		sort.Strings(properties)
+		if !reflect.DeepEqual(fields, properties) {
+			t.Errorf("%s: schema properties %v, %s fields %v", tc.def, properties, tc.typ.Name(), fields)
+		}
+
+		wantRequired := append([]string(nil), tc.object.Required...)
+		sort.Strings(required)
+		sort.Strings(wantRequired)
+		if !reflect.DeepEqual(required, wantRequired) {
+			t.Errorf("%s: schema requires %v, %s always writes %v", tc.def, wantRequired, tc.typ.Name(), required)
+		}
+	}
+
+	var version struct {
+		Const int `json:"const"`
+	}
+	if err := json.Unmarshal(doc.Properties["schema_version"], &version); err != nil || version.Const != SchemaVersion {
+		t.Errorf("schema_version in the schema is %d, SchemaVersion is %d", version.Const, SchemaVersion)
+	}
+}
+
+// pattern returns the regular expression of a string property
+func pattern(t *testing.T, object schemaObject, property string) *regexp.Regexp {
+	t.Helper()
+	var p struct {
+		Pattern string `json:"pattern"`
+	}
+	if err := json.Unmarshal(object.Properties[property], &p); err != nil || p.Pattern == "" {
+		t.Fatalf("%s has no pattern", property)
+	}
+	return regexp.MustCompile(p.Pattern)
+}
+
+func TestSchemaPatterns(t *testing.T) {
+	doc := loadSchema(t)
+
+	versionPattern := pattern(t, doc.Defs["version"], "version")
+	for _, v := range []string{"0.2.1", "0.10.0", "1.0.0-beta.1"} {
+		if !versionPattern.MatchString(v) {
+			t.Errorf("version %s does not match the schema", v)
+		}
+	}
+	if versionPattern.MatchString("v0.2.1") {
+		t.Error("versions are written without a v prefix")
+	}
+
+	repoPattern := pattern(t, doc.schemaObject, "repo")
+	if !repoPattern.MatchString("vibe-coding-labs/qoder-downloader") || repoPattern.MatchString("qoder-downloader") {
+		t.Error("repo pattern does not require owner/repo")
+	}
+
+	if !pattern(t, doc.Defs["artifact"], "md5").MatchString("d41d8cd98f00b204e9800998ecf8427e") {
+		t.Error("md5 pattern rejects a lower-case digest")
+	}
+	if !pattern(t, doc.Defs["artifact"], "sha256").MatchString("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855") {
+		t.Error("sha256 pattern rejects a lower-case digest")
+	}
+}
+
+func TestEncodeOmitsUnknownValues(t *testing.T) {
+	data := Data{
+		SchemaVersion: SchemaVersion,
+		GeneratedAt:   time.Date(2025, 9, 12, 8, 30, 0, 0, time.UTC),
+		Versions: []*Version{{
+			Version: "0.2.1",
+			Platforms: []*Platform{{
+				Name: "linux-x64", OS: "linux", Arch: "amd64",
+				Artifacts: []*Artifact{{
+					Name: "AppImage", Primary: true, Extension: "AppImage",
+					FileName:    "qoder-0.2.1-linux-x64.AppImage",
+					UpstreamURL: "https://download.qoder.com/release/0.2.1/Qoder-linux-x64.AppImage",
+				}},
+			}},
+		}},
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"schema_version":1,"generated_at":"2025-09-12T08:30:00Z","versions":[{"version":"0.2.1","platforms":[` +
+		`{"name":"linux-x64","os":"linux","arch":"amd64","artifacts":[{"name":"AppImage","primary":true,"extension":"AppImage",` +
+		`"file_name":"qoder-0.2.1-linux-x64.AppImage","upstream_url":"https://download.qoder.com/release/0.2.1/Qoder-linux-x64.AppImage"}]}]}]}`
+	if string(encoded) != want {
+		t.Errorf("encoded\n%s\nwant\n%s", encoded, want)
+	}
+}
@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/detector"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/publish"
+)
+
+// Names of the listings kept next to the artifacts
+const (
+	IndexName    = "index.json"
+	VersionsName = "versions.json"
+)
+
+// Index lists every artifact in the mirror
+type Index struct {
+	UpdatedAt time.Time     `json:"updated_at"`
+	Files     []*IndexEntry `json:"files"`
+}
+
+// IndexEntry describes one artifact in the mirror
+type IndexEntry struct {
+	Version  string `json:"version"`
+	Platform string `json:"platform"`
+	Artifact string `json:"artifact,omitempty"`
+	Key      string `json:"key"`
+	URL      string `json:"url,omitempty"` // Public URL, when one is configured
+	Upstream string `json:"upstream_url"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+	MD5      string `json:"md5"`
+}
+
+// Versions lists the versions in the mirror, newest first
+type Versions struct {
+	UpdatedAt time.Time `json:"updated_at"`
+	Latest    string    `json:"latest"`
+	Versions  []string  `json:"versions"`
+}
+
+// Action is one change made, or planned, by Sync
+type Action struct {
+	Op     string // "upload", "replace", "keep", "failed", or "update" for a listing
+	Key    string
+	Reason string
+}
+
+func (a Action) String() string {
+	if a.Reason == "" {
+		return a.Op + " " + a.Key
+	}
+	return fmt.Sprintf("%s %s (%s)", a.Op, a.Key, a.Reason)
+}
+
+// Mirror keeps a bucket in line with a downloads directory
+type Mirror struct {
+	store     *S3
+	prefix    string // Prepended to every key, such as "qoder/"
+	publicURL string // URL the root of the bucket is served under, if any
+	layout    *platform.Layout
+	verbose   bool
+}
+
+// NewMirror creates a mirror that stores artifacts under the names the
+// layout gives them, below prefix
+func NewMirror(store *S3, prefix, publicURL string, layout *platform.Layout, verbose bool) *Mirror {
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &Mirror{
+		store:     store,
+		prefix:    prefix,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		layout:    layout,
+		verbose:   verbose,
+	}
+}
+
+// Key returns the object key of an artifact
+func (m *Mirror) Key(version string, p platform.PlatformInfo) string {
+	return m.prefix + m.layout.Format(version, p)
+}
+
+// Sync uploads the given manifest entries that the bucket does not hold with
+// the same size and SHA-256, then brings index.json and versions.json up to
+// date. A failed upload does not stop the others; the listings then leave
+// the file out and an error is returned at the end. With dryRun set nothing
+// is written.
+func (m *Mirror) Sync(ctx context.Context, man *manifest.Manifest, entries []*manifest.Entry, dryRun bool) ([]Action, error) {
+	var actions []Action
+	var synced []*IndexEntry
+	failed := 0
+
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return actions, ctx.Err()
+		}
+		p, err := platform.GetArtifact(entry.Platform, entry.Artifact)
+		if err != nil {
+			actions = append(actions, Action{Op: "failed", Key: entry.Path, Reason: err.Error()})
+			failed++
+			continue
+		}
+		key := m.Key(entry.Version, p)
+
+		action, err := m.syncEntry(ctx, man, entry, key, dryRun)
+		if err != nil {
+			actions = append(actions, Action{Op: "failed", Key: key, Reason: err.Error()})
+			failed++
+			continue
+		}
+		actions = append(actions, action)
+		synced = append(synced, &IndexEntry{
+			Version:  entry.Version,
+			Platform: entry.Platform,
+			Artifact: entry.Artifact,
+			Key:      key,
+			URL:      m.objectPublicURL(key),
+			Upstream: entry.URL,
+			Size:     entry.Size,
+			SHA256:   entry.SHA256,
+			MD5:      entry.MD5,
+		})
+	}
+
+	listings, err := m.updateListings(ctx, synced, dryRun)
+	actions = append(actions, listings...)
+	if err != nil {
+		return actions, err
+	}
+	if failed > 0 {
+		return actions, fmt.Errorf("%d files could not be uploaded", failed)
+	}
+	return actions, nil
+}
+
+// syncEntry uploads one artifact unless the bucket already holds it
+func (m *Mirror) syncEntry(ctx context.Context, man *manifest.Manifest, entry *manifest.Entry, key string, dryRun bool) (Action, error) {
+	obj, err := m.store.Head(ctx, key)
+	if err != nil {
+		return Action{}, err
+	}
+
+	action := Action{Op: "upload", Key: key, Reason: "missing"}
+	if obj != nil {
+		switch {
+		case obj.Size != entry.Size:
+			action = Action{Op: "replace", Key: key, Reason: fmt.Sprintf("size %d, want %d", obj.Size, entry.Size)}
+		case obj.Metadata["sha256"] != entry.SHA256:
+			action = Action{Op: "replace", Key: key, Reason: "checksum differs"}
+		default:
+			return Action{Op: "keep", Key: key}, nil
+		}
+	}
+	if dryRun {
+		return action, nil
+	}
+
+	if m.verbose {
+		fmt.Printf("Uploading %s to %s\n", entry.Path, key)
+	}
+	err = m.store.PutFile(ctx, key, man.AbsPath(entry), publish.ContentType(key), map[string]string{
+		"sha256":   entry.SHA256,
+		"md5":      entry.MD5,
+		"version":  entry.Version,
+		"platform": entry.Platform,
+	})
+	if err != nil {
+		return Action{}, err
+	}
+	return action, nil
+}
+
+func (m *Mirror) objectPublicURL(key string) string {
+	if m.publicURL == "" {
+		return ""
+	}
+	return m.publicURL + "/" + key
+}
+
+// updateListings merges the synced files into index.json and rewrites
+// versions.json from it. Files of versions no longer downloaded locally stay
+// listed, since their objects stay in the bucket. Listings are only written
+// when their contents change.
+func (m *Mirror) updateListings(ctx context.Context, synced []*IndexEntry, dryRun bool) ([]Action, error) {
+	var index Index
+	data, err := m.store.Get(ctx, m.prefix+IndexName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", IndexName, err)
+	}
+	if data != nil {
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", IndexName, err)
+		}
+	}
+
+	byID := make(map[string]*IndexEntry)
+	for _, e := range append(index.Files, synced...) {
+		byID[e.Version+"/"+e.Platform+"/"+e.Artifact] = e
+	}
+	files := make([]*IndexEntry, 0, len(byID))
+	for _, e := range byID {
+		files = append(files, e)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Version != files[j].Version {
+			return detector.CompareVersionStrings(files[i].Version, files[j].Version) > 0
+		}
+		return files[i].Key < files[j].Key
+	})
+
+	now := time.Now().UTC().Truncate(time.Second)
+	var actions []Action
+
+	oldFiles, _ := json.Marshal(index.Files)
+	newFiles, _ := json.Marshal(files)
+	if data == nil || !bytes.Equal(oldFiles, newFiles) {
+		index = Index{UpdatedAt: now, Files: files}
+		action, err := m.writeJSON(ctx, IndexName, index, dryRun)
+		if err != nil {
+			return actions, err
+		}
+		actions = append(actions, action)
+	}
+
+	versions := Versions{UpdatedAt: now}
+	seen := make(map[string]bool)
+	for _, e := range files {
+		if !seen[e.Version] {
+			seen[e.Version] = true
+			versions.Versions = append(versions.Versions, e.Version)
+		}
+	}
+	if len(versions.Versions) > 0 {
+		versions.Latest = versions.Versions[0]
+	}
+
+	var current Versions
+	data, err = m.store.Get(ctx, m.prefix+VersionsName)
+	if err != nil {
+		return actions, fmt.Errorf("failed to read %s: %w", VersionsName, err)
+	}
+	if data != nil {
+		_ = json.Unmarshal(data, &current)
+	}
+	if data == nil || current.Latest != versions.Latest || strings.Join(current.Versions, ",") != strings.Join(versions.Versions, ",") {
+		action, err := m.writeJSON(ctx, VersionsName, versions, dryRun)
+		if err != nil {
+			return actions, err
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+func (m *Mirror) writeJSON(ctx context.Context, name string, v interface{}, dryRun bool) (Action, error) {
+	action := Action{Op: "update", Key: m.prefix + name}
+	if dryRun {
+		return action, nil
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return action, err
+	}
+	if err := m.store.PutBytes(ctx, m.prefix+name, append(data, '\n'), "application/json", nil); err != nil {
+		return action, fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return action, nil
+}
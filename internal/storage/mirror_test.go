@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+)
+
+// testManifest records two downloaded files of version 0.2.1
+func testManifest(t *testing.T) *manifest.Manifest {
+	t.Helper()
+	dir := t.TempDir()
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"darwin-arm64", "linux-x64"} {
+		rel := fmt.Sprintf("0.2.1/file-%s", p)
+		_, data := writeTestFile(t, dir, rel, 100)
+		m.Put(&manifest.Entry{
+			Version:  "0.2.1",
+			Platform: p,
+			Path:     rel,
+			URL:      "https://example.com/" + p,
+			Size:     int64(len(data)),
+			SHA256:   hashHex(data),
+			MD5:      "md5-" + p,
+		})
+	}
+	return m
+}
+
+func actionOps(actions []Action) string {
+	ops := make([]string, len(actions))
+	for i, a := range actions {
+		ops[i] = a.Op + " " + a.Key
+	}
+	return strings.Join(ops, ", ")
+}
+
+func TestMirrorSync(t *testing.T) {
+	f, s := newFakeS3(t)
+	mirror := NewMirror(s, "qoder", "https://cdn.example.com", platform.MustLayout(platform.DefaultLayout), false)
+	m := testManifest(t)
+	ctx := context.Background()
+
+	actions, err := mirror.Sync(ctx, m, m.Entries, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "upload qoder/0.2.1/qoder-0.2.1-darwin-arm64.dmg, upload qoder/0.2.1/qoder-0.2.1-linux-x64.AppImage, update qoder/index.json, update qoder/versions.json"
+	if got := actionOps(actions); got != want {
+		t.Fatalf("first sync: %s\nwant %s\nbucket: %v", got, want, f.sortedKeys())
+	}
+
+	// Nothing changed, so nothing is written
+	actions, err = mirror.Sync(ctx, m, m.Entries, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "keep qoder/0.2.1/qoder-0.2.1-darwin-arm64.dmg, keep qoder/0.2.1/qoder-0.2.1-linux-x64.AppImage"
+	if got := actionOps(actions); got != want {
+		t.Errorf("second sync: %s\nwant %s", got, want)
+	}
+	if puts := f.requests("PUT"); len(puts) != 4 {
+		t.Errorf("%d uploads in total, want 4: %v", len(puts), puts)
+	}
+
+	// An object of the same size but another checksum is replaced
+	f.objects["qoder/0.2.1/qoder-0.2.1-linux-x64.AppImage"].header.Set("X-Amz-Meta-Sha256", "stale")
+	actions, err = mirror.Sync(ctx, m, m.Entries, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actions[1].Op != "replace" || actions[1].Reason != "checksum differs" {
+		t.Errorf("changed checksum: %v", actions[1])
+	}
+
+	// And one of another size too
+	f.objects["qoder/0.2.1/qoder-0.2.1-darwin-arm64.dmg"].data = []byte("short")
+	actions, err = mirror.Sync(ctx, m, m.Entries, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actions[0].Op != "replace" || actions[0].Reason != "size 5, want 100" {
+		t.Errorf("changed size: %v", actions[0])
+	}
+}
+
+func TestMirrorMergesListings(t *testing.T) {
+	f, s := newFakeS3(t)
+	mirror := NewMirror(s, "", "", platform.MustLayout(platform.DefaultLayout), false)
+	m := testManifest(t)
+	ctx := context.Background()
+
+	// A version no longer downloaded locally stays listed
+	old := Index{Files: []*IndexEntry{
+		{Version: "0.1.0", Platform: "darwin-arm64", Key: "0.1.0/qoder-0.1.0-darwin-arm64.dmg", Size: 10, SHA256: "x"},
+		{Version: "0.2.1", Platform: "darwin-arm64", Key: "old-key", Size: 1, SHA256: "y"},
+	}}
+	data, _ := json.Marshal(old)
+	if err := s.PutBytes(ctx, IndexName, data, "application/json", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mirror.Sync(ctx, m, m.Entries, false); err != nil {
+		t.Fatal(err)
+	}
+
+	var index Index
+	if err := json.Unmarshal(f.objects[IndexName].data, &index); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, e := range index.Files {
+		keys = append(keys, e.Key)
+	}
+	want := "0.2.1/qoder-0.2.1-darwin-arm64.dmg, 0.2.1/qoder-0.2.1-linux-x64.AppImage, 0.1.0/qoder-0.1.0-darwin-arm64.dmg"
+	if got := strings.Join(keys, ", "); got != want {
+		t.Errorf("index keys = %s\nwant %s", got, want)
+	}
+
+	var versions Versions
+	if err := json.Unmarshal(f.objects[VersionsName].data, &versions); err != nil {
+		t.Fatal(err)
+	}
+	if versions.Latest != "0.2.1" || strings.Join(versions.Versions, ",") != "0.2.1,0.1.0" {
+		t.Errorf("versions = %+v", versions)
+	}
+}
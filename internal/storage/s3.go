@@ -0,0 +1,376 @@
+// Package storage uploads the mirror to S3-compatible object storage such
+// as AWS S3 or MinIO.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Part sizes for multipart uploads. S3 rejects parts below 5 MiB except the
+// last one, and uploads of more than 10000 parts.
+const (
+	DefaultPartSize = 64 << 20
+	minPartSize     = 5 << 20
+	maxParts        = 10000
+)
+
+// Options configures access to a bucket
+type Options struct {
+	Endpoint  string // Such as https://s3.eu-central-1.amazonaws.com or http://127.0.0.1:9000
+	Region    string // Defaults to us-east-1, which MinIO accepts as well
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool  // Address the bucket in the path rather than the host name, as MinIO needs
+	PartSize  int64 // Files larger than this are uploaded in parts; DefaultPartSize when zero
+	Verbose   bool
+}
+
+// S3 is a client for one bucket
+type S3 struct {
+	client   *http.Client
+	endpoint *url.URL
+	bucket   string
+	style    bool
+	partSize int64
+	signer   signer
+	verbose  bool
+}
+
+// Object describes a stored object
+type Object struct {
+	Key      string
+	Size     int64
+	ETag     string
+	Metadata map[string]string // User metadata, by lower-case name without the x-amz-meta- prefix
+}
+
+// NewS3 creates a client for the bucket in opts
+func NewS3(opts Options) (*S3, error) {
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("no bucket configured")
+	}
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", opts.Endpoint)
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.PartSize == 0 {
+		opts.PartSize = DefaultPartSize
+	}
+	if opts.PartSize < minPartSize {
+		return nil, fmt.Errorf("part size must be at least %d MiB", minPartSize>>20)
+	}
+
+	return &S3{
+		client:   &http.Client{Timeout: 30 * time.Minute},
+		endpoint: endpoint,
+		bucket:   opts.Bucket,
+		style:    opts.PathStyle,
+		partSize: opts.PartSize,
+		signer: signer{
+			accessKey: opts.AccessKey,
+			secretKey: opts.SecretKey,
+			region:    opts.Region,
+			service:   "s3",
+		},
+		verbose: opts.Verbose,
+	}, nil
+}
+
+// Bucket returns the name of the bucket
+func (s *S3) Bucket() string {
+	return s.bucket
+}
+
+// objectURL returns the URL of a key, with the path encoded the way it is signed
+func (s *S3) objectURL(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	base := strings.TrimSuffix(u.Path, "/")
+	if s.style {
+		base += "/" + s.bucket
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+
+	segments := strings.Split(key, "/")
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = uriEncode(segment)
+	}
+	u.Path = base + "/" + key
+	u.RawPath = base + "/" + strings.Join(escaped, "/")
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// do signs and sends a request. body is sent with the given hash; a nil body
+// is sent empty. Responses other than 2xx are turned into errors.
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, header http.Header, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key, query).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = size
+		if size == 0 {
+			// Otherwise sent chunked, which S3 does not accept
+			req.Body = http.NoBody
+		}
+	}
+	if payloadHash == "" {
+		payloadHash = emptyPayloadHash
+	}
+	s.signer.sign(req, payloadHash, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return resp, responseError(method, key, resp)
+	}
+	return resp, nil
+}
+
+// Error is an error response from the storage service
+type Error struct {
+	Method     string
+	Key        string
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("%s %s: HTTP %d", e.Method, e.Key, e.StatusCode)
+	}
+	return fmt.Sprintf("%s %s: %s: %s", e.Method, e.Key, e.Code, e.Message)
+}
+
+func responseError(method, key string, resp *http.Response) error {
+	e := &Error{Method: method, Key: key, StatusCode: resp.StatusCode}
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if xml.Unmarshal(data, &body) == nil {
+		e.Code, e.Message = body.Code, body.Message
+	}
+	return e
+}
+
+// IsNotFound reports whether err says that an object does not exist
+func IsNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && (e.StatusCode == http.StatusNotFound || e.Code == "NoSuchKey")
+}
+
+// Head returns the description of an object, or nil if it does not exist
+func (s *S3) Head(ctx context.Context, key string) (*Object, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil, nil, 0, "")
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	obj := &Object{
+		Key:      key,
+		Size:     resp.ContentLength,
+		ETag:     strings.Trim(resp.Header.Get("ETag"), `"`),
+		Metadata: make(map[string]string),
+	}
+	for name, values := range resp.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-meta-") && len(values) > 0 {
+			obj.Metadata[strings.TrimPrefix(lower, "x-amz-meta-")] = values[0]
+		}
+	}
+	return obj, nil
+}
+
+// Get returns the contents of an object, or nil if it does not exist
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil, nil, 0, "")
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// PutBytes stores a small object
+func (s *S3) PutBytes(ctx context.Context, key string, data []byte, contentType string, metadata map[string]string) error {
+	header := objectHeader(contentType, metadata)
+	resp, err := s.do(ctx, http.MethodPut, key, nil, header, bytes.NewReader(data), int64(len(data)), hashHex(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// PutFile stores a file, in parts when it is larger than the part size
+func (s *S3) PutFile(ctx context.Context, key, path, contentType string, metadata map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := objectHeader(contentType, metadata)
+	if info.Size() <= s.partSize {
+		hash, err := sectionHash(f, 0, info.Size())
+		if err != nil {
+			return err
+		}
+		resp, err := s.do(ctx, http.MethodPut, key, nil, header, io.NewSectionReader(f, 0, info.Size()), info.Size(), hash)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	return s.putMultipart(ctx, key, f, info.Size(), header)
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// putMultipart uploads a file in parts. A failed upload is aborted so that
+// its parts do not linger in the bucket.
+func (s *S3) putMultipart(ctx context.Context, key string, f *os.File, size int64, header http.Header) (err error) {
+	partSize := s.partSize
+	for (size+partSize-1)/partSize > maxParts {
+		partSize *= 2
+	}
+
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, header, nil, 0, "")
+	if err != nil {
+		return fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+	uploadID := initiated.UploadID
+
+	defer func() {
+		if err == nil {
+			return
+		}
+		// The context may be what failed, so the abort gets its own
+		abortCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if resp, abortErr := s.do(abortCtx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil, 0, ""); abortErr == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	var parts []completedPart
+	for number, offset := 1, int64(0); offset < size; number, offset = number+1, offset+partSize {
+		length := partSize
+		if offset+length > size {
+			length = size - offset
+		}
+		hash, err := sectionHash(f, offset, length)
+		if err != nil {
+			return err
+		}
+		if s.verbose {
+			fmt.Printf("Uploading part %d of %s (%d bytes)\n", number, key, length)
+		}
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := s.do(ctx, http.MethodPut, key, query, nil, io.NewSectionReader(f, offset, length), length, hash)
+		if err != nil {
+			return fmt.Errorf("failed to upload part %d: %w", number, err)
+		}
+		resp.Body.Close()
+		parts = append(parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	resp, err = s.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, http.Header{"Content-Type": {"application/xml"}}, bytes.NewReader(body), int64(len(body)), hashHex(body))
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Completion can fail after a 200 status, reported in the body
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var failure struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(data, &failure) == nil && failure.XMLName.Local == "Error" {
+		return fmt.Errorf("failed to complete multipart upload: %s: %s", failure.Code, failure.Message)
+	}
+	return nil
+}
+
+func objectHeader(contentType string, metadata map[string]string) http.Header {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	for name, value := range metadata {
+		header.Set("X-Amz-Meta-"+name, value)
+	}
+	return header
+}
+
+// sectionHash returns the hex SHA-256 of part of a file, which the
+// signature covers
+func sectionHash(f *os.File, offset, length int64) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, offset, length)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
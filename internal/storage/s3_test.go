@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is an in-memory stand-in for the object and multipart APIs of one
+// path-style bucket. It checks that every request carries a signature and
+// that the signed payload hash matches the body.
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string]*fakeObject
+	uploads map[string]map[int][]byte // Parts of open multipart uploads
+	nextID  int
+	log     []string
+
+	failPart       int  // Part number answered with 500
+	failCompletion bool // Answer completion with 200 and an <Error> body
+}
+
+type fakeObject struct {
+	data   []byte
+	header http.Header
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3) {
+	t.Helper()
+	f := &fakeS3{t: t, objects: make(map[string]*fakeObject), uploads: make(map[string]map[int][]byte)}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+
+	s, err := NewS3(Options{
+		Endpoint:  srv.URL,
+		Bucket:    "mirror",
+		AccessKey: "key",
+		SecretKey: "secret",
+		PathStyle: true,
+		PartSize:  minPartSize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, s
+}
+
+func (f *fakeS3) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/mirror/")
+	query := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+	op := r.Method
+	for _, name := range []string{"uploads", "partNumber", "uploadId"} {
+		if _, ok := query[name]; ok {
+			op += "?" + name
+			break
+		}
+	}
+	f.log = append(f.log, op+" "+key)
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		f.fail(w, http.StatusForbidden, "AccessDenied", "unsigned request")
+		return
+	}
+	if got := r.Header.Get("X-Amz-Content-Sha256"); got != hashHex(body) {
+		f.fail(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "payload hash "+got)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		obj := f.objects[key]
+		if obj == nil {
+			f.fail(w, http.StatusNotFound, "NoSuchKey", "no such key")
+			return
+		}
+		for name, values := range obj.header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, len(obj.data)))
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+	case r.Method == http.MethodPut && query.Get("uploadId") != "":
+		parts := f.uploads[query.Get("uploadId")]
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		if parts == nil || number == f.failPart {
+			f.fail(w, http.StatusInternalServerError, "InternalError", "part failed")
+			return
+		}
+		parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, number))
+	case r.Method == http.MethodPut:
+		f.objects[key] = &fakeObject{data: body, header: objectHeaders(r.Header)}
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		id := fmt.Sprintf("upload-%d", f.nextID)
+		f.uploads[id] = map[int][]byte{}
+		// The object's headers are kept for when it is completed
+		f.uploads[id][0] = []byte(r.Header.Get("Content-Type") + "\n" + r.Header.Get("X-Amz-Meta-Sha256"))
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>mirror</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, key, id)
+	case r.Method == http.MethodPost && query.Get("uploadId") != "":
+		parts := f.uploads[query.Get("uploadId")]
+		if f.failCompletion {
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>InternalError</Code><Message>We encountered an internal error.</Message></Error>`)
+			return
+		}
+		var complete struct {
+			Parts []completedPart `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &complete); err != nil || parts == nil {
+			f.fail(w, http.StatusBadRequest, "MalformedXML", "bad completion")
+			return
+		}
+		var data bytes.Buffer
+		for i, part := range complete.Parts {
+			if part.PartNumber != i+1 || part.ETag != fmt.Sprintf(`"part-%d"`, i+1) {
+				f.fail(w, http.StatusBadRequest, "InvalidPart", fmt.Sprintf("part %+v", part))
+				return
+			}
+			data.Write(parts[part.PartNumber])
+		}
+		meta := strings.SplitN(string(parts[0]), "\n", 2)
+		f.objects[key] = &fakeObject{data: data.Bytes(), header: http.Header{"Content-Type": {meta[0]}, "X-Amz-Meta-Sha256": {meta[1]}}}
+		delete(f.uploads, query.Get("uploadId"))
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Key>%s</Key></CompleteMultipartUploadResult>`, key)
+	case r.Method == http.MethodDelete && query.Get("uploadId") != "":
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.fail(w, http.StatusNotImplemented, "NotImplemented", op)
+	}
+}
+
+func (f *fakeS3) fail(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<Error><Code>%s</Code><Message>%s</Message></Error>`, code, message)
+}
+
+// objectHeaders keeps the headers S3 stores with an object
+func objectHeaders(h http.Header) http.Header {
+	kept := http.Header{}
+	for name, values := range h {
+		if name == "Content-Type" || strings.HasPrefix(name, "X-Amz-Meta-") {
+			kept[name] = values
+		}
+	}
+	return kept
+}
+
+// requests returns the logged requests, in order, that start with prefix
+func (f *fakeS3) requests(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var matched []string
+	for _, r := range f.log {
+		if strings.HasPrefix(r, prefix) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// writeTestFile creates a file of size bytes with varying contents
+func writeTestFile(t *testing.T, dir, name string, size int) (string, []byte) {
+	t.Helper()
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+func TestPutFileSinglePart(t *testing.T) {
+	f, s := newFakeS3(t)
+	path, data := writeTestFile(t, t.TempDir(), "a.dmg", 1000)
+
+	if err := s.PutFile(context.Background(), "qoder/a b.dmg", path, "application/x-apple-diskimage", map[string]string{"sha256": "abc"}); err != nil {
+		t.Fatal(err)
+	}
+	obj := f.objects["qoder/a b.dmg"]
+	if obj == nil || !bytes.Equal(obj.data, data) {
+		t.Fatalf("object not stored intact")
+	}
+	if got := obj.header.Get("Content-Type"); got != "application/x-apple-diskimage" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := f.requests("POST"); len(got) != 0 {
+		t.Errorf("small file used multipart: %v", got)
+	}
+
+	head, err := s.Head(context.Background(), "qoder/a b.dmg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Size != 1000 || head.Metadata["sha256"] != "abc" {
+		t.Errorf("Head = %+v", head)
+	}
+	if missing, err := s.Head(context.Background(), "qoder/none"); missing != nil || err != nil {
+		t.Errorf("Head of a missing key = %+v, %v", missing, err)
+	}
+}
+
+func TestPutFileMultipart(t *testing.T) {
+	f, s := newFakeS3(t)
+	path, data := writeTestFile(t, t.TempDir(), "big.AppImage", 2*minPartSize+1234)
+
+	if err := s.PutFile(context.Background(), "big.AppImage", path, "application/vnd.appimage", map[string]string{"sha256": "def"}); err != nil {
+		t.Fatal(err)
+	}
+	obj := f.objects["big.AppImage"]
+	if obj == nil || !bytes.Equal(obj.data, data) {
+		t.Fatalf("joined object differs from the file")
+	}
+	if got := obj.header.Get("X-Amz-Meta-Sha256"); got != "def" {
+		t.Errorf("metadata sha256 = %q, want def", got)
+	}
+	want := []string{"POST?uploads big.AppImage", "PUT?partNumber big.AppImage", "PUT?partNumber big.AppImage", "PUT?partNumber big.AppImage", "POST?uploadId big.AppImage"}
+	if got := f.requests(""); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
+
+func TestPutFileMultipartAbortsOnError(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		setup func(f *fakeS3)
+		want  string
+	}{
+		{"failed part", func(f *fakeS3) { f.failPart = 2 }, "failed to upload part 2"},
+		{"error after 200 on completion", func(f *fakeS3) { f.failCompletion = true }, "We encountered an internal error"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, s := newFakeS3(t)
+			tc.setup(f)
+			path, _ := writeTestFile(t, t.TempDir(), "big.exe", 2*minPartSize+1)
+
+			err := s.PutFile(context.Background(), "big.exe", path, "", nil)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("err = %v, want it to mention %q", err, tc.want)
+			}
+			if got := f.requests("DELETE?uploadId"); len(got) != 1 {
+				t.Errorf("upload was not aborted: %v", f.requests(""))
+			}
+			if len(f.uploads) != 0 || f.objects["big.exe"] != nil {
+				t.Errorf("upload left behind: %d open, object %v", len(f.uploads), f.objects["big.exe"] != nil)
+			}
+		})
+	}
+}
+
+// sortedKeys lists the stored keys, for messages
+func (f *fakeS3) sortedKeys() []string {
+	var keys []string
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
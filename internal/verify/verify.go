@@ -0,0 +1,305 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vibe-coding-labs/qoder-downloader/internal/downloader"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/manifest"
+	"github.com/vibe-coding-labs/qoder-downloader/internal/platform"
+)
+
+// Status describes the outcome of checking a single file
+type Status string
+
+const (
+	StatusOK               Status = "ok"
+	StatusMissing          Status = "missing"
+	StatusExtra            Status = "extra"
+	StatusTruncated        Status = "truncated"
+	StatusModified         Status = "modified"
+	StatusUpstreamMismatch Status = "upstream-mismatch"
+)
+
+// Result is the verification outcome for one file
+type Result struct {
+	Path           string `json:"path"`
+	Version        string `json:"version,omitempty"`
+	Platform       string `json:"platform,omitempty"`
+	Artifact       string `json:"artifact,omitempty"`
+	Status         Status `json:"status"`
+	ExpectedSize   int64  `json:"expected_size,omitempty"`
+	ActualSize     int64  `json:"actual_size,omitempty"`
+	ExpectedSHA256 string `json:"expected_sha256,omitempty"`
+	ActualSHA256   string `json:"actual_sha256,omitempty"`
+	UpstreamMD5    string `json:"upstream_md5,omitempty"`
+	Detail         string `json:"detail,omitempty"`
+}
+
+// Report collects the results of a verification run
+type Report struct {
+	Dir     string         `json:"dir"`
+	Quick   bool           `json:"quick"`
+	Results []Result       `json:"results"`
+	Counts  map[Status]int `json:"counts"`
+}
+
+// OK reports whether every file matched the manifest
+func (r *Report) OK() bool {
+	for status, count := range r.Counts {
+		if status != StatusOK && count > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Problems returns the results that are not OK
+func (r *Report) Problems() []Result {
+	var problems []Result
+	for _, result := range r.Results {
+		if result.Status != StatusOK {
+			problems = append(problems, result)
+		}
+	}
+	return problems
+}
+
+// Options controls a verification run
+type Options struct {
+	Quick    bool // Compare sizes only
+	Upstream bool // Also compare against the upstream MD5 checksums
+	Jobs     int  // Number of files hashed in parallel
+	Verbose  bool
+}
+
+// Verifier checks a downloads directory against its manifest
+type Verifier struct {
+	opts   Options
+	client *http.Client
+}
+
+// NewVerifier creates a new verifier
+func NewVerifier(opts Options) *Verifier {
+	if opts.Jobs < 1 {
+		opts.Jobs = 1
+	}
+	return &Verifier{
+		opts: opts,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Verify checks every manifest entry and looks for files the manifest does
+// not know about. It stops with ctx.Err() once ctx is cancelled.
+func (v *Verifier) Verify(ctx context.Context, m *manifest.Manifest) (*Report, error) {
+	results := make([]Result, len(m.Entries))
+
+	var wg sync.WaitGroup
+	work := make(chan int)
+	for i := 0; i < v.opts.Jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range work {
+				results[idx] = v.CheckEntry(ctx, m, m.Entries[idx])
+			}
+		}()
+	}
+feed:
+	for i := range m.Entries {
+		select {
+		case work <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	extras, err := v.findExtras(m)
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, extras...)
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Path < results[j].Path
+	})
+
+	report := &Report{
+		Dir:     m.Dir(),
+		Quick:   v.opts.Quick,
+		Results: results,
+		Counts:  make(map[Status]int),
+	}
+	for _, result := range results {
+		report.Counts[result.Status]++
+	}
+
+	return report, nil
+}
+
+// CheckEntry checks a single manifest entry against the file on disk. The
+// result is incomplete when ctx is cancelled, which callers check for.
+func (v *Verifier) CheckEntry(ctx context.Context, m *manifest.Manifest, entry *manifest.Entry) Result {
+	result := Result{
+		Path:           entry.Path,
+		Version:        entry.Version,
+		Platform:       entry.Platform,
+		Artifact:       entry.Artifact,
+		ExpectedSize:   entry.Size,
+		ExpectedSHA256: entry.SHA256,
+	}
+
+	path := m.AbsPath(entry)
+	info, err := os.Stat(path)
+	if err != nil {
+		result.Status = StatusMissing
+		if !os.IsNotExist(err) {
+			result.Detail = err.Error()
+		}
+		return result
+	}
+	result.ActualSize = info.Size()
+
+	if info.Size() < entry.Size {
+		result.Status = StatusTruncated
+		return result
+	}
+	if info.Size() > entry.Size {
+		result.Status = StatusModified
+		result.Detail = "file is larger than recorded"
+		return result
+	}
+
+	if v.opts.Quick {
+		result.Status = StatusOK
+		return result
+	}
+
+	if v.opts.Verbose {
+		fmt.Printf("Hashing %s\n", entry.Path)
+	}
+
+	_, md5Sum, sha256Sum, err := downloader.HashFileContext(ctx, path)
+	if ctx.Err() != nil {
+		result.Detail = ctx.Err().Error()
+		return result
+	}
+	if err != nil {
+		result.Status = StatusModified
+		result.Detail = err.Error()
+		return result
+	}
+	result.ActualSHA256 = sha256Sum
+
+	if sha256Sum != entry.SHA256 || (entry.MD5 != "" && md5Sum != entry.MD5) {
+		result.Status = StatusModified
+		return result
+	}
+
+	if v.opts.Upstream {
+		upstream, err := v.UpstreamMD5(ctx, entry)
+		if err != nil {
+			result.Detail = fmt.Sprintf("upstream checksum unavailable: %v", err)
+		} else {
+			result.UpstreamMD5 = upstream
+			if !strings.EqualFold(upstream, md5Sum) {
+				result.Status = StatusUpstreamMismatch
+				return result
+			}
+		}
+	}
+
+	result.Status = StatusOK
+	return result
+}
+
+// UpstreamMD5 retrieves the MD5 published next to an artifact on the release server
+func (v *Verifier) UpstreamMD5(ctx context.Context, entry *manifest.Entry) (string, error) {
+	url := platform.ConstructMD5URL(entry.URL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d from %s", resp.StatusCode, url)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+
+	// Accept both a bare digest and the "digest  filename" format of md5sum
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 || len(fields[0]) != 32 {
+		return "", fmt.Errorf("unexpected checksum format from %s", url)
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// findExtras walks the downloads directory for files that are not in the manifest
+func (v *Verifier) findExtras(m *manifest.Manifest) ([]Result, error) {
+	known := make(map[string]bool, len(m.Entries))
+	for _, entry := range m.Entries {
+		known[entry.Path] = true
+	}
+	// Backups kept when upstream republished a build are accounted for by events
+	for _, backup := range m.Backups() {
+		known[backup] = true
+	}
+
+	var extras []Result
+	err := filepath.Walk(m.Dir(), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == m.Dir() {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := m.RelPath(path)
+		if err != nil {
+			return err
+		}
+		if known[rel] || manifest.IsBookkeeping(rel) {
+			return nil
+		}
+
+		extras = append(extras, Result{
+			Path:       rel,
+			Status:     StatusExtra,
+			ActualSize: info.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", m.Dir(), err)
+	}
+
+	return extras, nil
+}